@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// AcceptTrail creates t and appends first, its REQUESTED event, both built
+// by another Service such as a store/remote client, or stores neither. t's
+// time must be in whole microseconds and this Service's sanitizer must leave
+// t unchanged; first is checked as AcceptEvent checks events, against t
+// with no history, before t is created.
+func (s *Service) AcceptTrail(ctx context.Context, t Trail, first Event) error {
+	if t.ID == "" || t.Title == "" {
+		return invalidf("trail id and title are required")
+	}
+	if !t.CreatedAt.Equal(t.CreatedAt.Truncate(time.Microsecond)) {
+		return invalidf("trail %s: time is finer than a microsecond", t.ID)
+	}
+	got, err := ComputeTrailHash(t)
+	if err != nil {
+		return err
+	}
+	want, err := ComputeTrailHash(s.sanitizer.SanitizeTrail(t))
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return invalidf("trail %s: not sanitized", t.ID)
+	}
+	if first.TrailID != t.ID || first.Type != EventRequested {
+		return invalidf("trail %s: the first event must be its %s event", t.ID, EventRequested)
+	}
+
+	// Stores cannot delete trails, so check first before t exists.
+	first.Seq = 0
+	if err := s.checkAccepted(ctx, t, nil, first); err != nil {
+		return err
+	}
+	if err := s.store.CreateTrail(ctx, t); err != nil {
+		return err
+	}
+	return s.store.CompareAndAppend(ctx, first)
+}
+
+// AcceptEvent appends e, an event built, hashed and perhaps signed by
+// another Service such as a store/remote client, after checking it the way
+// this Service checks its own: e must extend the head of its trail, have an
+// actor ID without padding and a time in whole microseconds, hash to e.Hash,
+// come out of this Service's sanitizer and target scoping unchanged, and
+// pass the lifecycle and policies. With a key resolver it must also carry a
+// valid signature. The store assigns e.Seq.
+//
+// A stale e.PrevHash fails with ErrConflict rather than being retried: the
+// hash and signature cover it, so only the builder can redo the event.
+func (s *Service) AcceptEvent(ctx context.Context, e Event) error {
+	e.Seq = 0
+	trail, events, err := s.store.GetTrail(ctx, e.TrailID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkAccepted(ctx, trail, events, e); err != nil {
+		return err
+	}
+	return s.store.CompareAndAppend(ctx, e)
+}
+
+// checkAccepted runs AcceptEvent's checks of e against trail and its events.
+func (s *Service) checkAccepted(ctx context.Context, trail Trail, events []Event, e Event) error {
+	if e.ID == "" {
+		return invalidf("event id is required")
+	}
+	if id := strings.TrimSpace(e.Actor.ID); id == "" || id != e.Actor.ID {
+		return invalidf("event %s: actor id is required and must not be padded", e.ID)
+	}
+	if !e.At.Equal(e.At.Truncate(time.Microsecond)) {
+		return invalidf("event %s: time is finer than a microsecond", e.ID)
+	}
+	if sum, err := ComputeEventHash(e); err != nil || sum != e.Hash {
+		return invalidf("event %s: hash does not match its content", e.ID)
+	}
+
+	head := ""
+	if n := len(events); n > 0 {
+		head = events[n-1].Hash
+	}
+	if e.PrevHash != head {
+		return ErrConflict
+	}
+
+	// Rebuild what this Service would have recorded from the same input.
+	var err error
+	want := s.sanitizeEvent(e)
+	if e.Type == EventRequested {
+		want.Targets = trail.Targets
+		if want.TrailHash, err = ComputeTrailHash(trail); err != nil {
+			return err
+		}
+	} else if want.Targets, want.Commands, err = scopeTargets(trail, want.Commands); err != nil {
+		return err
+	}
+	if sum, err := ComputeEventHash(want); err != nil || sum != e.Hash {
+		return invalidf("event %s: differs from what this service would record (sanitized text, targets or trail hash)", e.ID)
+	}
+
+	if err := s.checkAppend(trail, events, e); err != nil {
+		return err
+	}
+	if s.keys != nil {
+		if reason := s.verifySignature(ctx, e); reason != "" {
+			return &VerifyError{TrailID: e.TrailID, EventID: e.ID, Index: len(events), Reason: reason, Err: ErrBadSignature}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Checkpoint commits to the first TreeSize ledger entries with the Merkle
+// root over their event hashes. Signed checkpoints can be handed to auditors
+// and later used to check inclusion and consistency proofs offline.
+type Checkpoint struct {
+	TreeSize int64     `json:"tree_size"`
+	RootHash string    `json:"root_hash"`
+	At       time.Time `json:"at"`
+
+	KeyID     string `json:"key_id,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// CheckpointStore is implemented by stores that persist checkpoints.
+type CheckpointStore interface {
+	SaveCheckpoint(ctx context.Context, c Checkpoint) error
+	// LatestCheckpoint returns the checkpoint with the largest TreeSize,
+	// or nil if there is none.
+	LatestCheckpoint(ctx context.Context) (*Checkpoint, error)
+}
+
+// checkpointPayload is exactly what we sign for a checkpoint.
+type checkpointPayload struct {
+	TreeSize   int64  `json:"tree_size"`
+	RootHash   string `json:"root_hash"`
+	AtUnixNano int64  `json:"at_unix_nano"`
+}
+
+func checkpointDigest(c Checkpoint) ([]byte, error) {
+	b, err := json.Marshal(checkpointPayload{
+		TreeSize:   c.TreeSize,
+		RootHash:   c.RootHash,
+		AtUnixNano: c.At.UnixNano(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(b)
+	return sum[:], nil
+}
+
+// VerifyCheckpoint checks the signature on c with pub.
+func VerifyCheckpoint(c Checkpoint, pub crypto.PublicKey) error {
+	if c.Signature == "" {
+		return errors.New("audit: checkpoint is not signed")
+	}
+	digest, err := checkpointDigest(c)
+	if err != nil {
+		return err
+	}
+	// Reuse the event signature check: it verifies a signature over a hash.
+	return VerifyEventSignature(Event{Hash: hex.EncodeToString(digest), Signature: c.Signature}, pub)
+}
+
+// Checkpoint builds the Merkle tree over the whole ledger and returns a
+// checkpoint for it, signed if the service has a signer. If the store
+// implements CheckpointStore the checkpoint is saved, unless the ledger has
+// not grown since the latest one, which is then returned instead.
+func (s *Service) Checkpoint(ctx context.Context) (Checkpoint, error) {
+	leaves, _, err := s.ledgerLeaves(ctx)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	cs, persist := s.store.(CheckpointStore)
+	if persist {
+		latest, err := cs.LatestCheckpoint(ctx)
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		if latest != nil && latest.TreeSize == int64(len(leaves)) {
+			return *latest, nil
+		}
+	}
+
+	c := Checkpoint{
+		TreeSize: int64(len(leaves)),
+		RootHash: hex.EncodeToString(treeHash(leaves)),
+		At:       s.now(),
+	}
+	if s.signer != nil {
+		digest, err := checkpointDigest(c)
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		sig, err := s.signer.Sign(digest)
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		c.KeyID = s.signer.KeyID()
+		c.Signature = base64.StdEncoding.EncodeToString(sig)
+	}
+
+	if persist {
+		if err := cs.SaveCheckpoint(ctx, c); err != nil {
+			return Checkpoint{}, err
+		}
+	}
+	return c, nil
+}
+
+// RunCheckpoints calls Checkpoint every interval until ctx is done.
+func (s *Service) RunCheckpoints(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := s.Checkpoint(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// InclusionProof proves that the event is in the tree of treeSize leaves,
+// as committed to by a checkpoint of that size.
+func (s *Service) InclusionProof(ctx context.Context, eventID string, treeSize int64) (InclusionProof, error) {
+	leaves, entries, err := s.ledgerLeaves(ctx)
+	if err != nil {
+		return InclusionProof{}, err
+	}
+	if treeSize <= 0 || treeSize > int64(len(leaves)) {
+		return InclusionProof{}, fmt.Errorf("audit: tree size %d out of range (ledger has %d entries)", treeSize, len(leaves))
+	}
+
+	for i, le := range entries[:treeSize] {
+		if le.EventID != eventID {
+			continue
+		}
+		return InclusionProof{
+			EventID:   eventID,
+			EventHash: le.EventHash,
+			LeafIndex: int64(i),
+			TreeSize:  treeSize,
+			Hashes:    encodeHashes(inclusionPath(i, leaves[:treeSize])),
+		}, nil
+	}
+	return InclusionProof{}, fmt.Errorf("audit: event %s not in the first %d ledger entries", eventID, treeSize)
+}
+
+// ConsistencyProof proves that the tree of oldSize leaves is a prefix of the
+// tree of newSize leaves.
+func (s *Service) ConsistencyProof(ctx context.Context, oldSize, newSize int64) (ConsistencyProof, error) {
+	leaves, _, err := s.ledgerLeaves(ctx)
+	if err != nil {
+		return ConsistencyProof{}, err
+	}
+	if oldSize < 0 || oldSize > newSize || newSize > int64(len(leaves)) {
+		return ConsistencyProof{}, fmt.Errorf("audit: invalid tree sizes %d..%d (ledger has %d entries)", oldSize, newSize, len(leaves))
+	}
+
+	p := ConsistencyProof{OldSize: oldSize, NewSize: newSize, Hashes: []string{}}
+	if oldSize > 0 && oldSize < newSize {
+		p.Hashes = encodeHashes(consistencyPath(int(oldSize), leaves[:newSize]))
+	}
+	return p, nil
+}
+
+// ledgerLeaves returns the raw event hashes of the whole ledger, in order.
+func (s *Service) ledgerLeaves(ctx context.Context) ([][]byte, []LedgerEntry, error) {
+	l, ok := s.store.(Ledger)
+	if !ok {
+		return nil, nil, ErrNoLedger
+	}
+	entries, err := l.LedgerEntries(ctx, 0, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	leaves := make([][]byte, 0, len(entries))
+	for _, le := range entries {
+		b, err := hex.DecodeString(le.EventHash)
+		if err != nil {
+			return nil, nil, fmt.Errorf("audit: malformed event hash at seq %d: %w", le.Seq, err)
+		}
+		leaves = append(leaves, b)
+	}
+	return leaves, entries, nil
+}
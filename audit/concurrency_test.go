@@ -0,0 +1,78 @@
+package audit_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestConcurrentAppendsDoNotForkChain(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Concurrent approvals",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	// Each approval races on the same head; the store must serialize them and
+	// the service must retry the losers against the new head.
+	const approvers = 4
+	var wg sync.WaitGroup
+	errs := make(chan error, approvers)
+	for i := 0; i < approvers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", "")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		}
+	}
+
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	if len(events) != succeeded+1 {
+		t.Fatalf("expected %d events, got %d", succeeded+1, len(events))
+	}
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail should pass, got error: %v", err)
+	}
+}
+
+func TestCompareAndAppendRejectsStaleHead(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Stale head",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	err = st.CompareAndAppend(ctx, audit.Event{ID: "e-x", TrailID: trailID, Type: audit.EventApproved, PrevHash: "not-the-head"})
+	if err != audit.ErrConflict {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+}
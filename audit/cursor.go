@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrBadCursor is returned for a cursor that was not produced by a store.
+var ErrBadCursor = errors.New("audit: malformed cursor")
+
+// TrailCursor is the position of the last trail on a ListTrails page.
+// Trails are listed newest first, ties broken by descending ID.
+// Stores hand it out opaquely as TrailPage.NextCursor.
+type TrailCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"i"`
+}
+
+// Precedes reports whether t is listed after the cursor position, i.e.
+// belongs on a later page.
+func (c TrailCursor) Precedes(t Trail) bool {
+	if !t.CreatedAt.Equal(c.CreatedAt) {
+		return t.CreatedAt.Before(c.CreatedAt)
+	}
+	return t.ID < c.ID
+}
+
+func cursorOf(t Trail) TrailCursor {
+	return TrailCursor{CreatedAt: t.CreatedAt, ID: t.ID}
+}
+
+func EncodeTrailCursor(c TrailCursor) string {
+	return encodeCursor(c)
+}
+
+func DecodeTrailCursor(s string) (TrailCursor, error) {
+	var c TrailCursor
+	if err := decodeCursor(s, &c); err != nil {
+		return TrailCursor{}, err
+	}
+	if c.ID == "" {
+		return TrailCursor{}, ErrBadCursor
+	}
+	return c, nil
+}
+
+// EventCursor is the position of the last event on a QueryEvents page.
+// Events are ordered by their ledger Seq, which every store assigns.
+type EventCursor struct {
+	Seq int64 `json:"s"`
+}
+
+func EncodeEventCursor(c EventCursor) string {
+	return encodeCursor(c)
+}
+
+func DecodeEventCursor(s string) (EventCursor, error) {
+	var c EventCursor
+	if err := decodeCursor(s, &c); err != nil {
+		return EventCursor{}, err
+	}
+	if c.Seq <= 0 {
+		return EventCursor{}, ErrBadCursor
+	}
+	return c, nil
+}
+
+// Ascending reports whether o is OrderAsc. Any other value, including the
+// zero value, means newest first.
+func (o Order) Ascending() bool { return o == OrderAsc }
+
+func encodeCursor(v any) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return ErrBadCursor
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrBadCursor
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"sort"
+	"time"
 )
 
 // KV is a stable, sortable representation of map entries.
@@ -65,6 +66,9 @@ type hashPayload struct {
 	Commands []Command           `json:"commands,omitempty"`
 	Result   *Result             `json:"result,omitempty"`
 	Evidence []canonicalEvidence `json:"evidence,omitempty"`
+
+	// Added after v1 shipped; omitted when empty so older hashes still verify.
+	TrailHash string `json:"trail_hash,omitempty"`
 }
 
 func toCanonicalActor(a Actor) canonicalActor {
@@ -106,7 +110,8 @@ func toCanonicalEvidence(es []Evidence) []canonicalEvidence {
 	return out
 }
 
-func ComputeEventHash(e Event) (string, error) {
+// hashV1 is the original scheme: json.Marshal of hashPayload, SHA-256.
+func hashV1(e Event) (string, error) {
 	p := hashPayload{
 		ID:            e.ID,
 		TrailID:       e.TrailID,
@@ -119,6 +124,7 @@ func ComputeEventHash(e Event) (string, error) {
 		Commands:      e.Commands,
 		Result:        e.Result,
 		Evidence:      toCanonicalEvidence(e.Evidence),
+		TrailHash:     e.TrailHash,
 	}
 
 	b, err := json.Marshal(p)
@@ -129,3 +135,34 @@ func ComputeEventHash(e Event) (string, error) {
 	sum := sha256.Sum256(b)
 	return hex.EncodeToString(sum[:]), nil
 }
+
+// trailHeaderPayload is exactly what we hash for a Trail header.
+type trailHeaderPayload struct {
+	ID            string   `json:"id"`
+	CreatedAt     string   `json:"created_at"`
+	Title         string   `json:"title"`
+	Description   string   `json:"description,omitempty"`
+	CorrelationID string   `json:"correlation_id,omitempty"`
+	Targets       []Target `json:"targets,omitempty"`
+}
+
+// ComputeTrailHash hashes the Trail header (RFC 8785 canonical JSON, SHA-256).
+// The REQUESTED event records it in TrailHash, which puts the header under
+// the event hash and therefore under the whole chain. CreatedAt is hashed to
+// the microsecond, so it must be stored no finer than that.
+func ComputeTrailHash(t Trail) (string, error) {
+	b, err := canonicalJSON(trailHeaderPayload{
+		ID:            t.ID,
+		CreatedAt:     t.CreatedAt.UTC().Truncate(time.Microsecond).Format(v2TimeLayout),
+		Title:         t.Title,
+		Description:   t.Description,
+		CorrelationID: t.CorrelationID,
+		Targets:       t.Targets,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
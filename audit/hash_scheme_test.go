@@ -0,0 +1,61 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestVerifyTrailWithMixedHashVersions(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	legacy := audit.NewService(st, audit.NoopSanitizer{}, audit.WithHashVersion(audit.HashV1))
+
+	trailID, err := legacy.Request(ctx, audit.RequestInput{
+		Title:     "Mixed schemes",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	// The trail continues after an upgrade to the default (newer) scheme.
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail should pass, got error: %v", err)
+	}
+
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	if events[0].HashVersion != audit.HashV1 || events[1].HashVersion != audit.DefaultHashVersion {
+		t.Fatalf("unexpected hash versions %d, %d", events[0].HashVersion, events[1].HashVersion)
+	}
+
+	// Relabelling an event to another scheme is detected.
+	relabelled := &relabellingStore{Store: st}
+	if err := audit.NewService(relabelled, audit.NoopSanitizer{}).VerifyTrail(ctx, trailID); err == nil {
+		t.Fatalf("expected verification to fail after changing a hash version")
+	}
+}
+
+type relabellingStore struct{ audit.Store }
+
+func (r *relabellingStore) GetTrail(ctx context.Context, trailID string) (audit.Trail, []audit.Event, error) {
+	tr, evs, err := r.Store.GetTrail(ctx, trailID)
+	if err != nil {
+		return audit.Trail{}, nil, err
+	}
+	if len(evs) > 1 {
+		evs[1].HashVersion = audit.HashV1
+	}
+	return tr, evs, nil
+}
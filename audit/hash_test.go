@@ -45,3 +45,47 @@ func TestComputeEventHashIsStableWithMapCanonicalization(t *testing.T) {
 		t.Fatalf("expected stable hash; got h1=%s h2=%s", h1, h2)
 	}
 }
+
+func TestComputeEventHashV2KnownAnswer(t *testing.T) {
+	exitCode := 0
+	e := audit.Event{
+		ID:            "e-1",
+		TrailID:       "t-1",
+		Type:          audit.EventExecuted,
+		At:            time.Date(2026, 2, 3, 12, 0, 0, 999, time.UTC), // sub-microsecond part is not hashed
+		Actor:         audit.Actor{ID: "u-1", Role: audit.RoleExecutor, Meta: map[string]string{"b": "2", "a": "1"}},
+		PrevHash:      "prev",
+		Commands:      []audit.Command{{Kind: "cli", Raw: "x"}},
+		Result:        &audit.Result{Status: "SUCCESS", ExitCode: &exitCode},
+		CorrelationID: "corr",
+		HashVersion:   audit.HashV2,
+	}
+
+	// SHA-512/256 over the RFC 8785 form:
+	// {"actor":{"id":"u-1","meta":{"a":"1","b":"2"},"role":"EXECUTOR"},"at":"2026-02-03T12:00:00.000000Z",...}
+	const want = "583a19346a8c3d6d0548be8632edf8ff817c67156d3b46265a3d7191019121b2"
+
+	got, err := audit.ComputeEventHash(e)
+	if err != nil {
+		t.Fatalf("ComputeEventHash error: %v", err)
+	}
+	if got != want {
+		t.Fatalf("unexpected v2 hash: got %s want %s", got, want)
+	}
+
+	e.HashVersion = audit.HashV1
+	v1, err := audit.ComputeEventHash(e)
+	if err != nil {
+		t.Fatalf("ComputeEventHash error: %v", err)
+	}
+	if v1 == got {
+		t.Fatalf("expected v1 and v2 hashes to differ")
+	}
+}
+
+func TestRegisterHashSchemeRejectsExistingVersion(t *testing.T) {
+	scheme := audit.HashSchemeFunc(func(audit.Event) (string, error) { return "", nil })
+	if err := audit.RegisterHashScheme(audit.HashV1, scheme); err == nil {
+		t.Fatalf("expected registering over HashV1 to fail")
+	}
+}
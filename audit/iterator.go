@@ -0,0 +1,65 @@
+package audit
+
+import "context"
+
+// EventIterator walks the results of a query one event at a time, so exports
+// of millions of events run in constant memory. Callers must Close it.
+//
+//	it, err := store.IterateEvents(ctx, q)
+//	if err != nil { ... }
+//	defer it.Close()
+//	for it.Next() {
+//		e := it.Event()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type EventIterator interface {
+	// Next advances to the next event. It returns false when the results
+	// are exhausted, an error occurred or ctx was cancelled.
+	Next() bool
+	// Event returns the current event.
+	Event() Event
+	// Err returns the error that stopped iteration, if any.
+	Err() error
+	// Close releases the underlying resources. It is safe to call twice.
+	Close() error
+}
+
+// SliceIterator returns an EventIterator over events that are already in
+// memory. Next stops with ctx's error once ctx is cancelled.
+func SliceIterator(ctx context.Context, events []Event) EventIterator {
+	return &sliceIterator{ctx: ctx, events: events, pos: -1}
+}
+
+type sliceIterator struct {
+	ctx    context.Context
+	events []Event
+	pos    int
+	err    error
+}
+
+func (it *sliceIterator) Next() bool {
+	if it.err != nil || it.pos >= len(it.events) {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+	it.pos++
+	return it.pos < len(it.events)
+}
+
+func (it *sliceIterator) Event() Event {
+	if it.pos < 0 || it.pos >= len(it.events) {
+		return Event{}
+	}
+	return it.events[it.pos]
+}
+
+func (it *sliceIterator) Err() error { return it.err }
+
+func (it *sliceIterator) Close() error {
+	it.events = nil
+	it.pos = 0
+	return nil
+}
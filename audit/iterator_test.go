@@ -0,0 +1,77 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestIterateEventsStreamsInOrder(t *testing.T) {
+	ctx := context.Background()
+
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{})
+	for i := 0; i < 3; i++ {
+		trailID, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}})
+		if err != nil {
+			t.Fatalf("Request error: %v", err)
+		}
+		if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+			t.Fatalf("Approve error: %v", err)
+		}
+	}
+
+	it, err := svc.IterateEvents(ctx, audit.Query{Order: audit.OrderAsc, EventTypes: []audit.EventType{audit.EventApproved}})
+	if err != nil {
+		t.Fatalf("IterateEvents error: %v", err)
+	}
+	defer it.Close()
+
+	var seqs []int64
+	for it.Next() {
+		e := it.Event()
+		if e.Type != audit.EventApproved {
+			t.Fatalf("expected only APPROVED events, got %s", e.Type)
+		}
+		seqs = append(seqs, e.Seq)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration error: %v", err)
+	}
+	if len(seqs) != 3 || seqs[0] >= seqs[1] || seqs[1] >= seqs[2] {
+		t.Fatalf("expected 3 APPROVED events in ascending seq, got %v", seqs)
+	}
+	if err := it.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+}
+
+func TestIterateEventsStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{})
+	for i := 0; i < 3; i++ {
+		if _, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}}); err != nil {
+			t.Fatalf("Request error: %v", err)
+		}
+	}
+
+	it, err := svc.IterateEvents(ctx, audit.Query{})
+	if err != nil {
+		t.Fatalf("IterateEvents error: %v", err)
+	}
+	defer it.Close()
+
+	if !it.Next() {
+		t.Fatalf("expected a first event, got err %v", it.Err())
+	}
+	cancel()
+	for it.Next() {
+	}
+	if !errors.Is(it.Err(), context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", it.Err())
+	}
+}
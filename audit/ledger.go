@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// LedgerEntry links one event into the global ledger. Every event appended to
+// any trail gets the next sequence number and chains to the previous entry,
+// so deleting or reordering whole trails breaks the ledger even though each
+// remaining trail still verifies on its own.
+type LedgerEntry struct {
+	Seq       int64  `json:"seq"`
+	TrailID   string `json:"trail_id"`
+	EventID   string `json:"event_id"`
+	EventHash string `json:"event_hash"`
+	PrevHash  string `json:"prev_hash,omitempty"`
+	Hash      string `json:"hash"`
+}
+
+// Ledger is implemented by stores that keep a global ledger across trails.
+// Stores assign Seq and compute Hash atomically with the event append.
+type Ledger interface {
+	// LedgerEntries returns entries with fromSeq <= Seq <= toSeq in ascending
+	// Seq order. toSeq <= 0 means up to the latest entry.
+	LedgerEntries(ctx context.Context, fromSeq, toSeq int64) ([]LedgerEntry, error)
+}
+
+// ErrNoLedger is returned when the store does not implement Ledger.
+var ErrNoLedger = errors.New("audit: store does not keep a ledger")
+
+// ledgerPayload is exactly what we hash for a ledger entry.
+// Seq is not hashed: the chain itself fixes the order, and stores may leave
+// gaps in the sequence (e.g. rolled back transactions).
+type ledgerPayload struct {
+	TrailID   string `json:"trail_id"`
+	EventID   string `json:"event_id"`
+	EventHash string `json:"event_hash"`
+	PrevHash  string `json:"prev_hash,omitempty"`
+}
+
+func ComputeLedgerHash(e LedgerEntry) (string, error) {
+	b, err := json.Marshal(ledgerPayload{
+		TrailID:   e.TrailID,
+		EventID:   e.EventID,
+		EventHash: e.EventHash,
+		PrevHash:  e.PrevHash,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyLedger checks the global ledger between fromSeq and toSeq
+// (toSeq <= 0 means up to the latest entry). It detects:
+// - deleted trails or events (PrevHash mismatch)
+// - reordered entries (PrevHash mismatch or non-increasing Seq)
+// - edited entries (Hash mismatch)
+//
+// When fromSeq > 1 the first entry in the range is the trusted anchor;
+// verify from the start of the ledger to check the whole history.
+func (s *Service) VerifyLedger(ctx context.Context, fromSeq, toSeq int64) error {
+	l, ok := s.store.(Ledger)
+	if !ok {
+		return ErrNoLedger
+	}
+
+	entries, err := l.LedgerEntries(ctx, fromSeq, toSeq)
+	if err != nil {
+		return err
+	}
+
+	for i, le := range entries {
+		if i == 0 {
+			if fromSeq <= 1 && le.PrevHash != "" {
+				return ledgerError(le, i, ErrChainBroken, "first ledger entry PrevHash must be empty")
+			}
+		} else {
+			prev := entries[i-1]
+			if le.Seq <= prev.Seq {
+				return ledgerError(le, i, ErrChainBroken, fmt.Sprintf("Seq not increasing (%d after %d)", le.Seq, prev.Seq))
+			}
+			if le.PrevHash != prev.Hash {
+				return ledgerError(le, i, ErrChainBroken, fmt.Sprintf("ledger PrevHash mismatch at seq %d (expected %s, got %s)", le.Seq, short(prev.Hash), short(le.PrevHash)))
+			}
+		}
+
+		expected, err := ComputeLedgerHash(le)
+		if err != nil {
+			return err
+		}
+		if le.Hash != expected {
+			return ledgerError(le, i, ErrHashMismatch, fmt.Sprintf("ledger Hash mismatch at seq %d (expected %s, got %s)", le.Seq, short(expected), short(le.Hash)))
+		}
+	}
+
+	return nil
+}
+
+func ledgerError(le LedgerEntry, i int, kind error, reason string) *VerifyError {
+	return &VerifyError{
+		TrailID: le.TrailID,
+		EventID: le.EventID,
+		Index:   i,
+		Reason:  reason,
+		Err:     kind,
+	}
+}
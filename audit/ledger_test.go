@@ -0,0 +1,89 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestVerifyLedgerDetectsDeletedTrail(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	var trailIDs []string
+	for _, title := range []string{"Change A", "Change B", "Change C"} {
+		trailID, err := svc.Request(ctx, audit.RequestInput{
+			Title:     title,
+			Requester: audit.Actor{ID: "u-1"},
+		})
+		if err != nil {
+			t.Fatalf("Request error: %v", err)
+		}
+		if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+			t.Fatalf("Approve error: %v", err)
+		}
+		trailIDs = append(trailIDs, trailID)
+	}
+
+	if err := svc.VerifyLedger(ctx, 0, 0); err != nil {
+		t.Fatalf("VerifyLedger should pass, got error: %v", err)
+	}
+
+	entries, err := st.LedgerEntries(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("LedgerEntries error: %v", err)
+	}
+	if len(entries) != 6 {
+		t.Fatalf("expected 6 ledger entries, got %d", len(entries))
+	}
+	_, events, err := st.GetTrail(ctx, trailIDs[1])
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	if events[0].Seq != 3 {
+		t.Fatalf("expected first event of second trail at seq 3, got %d", events[0].Seq)
+	}
+
+	// Each remaining trail still verifies on its own, but the ledger does not.
+	deleted := &trailDeletingStore{Store: st, trailID: trailIDs[1]}
+	svc2 := audit.NewService(deleted, audit.NoopSanitizer{})
+
+	if err := svc2.VerifyTrail(ctx, trailIDs[2]); err != nil {
+		t.Fatalf("VerifyTrail should pass for untouched trail, got error: %v", err)
+	}
+	if err := svc2.VerifyLedger(ctx, 0, 0); err == nil {
+		t.Fatalf("expected VerifyLedger to detect the deleted trail")
+	}
+}
+
+func TestVerifyLedgerWithoutLedgerStore(t *testing.T) {
+	svc := audit.NewService(&tamperingStore{Store: memory.New()}, audit.NoopSanitizer{})
+	if err := svc.VerifyLedger(context.Background(), 0, 0); err != audit.ErrNoLedger {
+		t.Fatalf("expected ErrNoLedger, got %v", err)
+	}
+}
+
+// trailDeletingStore hides every ledger entry of one trail, as if its rows
+// had been deleted from the database.
+type trailDeletingStore struct {
+	*memory.Store
+	trailID string
+}
+
+func (d *trailDeletingStore) LedgerEntries(ctx context.Context, fromSeq, toSeq int64) ([]audit.LedgerEntry, error) {
+	entries, err := d.Store.LedgerEntries(ctx, fromSeq, toSeq)
+	if err != nil {
+		return nil, err
+	}
+	var out []audit.LedgerEntry
+	for _, le := range entries {
+		if le.TrailID != d.trailID {
+			out = append(out, le)
+		}
+	}
+	return out, nil
+}
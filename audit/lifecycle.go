@@ -0,0 +1,63 @@
+package audit
+
+import "fmt"
+
+// StateNew is the state of a trail that has no events yet.
+const StateNew EventType = ""
+
+// Transitions is the trail lifecycle: it maps the current state of a trail
+// (the type of its latest event) to the event types that may be appended next.
+// A state with no outgoing transitions is terminal.
+type Transitions map[EventType][]EventType
+
+// DefaultTransitions returns the lifecycle enforced by NewService:
+//
+//	REQUESTED → APPROVED → EXECUTED → VERIFIED
+//
+// APPROVED may repeat so several approvers can sign off the same change.
+// A change may be CANCELLED before it is executed, an approved or executed
+// change may FAIL, and an executed or failed change may be ROLLED_BACK.
+// VERIFIED, CANCELLED and ROLLED_BACK are terminal.
+func DefaultTransitions() Transitions {
+	return Transitions{
+		StateNew:        {EventRequested},
+		EventRequested:  {EventApproved, EventCancelled},
+		EventApproved:   {EventApproved, EventExecuted, EventFailed, EventCancelled},
+		EventExecuted:   {EventVerified, EventFailed, EventRolledBack},
+		EventFailed:     {EventRolledBack},
+		EventVerified:   nil,
+		EventCancelled:  nil,
+		EventRolledBack: nil,
+	}
+}
+
+// Allows reports whether an event of type to may follow state from.
+func (t Transitions) Allows(from, to EventType) bool {
+	for _, next := range t[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Terminal reports whether no event may follow state.
+func (t Transitions) Terminal(state EventType) bool {
+	return len(t[state]) == 0
+}
+
+// TransitionError is returned when an event would move a trail through a
+// transition its lifecycle does not allow.
+type TransitionError struct {
+	TrailID string
+	From    EventType
+	To      EventType
+}
+
+func (e *TransitionError) Error() string {
+	from := e.From
+	if from == StateNew {
+		from = "<none>"
+	}
+	return fmt.Sprintf("audit: invalid transition: trail=%s from=%s to=%s", e.TrailID, from, e.To)
+}
@@ -0,0 +1,161 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestExecuteBeforeApproveIsRejected(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Lifecycle test",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	err = svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "corr",
+		[]audit.Command{{Kind: "cli", Raw: "do thing"}},
+		audit.Result{Status: "SUCCESS"},
+	)
+
+	var terr *audit.TransitionError
+	if !errors.As(err, &terr) {
+		t.Fatalf("expected TransitionError, got %v", err)
+	}
+	if terr.From != audit.EventRequested || terr.To != audit.EventExecuted {
+		t.Fatalf("unexpected transition in error: %s -> %s", terr.From, terr.To)
+	}
+
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("expected rejected event not to be stored, got %d events", len(events))
+	}
+}
+
+func TestTerminalStateRejectsFurtherEvents(t *testing.T) {
+	ctx := context.Background()
+
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{})
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Lifecycle test",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "", nil, audit.Result{Status: "SUCCESS"}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if err := svc.Verify(ctx, trailID, audit.Actor{ID: "u-3"}, "", nil); err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+
+	err = svc.Verify(ctx, trailID, audit.Actor{ID: "u-3"}, "", nil)
+	var terr *audit.TransitionError
+	if !errors.As(err, &terr) {
+		t.Fatalf("expected TransitionError after terminal state, got %v", err)
+	}
+}
+
+func TestWithTransitionsAllowsCustomLifecycle(t *testing.T) {
+	ctx := context.Background()
+
+	// A lifecycle for pre-approved standard changes: no approval step.
+	tr := audit.Transitions{
+		audit.StateNew:       {audit.EventRequested},
+		audit.EventRequested: {audit.EventExecuted},
+		audit.EventExecuted:  {audit.EventVerified},
+	}
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{}, audit.WithTransitions(tr))
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Standard change",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "", nil, audit.Result{Status: "SUCCESS"}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err == nil {
+		t.Fatalf("expected Approve to be rejected by custom lifecycle")
+	}
+}
+
+func TestFailAndRollbackAreChained(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Rollback test",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "",
+		[]audit.Command{{Kind: "cli", Raw: "ntp server 10.0.0.10"}},
+		audit.Result{Status: "SUCCESS"},
+	); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if err := svc.Fail(ctx, trailID, audit.Actor{ID: "u-3", Role: audit.RoleVerifier}, "",
+		audit.Result{Message: "ntp not in sync"},
+		[]audit.Evidence{{Kind: "show_cmd", Ref: "show ntp status"}},
+	); err != nil {
+		t.Fatalf("Fail error: %v", err)
+	}
+	if err := svc.Rollback(ctx, trailID, audit.Actor{ID: "svc-1"}, "",
+		[]audit.Command{{Kind: "cli", Raw: "no ntp server 10.0.0.10"}},
+		audit.Result{Status: "SUCCESS"},
+		nil,
+	); err != nil {
+		t.Fatalf("Rollback error: %v", err)
+	}
+
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail should pass, got error: %v", err)
+	}
+
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	failed := events[3]
+	if failed.Type != audit.EventFailed || failed.Result == nil || failed.Result.Status != "FAILED" {
+		t.Fatalf("expected FAILED event with FAILED result, got %+v", failed)
+	}
+	if failed.Actor.Role != audit.RoleVerifier {
+		t.Fatalf("expected Fail to keep actor role, got %s", failed.Actor.Role)
+	}
+	if events[4].Type != audit.EventRolledBack {
+		t.Fatalf("expected ROLLED_BACK event, got %s", events[4].Type)
+	}
+
+	if err := svc.Cancel(ctx, trailID, audit.Actor{ID: "u-1"}, "", audit.Result{}, nil); err == nil {
+		t.Fatalf("expected Cancel after rollback to be rejected")
+	}
+}
@@ -0,0 +1,99 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestListTrailsFiltersAndPages(t *testing.T) {
+	ctx := context.Background()
+
+	now := time.Date(2026, 2, 3, 12, 0, 0, 0, time.UTC)
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{}, audit.WithClock(func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}))
+
+	sw12 := audit.Target{Type: "network_device", ID: "sw-12"}
+	var ids []string
+	for i, title := range []string{"Update NTP", "Rotate keys", "update ntp on core", "Patch kernel"} {
+		requester := "u-1"
+		if i%2 == 1 {
+			requester = "u-2"
+		}
+		id, err := svc.Request(ctx, audit.RequestInput{
+			Title:     title,
+			Requester: audit.Actor{ID: requester},
+			Targets:   []audit.Target{sw12},
+		})
+		if err != nil {
+			t.Fatalf("Request error: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := svc.Approve(ctx, ids[2], audit.Actor{ID: "u-3"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+
+	page, err := svc.ListTrails(ctx, audit.TrailQuery{TitleContains: "ntp"})
+	if err != nil {
+		t.Fatalf("ListTrails error: %v", err)
+	}
+	if len(page.Trails) != 2 || page.Trails[0].ID != ids[2] || page.Trails[1].ID != ids[0] {
+		t.Fatalf("expected the two NTP trails newest first, got %+v", page.Trails)
+	}
+	if page.Trails[0].Status != audit.EventApproved || page.Trails[0].Requester.ID != "u-1" {
+		t.Fatalf("unexpected summary %+v", page.Trails[0])
+	}
+
+	page, err = svc.ListTrails(ctx, audit.TrailQuery{RequesterID: "u-2", Status: audit.EventRequested})
+	if err != nil {
+		t.Fatalf("ListTrails error: %v", err)
+	}
+	if len(page.Trails) != 2 || page.Trails[0].ID != ids[3] || page.Trails[1].ID != ids[1] {
+		t.Fatalf("expected u-2's trails, got %+v", page.Trails)
+	}
+
+	var got []string
+	q := audit.TrailQuery{TargetType: sw12.Type, TargetID: sw12.ID, Limit: 3}
+	for pages := 0; ; pages++ {
+		if pages > 2 {
+			t.Fatalf("pagination did not terminate")
+		}
+		page, err := svc.ListTrails(ctx, q)
+		if err != nil {
+			t.Fatalf("ListTrails error: %v", err)
+		}
+		for _, tr := range page.Trails {
+			got = append(got, tr.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	want := []string{ids[3], ids[2], ids[1], ids[0]}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d trails across pages, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("page order mismatch at %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestListTrailsRejectsBadCursor(t *testing.T) {
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{})
+
+	_, err := svc.ListTrails(context.Background(), audit.TrailQuery{Cursor: "not a cursor"})
+	if !errors.Is(err, audit.ErrBadCursor) {
+		t.Fatalf("expected ErrBadCursor, got %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Merkle trees follow RFC 6962 (Certificate Transparency): leaves are the
+// raw event hashes in ledger order, hashed with a 0x00 prefix, and interior
+// nodes are hashed with a 0x01 prefix. Proof hashes are hex encoded.
+
+// InclusionProof proves that an event is leaf LeafIndex of a tree of TreeSize.
+type InclusionProof struct {
+	EventID   string   `json:"event_id"`
+	EventHash string   `json:"event_hash"`
+	LeafIndex int64    `json:"leaf_index"`
+	TreeSize  int64    `json:"tree_size"`
+	Hashes    []string `json:"hashes"`
+}
+
+// ConsistencyProof proves that the tree of OldSize is a prefix of the tree
+// of NewSize, i.e. nothing was removed or rewritten in between.
+type ConsistencyProof struct {
+	OldSize int64    `json:"old_size"`
+	NewSize int64    `json:"new_size"`
+	Hashes  []string `json:"hashes"`
+}
+
+// VerifyInclusion checks p against the hex root hash of a tree of p.TreeSize.
+// It needs nothing but the proof and a trusted root, e.g. from a checkpoint.
+func VerifyInclusion(p InclusionProof, root string) error {
+	leaf, err := hex.DecodeString(p.EventHash)
+	if err != nil {
+		return fmt.Errorf("audit: malformed event hash: %w", err)
+	}
+	want, err := hex.DecodeString(root)
+	if err != nil {
+		return fmt.Errorf("audit: malformed root hash: %w", err)
+	}
+	path, err := decodeHashes(p.Hashes)
+	if err != nil {
+		return err
+	}
+
+	if p.LeafIndex < 0 || p.LeafIndex >= p.TreeSize {
+		return errors.New("audit: leaf index out of range")
+	}
+
+	fn, sn := p.LeafIndex, p.TreeSize-1
+	r := leafHash(leaf)
+	for _, h := range path {
+		if sn == 0 {
+			return errors.New("audit: inclusion proof too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(h, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, h)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return errors.New("audit: inclusion proof too short")
+	}
+	if !bytes.Equal(r, want) {
+		return errors.New("audit: inclusion proof does not match root")
+	}
+	return nil
+}
+
+// VerifyConsistency checks p against the hex root hashes of both trees.
+func VerifyConsistency(p ConsistencyProof, oldRoot, newRoot string) error {
+	first, err := hex.DecodeString(oldRoot)
+	if err != nil {
+		return fmt.Errorf("audit: malformed old root hash: %w", err)
+	}
+	second, err := hex.DecodeString(newRoot)
+	if err != nil {
+		return fmt.Errorf("audit: malformed new root hash: %w", err)
+	}
+	path, err := decodeHashes(p.Hashes)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case p.OldSize < 0 || p.OldSize > p.NewSize:
+		return errors.New("audit: invalid tree sizes")
+	case p.OldSize == p.NewSize:
+		if len(path) != 0 || !bytes.Equal(first, second) {
+			return errors.New("audit: consistency proof does not match roots")
+		}
+		return nil
+	case p.OldSize == 0:
+		// The empty tree is a prefix of every tree.
+		return nil
+	case len(path) == 0:
+		return errors.New("audit: consistency proof is empty")
+	}
+
+	if p.OldSize&(p.OldSize-1) == 0 {
+		path = append([][]byte{first}, path...)
+	}
+
+	fn, sn := p.OldSize-1, p.NewSize-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := path[0], path[0]
+	for _, c := range path[1:] {
+		if sn == 0 {
+			return errors.New("audit: consistency proof too long")
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(fr, first) || !bytes.Equal(sr, second) {
+		return errors.New("audit: consistency proof does not match roots")
+	}
+	return nil
+}
+
+// MerkleRoot returns the hex root hash of the tree over the given hex
+// event hashes.
+func MerkleRoot(eventHashes []string) (string, error) {
+	leaves, err := decodeHashes(eventHashes)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(treeHash(leaves)), nil
+}
+
+func leafHash(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// splitPoint is the largest power of two smaller than n (n > 1).
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// treeHash is MTH from RFC 6962, section 2.1.
+func treeHash(leaves [][]byte) []byte {
+	switch n := len(leaves); n {
+	case 0:
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	case 1:
+		return leafHash(leaves[0])
+	default:
+		k := splitPoint(n)
+		return nodeHash(treeHash(leaves[:k]), treeHash(leaves[k:]))
+	}
+}
+
+// inclusionPath is PATH from RFC 6962, section 2.1.1.
+func inclusionPath(m int, leaves [][]byte) [][]byte {
+	n := len(leaves)
+	if n <= 1 {
+		return nil
+	}
+	k := splitPoint(n)
+	if m < k {
+		return append(inclusionPath(m, leaves[:k]), treeHash(leaves[k:]))
+	}
+	return append(inclusionPath(m-k, leaves[k:]), treeHash(leaves[:k]))
+}
+
+// consistencyPath is PROOF from RFC 6962, section 2.1.2.
+func consistencyPath(m int, leaves [][]byte) [][]byte {
+	return subproof(m, leaves, true)
+}
+
+func subproof(m int, leaves [][]byte, complete bool) [][]byte {
+	n := len(leaves)
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][]byte{treeHash(leaves)}
+	}
+	k := splitPoint(n)
+	if m <= k {
+		return append(subproof(m, leaves[:k], complete), treeHash(leaves[k:]))
+	}
+	return append(subproof(m-k, leaves[k:], false), treeHash(leaves[:k]))
+}
+
+func decodeHashes(hs []string) ([][]byte, error) {
+	out := make([][]byte, 0, len(hs))
+	for _, h := range hs {
+		b, err := hex.DecodeString(h)
+		if err != nil {
+			return nil, fmt.Errorf("audit: malformed hash %q: %w", short(h), err)
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+func encodeHashes(hs [][]byte) []string {
+	out := make([]string, 0, len(hs))
+	for _, h := range hs {
+		out = append(out, hex.EncodeToString(h))
+	}
+	return out
+}
@@ -0,0 +1,158 @@
+package audit_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestMerkleProofsForAllTreeSizes(t *testing.T) {
+	var hashes []string
+	for i := 0; i < 17; i++ {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("event-%d", i)))
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+
+	ctx := context.Background()
+	for n := 1; n <= len(hashes); n++ {
+		root, err := audit.MerkleRoot(hashes[:n])
+		if err != nil {
+			t.Fatalf("MerkleRoot error: %v", err)
+		}
+
+		svc, ids := serviceWithLedger(t, hashes[:n])
+		for i, id := range ids {
+			p, err := svc.InclusionProof(ctx, id, int64(n))
+			if err != nil {
+				t.Fatalf("InclusionProof(%d, %d) error: %v", i, n, err)
+			}
+			if err := audit.VerifyInclusion(p, root); err != nil {
+				t.Fatalf("VerifyInclusion(%d, %d) error: %v", i, n, err)
+			}
+			p.LeafIndex = (p.LeafIndex + 1) % int64(n)
+			if n > 1 && audit.VerifyInclusion(p, root) == nil {
+				t.Fatalf("VerifyInclusion(%d, %d) accepted wrong leaf index", i, n)
+			}
+		}
+
+		for m := 1; m <= n; m++ {
+			oldRoot, err := audit.MerkleRoot(hashes[:m])
+			if err != nil {
+				t.Fatalf("MerkleRoot error: %v", err)
+			}
+			p, err := svc.ConsistencyProof(ctx, int64(m), int64(n))
+			if err != nil {
+				t.Fatalf("ConsistencyProof(%d, %d) error: %v", m, n, err)
+			}
+			if err := audit.VerifyConsistency(p, oldRoot, root); err != nil {
+				t.Fatalf("VerifyConsistency(%d, %d) error: %v", m, n, err)
+			}
+			if m < n && audit.VerifyConsistency(p, root, root) == nil {
+				t.Fatalf("VerifyConsistency(%d, %d) accepted wrong old root", m, n)
+			}
+		}
+	}
+}
+
+func TestSignedCheckpoint(t *testing.T) {
+	ctx := context.Background()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey error: %v", err)
+	}
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{}, audit.WithSigner(audit.NewEd25519Signer("k-1", priv)))
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Checkpointed change",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	first, err := svc.Checkpoint(ctx)
+	if err != nil {
+		t.Fatalf("Checkpoint error: %v", err)
+	}
+
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	second, err := svc.Checkpoint(ctx)
+	if err != nil {
+		t.Fatalf("Checkpoint error: %v", err)
+	}
+	if first.TreeSize != 1 || second.TreeSize != 2 {
+		t.Fatalf("expected tree sizes 1 and 2, got %d and %d", first.TreeSize, second.TreeSize)
+	}
+
+	for _, c := range []audit.Checkpoint{first, second} {
+		if err := audit.VerifyCheckpoint(c, pub); err != nil {
+			t.Fatalf("VerifyCheckpoint error: %v", err)
+		}
+	}
+	forged := second
+	forged.TreeSize = 3
+	if audit.VerifyCheckpoint(forged, pub) == nil {
+		t.Fatalf("expected forged checkpoint to fail verification")
+	}
+
+	// An auditor holding only the checkpoints and proofs can check them offline.
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	incl, err := svc.InclusionProof(ctx, events[0].ID, second.TreeSize)
+	if err != nil {
+		t.Fatalf("InclusionProof error: %v", err)
+	}
+	if err := audit.VerifyInclusion(incl, second.RootHash); err != nil {
+		t.Fatalf("VerifyInclusion error: %v", err)
+	}
+	cons, err := svc.ConsistencyProof(ctx, first.TreeSize, second.TreeSize)
+	if err != nil {
+		t.Fatalf("ConsistencyProof error: %v", err)
+	}
+	if err := audit.VerifyConsistency(cons, first.RootHash, second.RootHash); err != nil {
+		t.Fatalf("VerifyConsistency error: %v", err)
+	}
+
+	// No new events: the latest checkpoint is reused.
+	again, err := svc.Checkpoint(ctx)
+	if err != nil {
+		t.Fatalf("Checkpoint error: %v", err)
+	}
+	if again != second {
+		t.Fatalf("expected unchanged ledger to reuse the latest checkpoint")
+	}
+}
+
+// serviceWithLedger returns a service whose ledger has exactly the given
+// event hashes, and the IDs of those events.
+func serviceWithLedger(t *testing.T, hashes []string) (*audit.Service, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	st := memory.New()
+	if err := st.CreateTrail(ctx, audit.Trail{ID: "t-1"}); err != nil {
+		t.Fatalf("CreateTrail error: %v", err)
+	}
+	var ids []string
+	for i, h := range hashes {
+		id := fmt.Sprintf("e-%d", i)
+		if err := st.AppendEvent(ctx, audit.Event{ID: id, TrailID: "t-1", Hash: h}); err != nil {
+			t.Fatalf("AppendEvent error: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return audit.NewService(st, audit.NoopSanitizer{}), ids
+}
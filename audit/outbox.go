@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultSink is the sink a store records deliveries for when its outbox is
+// enabled without naming any, and the name webhook.Sink claims them under.
+const DefaultSink = "default"
+
+// ErrUnknownSink is returned for a sink the store records no deliveries for.
+var ErrUnknownSink = errors.New("audit: unknown outbox sink")
+
+// Delivery is one pending notification of an appended event to one sink.
+type Delivery struct {
+	ID        int64
+	Sink      string
+	Event     Event
+	Attempts  int       // failed attempts so far
+	NextAt    time.Time // not retried before this time
+	LastError string
+	Dead      bool // given up on; see Outbox.DeadLetter
+}
+
+// Outbox is implemented by stores that record a Delivery for every appended
+// event and every configured sink in the same transaction as the event, so a
+// crash between append and notification never loses the notification, and
+// each sink receives every event however the others fare. Deliveries are at
+// least once; receivers should deduplicate by Event.ID.
+type Outbox interface {
+	// ClaimDeliveries leases up to limit of sink's deliveries with
+	// NextAt <= now, oldest first. A claimed delivery is not claimed again
+	// before now+lease, so flushers of the same sink in several processes
+	// each get different deliveries. limit <= 0 means no limit.
+	ClaimDeliveries(ctx context.Context, sink string, now time.Time, lease time.Duration, limit int) ([]Delivery, error)
+	// AckDelivery removes a delivered entry.
+	AckDelivery(ctx context.Context, id int64) error
+	// RetryDelivery records a failed attempt, releases the lease and sets
+	// when to try again.
+	RetryDelivery(ctx context.Context, id int64, next time.Time, lastErr string) error
+	// DeadLetter records a final failed attempt. The delivery is never
+	// claimed again but stays listed by Deliveries with Dead set, until an
+	// operator removes or requeues it.
+	DeadLetter(ctx context.Context, id int64, lastErr string) error
+	// Deliveries lists up to limit of sink's remaining deliveries, due or
+	// not and dead or not, oldest first, without claiming them. limit <= 0
+	// means no limit.
+	Deliveries(ctx context.Context, sink string, limit int) ([]Delivery, error)
+}
@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policy decides whether an event may be appended to a trail, given the trail
+// header and the events already on it. Policies run after the lifecycle check
+// and before the event is hashed; returning an error rejects the event.
+type Policy interface {
+	Evaluate(trail Trail, events []Event, next Event) error
+}
+
+// ActorMetaPolicy is implemented by policies that read Actor.Meta. The
+// Service keeps the keys they name out of sanitization, so a policy sees the
+// values the caller gave both when it judges a new event and when it reads
+// that event back as history; a redaction rule cannot change its decision.
+type ActorMetaPolicy interface {
+	Policy
+	// ActorMetaKeys names the keys the policy reads. Their values are stored
+	// as given, so no sanitizer, including a redactor, can scrub a secret
+	// placed in one: return only keys the policy actually reads.
+	ActorMetaKeys() []string
+}
+
+// PolicyFunc adapts an ordinary function to the Policy interface.
+type PolicyFunc func(trail Trail, events []Event, next Event) error
+
+func (f PolicyFunc) Evaluate(trail Trail, events []Event, next Event) error {
+	return f(trail, events, next)
+}
+
+// PolicyError is returned when a policy rejects an event.
+type PolicyError struct {
+	TrailID string
+	Type    EventType
+	ActorID string
+	Reason  string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("audit: policy rejected event: trail=%s type=%s actor=%s reason=%s",
+		e.TrailID, e.Type, e.ActorID, e.Reason,
+	)
+}
+
+// DefaultGroupKey is the Actor.Meta key ApprovalPolicy reads groups from.
+const DefaultGroupKey = "group"
+
+// ApprovalPolicy enforces an N-of-M approval quorum and separation of duties.
+type ApprovalPolicy struct {
+	// Quorum is the number of distinct eligible approvers required before a
+	// change may be executed. Actor IDs are compared without surrounding
+	// whitespace, and actors without an ID never count.
+	Quorum int
+
+	// Approvers and Groups define who is eligible to approve. An actor is
+	// eligible if its ID is in Approvers or its group is in Groups.
+	// If both are empty, any actor is eligible.
+	Approvers []string
+	Groups    []string
+
+	// GroupKey is the Actor.Meta key holding the actor's group.
+	// Defaults to DefaultGroupKey. When Groups is set, its value is stored
+	// unsanitized; see ActorMetaPolicy.
+	GroupKey string
+
+	// SeparateDuties forbids the requester from approving, executing or
+	// rolling back their own change, and an approver from executing or
+	// rolling back a change they approved.
+	SeparateDuties bool
+}
+
+func (p ApprovalPolicy) Evaluate(trail Trail, events []Event, next Event) error {
+	actor := actorID(next.Actor)
+
+	switch next.Type {
+	case EventApproved:
+		if actor == "" {
+			return p.reject(trail, next, "approver has no actor id")
+		}
+		if !p.eligible(next.Actor) {
+			return p.reject(trail, next, "actor is not an eligible approver")
+		}
+		for _, e := range events {
+			if actorID(e.Actor) != actor {
+				continue
+			}
+			if e.Type == EventApproved {
+				return p.reject(trail, next, "actor has already approved this change")
+			}
+			if p.SeparateDuties && e.Type == EventRequested {
+				return p.reject(trail, next, "requester cannot approve their own change")
+			}
+		}
+
+	case EventExecuted, EventRolledBack:
+		verb := "execute"
+		if next.Type == EventRolledBack {
+			verb = "roll back"
+		}
+		if p.SeparateDuties && actor == "" {
+			return p.reject(trail, next, "executor has no actor id")
+		}
+
+		approvers := make(map[string]bool)
+		for _, e := range events {
+			id := actorID(e.Actor)
+			switch e.Type {
+			case EventRequested:
+				if p.SeparateDuties && id == actor {
+					return p.reject(trail, next, "requester cannot "+verb+" their own change")
+				}
+			case EventApproved:
+				if p.SeparateDuties && id == actor {
+					return p.reject(trail, next, "approver cannot "+verb+" a change they approved")
+				}
+				if id != "" && p.eligible(e.Actor) {
+					approvers[id] = true
+				}
+			}
+		}
+		if next.Type == EventExecuted && len(approvers) < p.Quorum {
+			return p.reject(trail, next, fmt.Sprintf("approval quorum not reached (%d of %d)", len(approvers), p.Quorum))
+		}
+	}
+	return nil
+}
+
+// actorID is the ID policies compare actors by.
+func actorID(a Actor) string {
+	return strings.TrimSpace(a.ID)
+}
+
+// ActorMetaKeys implements ActorMetaPolicy: the group key is policy input,
+// not free text, when Groups makes the policy read it.
+func (p ApprovalPolicy) ActorMetaKeys() []string {
+	if len(p.Groups) == 0 {
+		return nil
+	}
+	return []string{p.groupKey()}
+}
+
+func (p ApprovalPolicy) groupKey() string {
+	if p.GroupKey == "" {
+		return DefaultGroupKey
+	}
+	return p.GroupKey
+}
+
+func (p ApprovalPolicy) eligible(a Actor) bool {
+	if len(p.Approvers) == 0 && len(p.Groups) == 0 {
+		return true
+	}
+	for _, id := range p.Approvers {
+		if actorID(a) == strings.TrimSpace(id) {
+			return true
+		}
+	}
+
+	group, ok := a.Meta[p.groupKey()]
+	if !ok {
+		return false
+	}
+	for _, g := range p.Groups {
+		if group == g {
+			return true
+		}
+	}
+	return false
+}
+
+func (p ApprovalPolicy) reject(trail Trail, next Event, reason string) error {
+	return &PolicyError{
+		TrailID: trail.ID,
+		Type:    next.Type,
+		ActorID: next.Actor.ID,
+		Reason:  reason,
+	}
+}
@@ -0,0 +1,271 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestApprovalPolicyRequiresQuorumBeforeExecute(t *testing.T) {
+	ctx := context.Background()
+
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{}, audit.WithPolicy(audit.ApprovalPolicy{
+		Quorum: 2,
+		Groups: []string{"cab"},
+	}))
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Quorum test",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	cab := func(id string) audit.Actor {
+		return audit.Actor{ID: id, Meta: map[string]string{"group": "cab"}}
+	}
+	executor := audit.Actor{ID: "svc-1"}
+	res := audit.Result{Status: "SUCCESS"}
+
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-9", Meta: map[string]string{"group": "dev"}}, "", ""); err == nil {
+		t.Fatalf("expected approval from ineligible group to be rejected")
+	}
+	if err := svc.Approve(ctx, trailID, cab("u-2"), "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, cab("u-2"), "", ""); err == nil {
+		t.Fatalf("expected duplicate approval to be rejected")
+	}
+
+	err = svc.Execute(ctx, trailID, executor, "", nil, res)
+	var perr *audit.PolicyError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected PolicyError before quorum, got %v", err)
+	}
+
+	if err := svc.Approve(ctx, trailID, cab("u-3"), "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, executor, "", nil, res); err != nil {
+		t.Fatalf("Execute after quorum error: %v", err)
+	}
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail should pass, got error: %v", err)
+	}
+}
+
+func TestApprovalPolicySeparatesDuties(t *testing.T) {
+	ctx := context.Background()
+
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{}, audit.WithPolicy(audit.ApprovalPolicy{
+		Quorum:         1,
+		SeparateDuties: true,
+	}))
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "SoD test",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-1"}, "", ""); err == nil {
+		t.Fatalf("expected requester self-approval to be rejected")
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	for _, id := range []string{"u-1", "u-2"} {
+		if err := svc.Execute(ctx, trailID, audit.Actor{ID: id}, "", nil, audit.Result{Status: "SUCCESS"}); err == nil {
+			t.Fatalf("expected execution by %s to be rejected", id)
+		}
+	}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "", nil, audit.Result{Status: "SUCCESS"}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+}
+
+func TestApprovalPolicySeparatesDutiesOnRollback(t *testing.T) {
+	ctx := context.Background()
+
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{}, audit.WithPolicy(audit.ApprovalPolicy{
+		Quorum:         1,
+		SeparateDuties: true,
+	}))
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "SoD rollback", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "", nil, audit.Result{Status: "SUCCESS"}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+
+	var perr *audit.PolicyError
+	for _, id := range []string{"u-1", "u-2"} {
+		err := svc.Rollback(ctx, trailID, audit.Actor{ID: id}, "", nil, audit.Result{Status: "SUCCESS"}, nil)
+		if !errors.As(err, &perr) {
+			t.Fatalf("expected rollback by %s to be rejected, got %v", id, err)
+		}
+	}
+	if err := svc.Rollback(ctx, trailID, audit.Actor{ID: "svc-1"}, "", nil, audit.Result{Status: "SUCCESS"}, nil); err != nil {
+		t.Fatalf("Rollback error: %v", err)
+	}
+}
+
+// Policies also see events written by other services or older versions,
+// so they normalize actor IDs themselves.
+func TestApprovalPolicyNormalizesActorIDs(t *testing.T) {
+	p := audit.ApprovalPolicy{Quorum: 2, Approvers: []string{"u-2 ", "u-3"}, SeparateDuties: true}
+	trail := audit.Trail{ID: "t-1"}
+	event := func(typ audit.EventType, id string) audit.Event {
+		return audit.Event{TrailID: trail.ID, Type: typ, Actor: audit.Actor{ID: id}}
+	}
+	requested := event(audit.EventRequested, "u-1")
+
+	rejects := map[string]struct {
+		events []audit.Event
+		next   audit.Event
+	}{
+		"blank approver":           {[]audit.Event{requested}, event(audit.EventApproved, "  ")},
+		"requester with padding":   {[]audit.Event{requested}, event(audit.EventApproved, " u-1")},
+		"repeat with padding":      {[]audit.Event{requested, event(audit.EventApproved, "u-2")}, event(audit.EventApproved, "u-2\t")},
+		"blank executor":           {[]audit.Event{requested, event(audit.EventApproved, "u-2"), event(audit.EventApproved, "u-3")}, event(audit.EventExecuted, "")},
+		"approver with padding":    {[]audit.Event{requested, event(audit.EventApproved, "u-2"), event(audit.EventApproved, "u-3")}, event(audit.EventExecuted, "u-3 ")},
+		"one approver counted two": {[]audit.Event{requested, event(audit.EventApproved, "u-2"), event(audit.EventApproved, " u-2")}, event(audit.EventExecuted, "svc-1")},
+	}
+	for name, tc := range rejects {
+		var perr *audit.PolicyError
+		if err := p.Evaluate(trail, tc.events, tc.next); !errors.As(err, &perr) {
+			t.Fatalf("%s: expected PolicyError, got %v", name, err)
+		}
+	}
+
+	// Without an approver list anyone is eligible, but not a blank ID.
+	var perr *audit.PolicyError
+	open := audit.ApprovalPolicy{Quorum: 1}
+	if err := open.Evaluate(trail, []audit.Event{requested, event(audit.EventApproved, " ")}, event(audit.EventExecuted, "svc-1")); !errors.As(err, &perr) {
+		t.Fatalf("blank approval counted towards the quorum: %v", err)
+	}
+
+	ok := []audit.Event{requested, event(audit.EventApproved, " u-2"), event(audit.EventApproved, "u-3")}
+	if err := p.Evaluate(trail, ok, event(audit.EventExecuted, "svc-1")); err != nil {
+		t.Fatalf("Evaluate with quorum error: %v", err)
+	}
+}
+
+func TestRejectedRequestLeavesNoTrail(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+
+	svc := audit.NewService(st, nil, audit.WithPolicy(audit.PolicyFunc(func(trail audit.Trail, events []audit.Event, next audit.Event) error {
+		if next.Type == audit.EventRequested && len(trail.Targets) == 0 {
+			return &audit.PolicyError{TrailID: trail.ID, Type: next.Type, Reason: "a change needs a target"}
+		}
+		return nil
+	})))
+
+	var perr *audit.PolicyError
+	if _, err := svc.Request(ctx, audit.RequestInput{Title: "No target", Requester: audit.Actor{ID: "u-1"}}); !errors.As(err, &perr) {
+		t.Fatalf("expected PolicyError, got %v", err)
+	}
+	page, err := st.ListTrails(ctx, audit.TrailQuery{})
+	if err != nil {
+		t.Fatalf("ListTrails error: %v", err)
+	}
+	if len(page.Trails) != 0 {
+		t.Fatalf("rejected request left trails behind: %+v", page.Trails)
+	}
+
+	if _, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "With target",
+		Requester: audit.Actor{ID: "u-1"},
+		Targets:   []audit.Target{{Type: "network_device", ID: "sw-12"}},
+	}); err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+}
+
+// A sanitizer must not change who is eligible: ApprovalPolicy's group key is
+// kept as given, while the rest of Actor.Meta is still sanitized.
+func TestApprovalPolicyIgnoresSanitizedGroup(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	redactMeta := audit.TextSanitizer(func(field, text string) string {
+		if strings.HasPrefix(field, "actor.meta.") {
+			return "cab" // would make anyone eligible if policies saw it
+		}
+		return text
+	})
+	svc := audit.NewService(st, redactMeta, audit.WithPolicy(audit.ApprovalPolicy{
+		Quorum: 1,
+		Groups: []string{"cab"},
+	}))
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "Sanitized groups", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-9", Meta: map[string]string{"group": "dev"}}, "", ""); err == nil {
+		t.Fatalf("expected an approval from dev to be rejected")
+	}
+	meta := map[string]string{"group": "cab", "ip": "10.0.0.7"}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2", Meta: meta}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "", nil, audit.Result{Status: "SUCCESS"}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	if got := events[1].Actor.Meta; got["group"] != "cab" || got["ip"] != "cab" {
+		t.Fatalf("expected only the group to skip sanitization, got %v", got)
+	}
+	if meta["ip"] != "10.0.0.7" {
+		t.Fatalf("caller's meta was modified: %v", meta)
+	}
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+}
+
+func TestApprovalPolicyWithoutGroupsSanitizesGroup(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	redactMeta := audit.TextSanitizer(func(field, text string) string {
+		if strings.HasPrefix(field, "actor.meta.") {
+			return "[redacted]"
+		}
+		return text
+	})
+	svc := audit.NewService(st, redactMeta, audit.WithPolicy(audit.ApprovalPolicy{Quorum: 1}))
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "No groups", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2", Meta: map[string]string{"group": "token=s3cret"}}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	if got := events[1].Actor.Meta["group"]; got != "[redacted]" {
+		t.Fatalf("expected the group to be sanitized when the policy does not read it, got %q", got)
+	}
+}
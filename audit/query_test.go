@@ -0,0 +1,136 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestQueryEventsPagesInLedgerOrder(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	sw12 := audit.Target{Type: "network_device", ID: "sw-12"}
+	for i := 0; i < 3; i++ {
+		trailID, err := svc.Request(ctx, audit.RequestInput{
+			Title:     "Update NTP",
+			Requester: audit.Actor{ID: "u-1"},
+			Targets:   []audit.Target{sw12},
+		})
+		if err != nil {
+			t.Fatalf("Request error: %v", err)
+		}
+		if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+			t.Fatalf("Approve error: %v", err)
+		}
+	}
+
+	for _, order := range []audit.Order{audit.OrderAsc, audit.OrderDesc} {
+		var seqs []int64
+		q := audit.Query{TargetType: sw12.Type, TargetID: sw12.ID, Limit: 4, Order: order}
+		for pages := 0; ; pages++ {
+			if pages > 2 {
+				t.Fatalf("%s: pagination did not terminate", order)
+			}
+			page, err := svc.QueryEvents(ctx, q)
+			if err != nil {
+				t.Fatalf("QueryEvents error: %v", err)
+			}
+			for _, e := range page.Events {
+				seqs = append(seqs, e.Seq)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+
+		if len(seqs) != 6 {
+			t.Fatalf("%s: expected 6 events across pages, got %d", order, len(seqs))
+		}
+		for i := 1; i < len(seqs); i++ {
+			if order == audit.OrderAsc && seqs[i] <= seqs[i-1] || order == audit.OrderDesc && seqs[i] >= seqs[i-1] {
+				t.Fatalf("%s: events out of order: %v", order, seqs)
+			}
+		}
+	}
+}
+
+func TestQueryEventsRejectsBadCursor(t *testing.T) {
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{})
+
+	_, err := svc.QueryEvents(context.Background(), audit.Query{Cursor: "bm9wZQ"})
+	if !errors.Is(err, audit.ErrBadCursor) {
+		t.Fatalf("expected ErrBadCursor, got %v", err)
+	}
+}
+
+func TestQueryEventsFilters(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	sw12 := audit.Target{Type: "network_device", ID: "sw-12", Labels: map[string]string{"site": "dc1", "vendor": "cisco"}}
+	sw13 := audit.Target{Type: "network_device", ID: "sw-13", Labels: map[string]string{"site": "dc2", "vendor": "cisco"}}
+
+	change := func(target audit.Target, corr, status string) string {
+		t.Helper()
+		trailID, err := svc.Request(ctx, audit.RequestInput{
+			Title:         "Update NTP",
+			CorrelationID: corr,
+			Requester:     audit.Actor{ID: "u-1"},
+			Targets:       []audit.Target{target},
+		})
+		if err != nil {
+			t.Fatalf("Request error: %v", err)
+		}
+		if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, corr, "ok"); err != nil {
+			t.Fatalf("Approve error: %v", err)
+		}
+		if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-spectre"}, corr,
+			[]audit.Command{{Kind: "cli", Raw: "ntp server 10.0.0.1"}},
+			audit.Result{Status: status},
+		); err != nil {
+			t.Fatalf("Execute error: %v", err)
+		}
+		return trailID
+	}
+	first := change(sw12, "corr-1", "FAILED")
+	second := change(sw13, "corr-2", "SUCCESS")
+
+	cases := []struct {
+		name  string
+		q     audit.Query
+		count int
+	}{
+		{"actor and result status", audit.Query{ActorID: "svc-spectre", ResultStatus: "FAILED"}, 1},
+		{"actor role", audit.Query{ActorRole: audit.RoleApprover}, 2},
+		{"correlation id", audit.Query{CorrelationID: "corr-2"}, 3},
+		{"trail ids", audit.Query{TrailIDs: []string{first, second}, EventTypes: []audit.EventType{audit.EventExecuted}}, 2},
+		{"any of several targets", audit.Query{Targets: []audit.Target{{Type: "network_device", ID: "sw-12"}, {Type: "network_device", ID: "sw-13"}}}, 6},
+		{"label selector", audit.Query{TargetLabels: map[string]string{"site": "dc1"}}, 3},
+		{"labels on one target", audit.Query{TargetLabels: map[string]string{"site": "dc2", "vendor": "cisco"}}, 3},
+		{"label mismatch", audit.Query{TargetLabels: map[string]string{"site": "dc1", "vendor": "juniper"}}, 0},
+		{"combined", audit.Query{TargetLabels: map[string]string{"vendor": "cisco"}, ResultStatus: "SUCCESS"}, 1},
+	}
+	for _, tc := range cases {
+		page, err := svc.QueryEvents(ctx, tc.q)
+		if err != nil {
+			t.Fatalf("%s: QueryEvents error: %v", tc.name, err)
+		}
+		if len(page.Events) != tc.count {
+			t.Fatalf("%s: expected %d events, got %d", tc.name, tc.count, len(page.Events))
+		}
+		for _, e := range page.Events {
+			if !tc.q.Matches(e) {
+				t.Fatalf("%s: returned event %s does not match the query", tc.name, e.ID)
+			}
+		}
+	}
+}
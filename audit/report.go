@@ -0,0 +1,210 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// VerifyReport is the result of checking a whole trail without stopping at
+// the first failure, for investigating a corrupted database.
+type VerifyReport struct {
+	TrailID string
+	Events  int // events checked
+
+	// Failures lists every failed check in chain order. An event can fail
+	// more than one check.
+	Failures []*VerifyError
+
+	HashMismatches    int
+	ChainBreaks       int // PrevHash mismatches
+	SignatureFailures int
+	HeaderMismatches  int
+	BadEvents         int // events with at least one failure
+	FirstBadIndex     int // -1 if every event verifies
+	FirstGoodIndex    int // -1 if no event verifies
+	LastGoodIndex     int // -1 if no event verifies
+	LastTrustedIndex  int // last index of the unbroken prefix; -1 if event 0 fails
+}
+
+// OK reports whether the trail verified completely.
+func (r *VerifyReport) OK() bool { return len(r.Failures) == 0 }
+
+// VerifyTrailReport runs the same checks as VerifyTrail on every event of
+// the trail and reports all failures.
+func (s *Service) VerifyTrailReport(ctx context.Context, trailID string) (*VerifyReport, error) {
+	trail, events, err := s.store.GetTrail(ctx, trailID)
+	if err != nil {
+		return nil, err
+	}
+
+	failures, err := s.checkEvents(ctx, trailID, trail, events, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		failures = append(failures, noEvents(trailID))
+	}
+
+	r := &VerifyReport{
+		TrailID:          trailID,
+		Events:           len(events),
+		Failures:         failures,
+		FirstBadIndex:    -1,
+		FirstGoodIndex:   -1,
+		LastGoodIndex:    -1,
+		LastTrustedIndex: len(events) - 1,
+	}
+
+	bad := make(map[int]bool)
+	for _, f := range failures {
+		bad[f.Index] = true
+		switch {
+		case errors.Is(f, ErrHashMismatch):
+			r.HashMismatches++
+		case errors.Is(f, ErrChainBroken):
+			r.ChainBreaks++
+		case errors.Is(f, ErrBadSignature):
+			r.SignatureFailures++
+		case errors.Is(f, ErrTrailHeaderMismatch):
+			r.HeaderMismatches++
+		}
+	}
+	r.BadEvents = len(bad)
+
+	for i := range events {
+		if bad[i] {
+			if r.FirstBadIndex < 0 {
+				r.FirstBadIndex = i
+				r.LastTrustedIndex = i - 1
+			}
+			continue
+		}
+		if r.FirstGoodIndex < 0 {
+			r.FirstGoodIndex = i
+		}
+		r.LastGoodIndex = i
+	}
+
+	return r, nil
+}
+
+// VerifyResult is one trail's outcome from VerifyRange or VerifyAll.
+// Err is set if the trail could not be checked at all.
+type VerifyResult struct {
+	TrailID string
+	Report  *VerifyReport
+	Err     error
+}
+
+// verifyPageSize is how many trails or events VerifyAll and VerifyRange
+// read per page, which bounds their memory use.
+const verifyPageSize = 256
+
+// VerifyAll verifies every trail in the store, including trails without
+// events, using at most workers concurrent checks. Trails are listed page by
+// page with ListTrails. Results are streamed as trails finish, and the
+// channel is closed when all trails are done or ctx is cancelled.
+func (s *Service) VerifyAll(ctx context.Context, workers int) <-chan VerifyResult {
+	return s.verifyTrails(ctx, workers, func(yield func(trailID string) bool) error {
+		q := TrailQuery{Limit: verifyPageSize}
+		for {
+			page, err := s.store.ListTrails(ctx, q)
+			if err != nil {
+				return err
+			}
+			for _, t := range page.Trails {
+				if !yield(t.ID) {
+					return nil
+				}
+			}
+			if page.NextCursor == "" {
+				return nil
+			}
+			q.Cursor = page.NextCursor
+		}
+	})
+}
+
+// VerifyRange verifies every trail with an event whose ledger Seq is between
+// fromSeq and toSeq (toSeq <= 0 means up to the latest event), like
+// VerifyAll. Events are read a page at a time in ledger order.
+func (s *Service) VerifyRange(ctx context.Context, fromSeq, toSeq int64, workers int) <-chan VerifyResult {
+	return s.verifyTrails(ctx, workers, func(yield func(trailID string) bool) error {
+		q := Query{Order: OrderAsc, Limit: verifyPageSize}
+		if fromSeq > 1 {
+			q.Cursor = EncodeEventCursor(EventCursor{Seq: fromSeq - 1})
+		}
+		seen := make(map[string]bool)
+		for {
+			page, err := s.store.QueryEvents(ctx, q)
+			if err != nil {
+				return err
+			}
+			for _, e := range page.Events {
+				if toSeq > 0 && e.Seq > toSeq {
+					return nil
+				}
+				if seen[e.TrailID] {
+					continue
+				}
+				seen[e.TrailID] = true
+				if !yield(e.TrailID) {
+					return nil
+				}
+			}
+			if page.NextCursor == "" {
+				return nil
+			}
+			q.Cursor = page.NextCursor
+		}
+	})
+}
+
+// verifyTrails runs VerifyTrailReport on every trail list yields, with
+// workers concurrent checks. An error from list is sent as a result without
+// a trail ID.
+func (s *Service) verifyTrails(ctx context.Context, workers int, list func(yield func(trailID string) bool) error) <-chan VerifyResult {
+	if workers < 1 {
+		workers = 1
+	}
+	out := make(chan VerifyResult)
+
+	go func() {
+		defer close(out)
+
+		trailIDs := make(chan string)
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for id := range trailIDs {
+					r, err := s.VerifyTrailReport(ctx, id)
+					if !send(ctx, out, VerifyResult{TrailID: id, Report: r, Err: err}) {
+						return
+					}
+				}
+			}()
+		}
+
+		err := list(func(id string) bool { return send(ctx, trailIDs, id) })
+		close(trailIDs)
+		wg.Wait()
+		if err != nil {
+			send(ctx, out, VerifyResult{Err: err})
+		}
+	}()
+
+	return out
+}
+
+// send delivers v unless ctx is cancelled first.
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,183 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestVerifyTrailReportFindsEveryBrokenLink(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+	trailID := requestApproveExecute(t, svc, "Report test")
+
+	corrupt := &corruptingStore{Store: st, mutate: func(evs []audit.Event) {
+		evs[1].CorrelationID = "edited"  // hash mismatch at 1
+		evs[3].PrevHash = "0123456789ab" // chain break at 3
+	}}
+	r, err := audit.NewService(corrupt, audit.NoopSanitizer{}).VerifyTrailReport(ctx, trailID)
+	if err != nil {
+		t.Fatalf("VerifyTrailReport error: %v", err)
+	}
+
+	if r.OK() {
+		t.Fatalf("expected report to contain failures")
+	}
+	if r.Events != 4 || r.BadEvents != 2 {
+		t.Fatalf("expected 2 of 4 bad events, got %d of %d", r.BadEvents, r.Events)
+	}
+	// Event 3's edited PrevHash is hashed too, so it also mismatches.
+	if r.HashMismatches != 2 || r.ChainBreaks != 1 {
+		t.Fatalf("expected 2 hash mismatches and 1 chain break, got %d and %d", r.HashMismatches, r.ChainBreaks)
+	}
+	if r.FirstBadIndex != 1 || r.LastTrustedIndex != 0 {
+		t.Fatalf("expected first bad index 1 and last trusted 0, got %d and %d", r.FirstBadIndex, r.LastTrustedIndex)
+	}
+	if r.FirstGoodIndex != 0 || r.LastGoodIndex != 2 {
+		t.Fatalf("expected good indexes 0..2, got %d..%d", r.FirstGoodIndex, r.LastGoodIndex)
+	}
+}
+
+func TestVerifyAllStreamsEveryTrail(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	var broken string
+	for i, title := range []string{"A", "B", "C", "D", "E"} {
+		id := requestApproveExecute(t, svc, title)
+		if i == 2 {
+			broken = id
+		}
+	}
+
+	corrupt := &corruptingStore{Store: st, trailID: broken, mutate: func(evs []audit.Event) {
+		evs[0].Actor.ID = "someone-else"
+	}}
+
+	seen := 0
+	for res := range audit.NewService(corrupt, audit.NoopSanitizer{}).VerifyAll(ctx, 2) {
+		if res.Err != nil {
+			t.Fatalf("VerifyAll error for %s: %v", res.TrailID, res.Err)
+		}
+		seen++
+		if ok := res.TrailID != broken; res.Report.OK() != ok {
+			t.Fatalf("trail %s: expected OK=%v, got failures %v", res.TrailID, ok, res.Report.Failures)
+		}
+	}
+	if seen != 5 {
+		t.Fatalf("expected 5 results, got %d", seen)
+	}
+}
+
+func requestApproveExecute(t *testing.T, svc *audit.Service, title string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     title,
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-3"}, "", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "",
+		[]audit.Command{{Kind: "cli", Raw: "do thing"}},
+		audit.Result{Status: "SUCCESS"},
+	); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	return trailID
+}
+
+// corruptingStore applies mutate to the events of trailID (or of every trail
+// if trailID is empty) when they are read back.
+type corruptingStore struct {
+	*memory.Store
+	trailID string
+	mutate  func([]audit.Event)
+}
+
+func (c *corruptingStore) GetTrail(ctx context.Context, trailID string) (audit.Trail, []audit.Event, error) {
+	tr, evs, err := c.Store.GetTrail(ctx, trailID)
+	if err != nil {
+		return audit.Trail{}, nil, err
+	}
+	if c.trailID == "" || c.trailID == trailID {
+		c.mutate(evs)
+	}
+	return tr, evs, nil
+}
+
+// ledgerless hides the memory store's Ledger and every other optional
+// interface.
+type ledgerless struct{ audit.Store }
+
+func TestVerifyAllListsTrailsWithoutALedger(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(ledgerless{st}, audit.NoopSanitizer{})
+
+	want := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		want[requestApproveExecute(t, svc, "trail")] = true
+	}
+	// A trail whose events were deleted is reported, not skipped.
+	if err := st.CreateTrail(ctx, audit.Trail{ID: "emptied", Title: "emptied"}); err != nil {
+		t.Fatalf("CreateTrail error: %v", err)
+	}
+
+	for res := range svc.VerifyAll(ctx, 2) {
+		if res.Err != nil {
+			t.Fatalf("VerifyAll error for %q: %v", res.TrailID, res.Err)
+		}
+		if res.TrailID == "emptied" {
+			if res.Report.OK() || !errors.Is(res.Report.Failures[0], audit.ErrChainBroken) {
+				t.Fatalf("trail without events: expected a chain break, got %+v", res.Report)
+			}
+			continue
+		}
+		if !want[res.TrailID] || !res.Report.OK() {
+			t.Fatalf("unexpected result %+v", res)
+		}
+		delete(want, res.TrailID)
+	}
+	if len(want) != 0 {
+		t.Fatalf("trails not verified: %v", want)
+	}
+}
+
+func TestVerifyRangeChecksTrailsInTheSeqRange(t *testing.T) {
+	ctx := context.Background()
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{})
+
+	// Four events per trail: seqs 1-4, 5-8 and 9-12.
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, requestApproveExecute(t, svc, "trail"))
+	}
+
+	var got []string
+	for res := range svc.VerifyRange(ctx, 6, 9, 1) {
+		if res.Err != nil || !res.Report.OK() {
+			t.Fatalf("VerifyRange result %+v", res)
+		}
+		got = append(got, res.TrailID)
+	}
+	if len(got) != 2 || got[0] != ids[1] || got[1] != ids[2] {
+		t.Fatalf("expected trails %v, got %v", ids[1:], got)
+	}
+}
@@ -1,5 +1,7 @@
 package audit
 
+import "strconv"
+
 // Sanitizer allows the host app to redact secrets.
 // Default is no-op.
 type Sanitizer interface {
@@ -7,7 +9,163 @@ type Sanitizer interface {
 	SanitizeCommands(cmds []Command) []Command
 }
 
+// EventSanitizer sees every free-text field, not just targets and commands.
+// When the sanitizer given to NewService implements it, the service passes
+// each trail header and every event through it before hashing; see
+// TextSanitizer for an easy way to implement it. Other sanitizers are
+// adapted with AdaptSanitizer.
+type EventSanitizer interface {
+	Sanitizer
+	SanitizeTrail(t Trail) Trail
+	SanitizeEvent(e Event) Event
+}
+
 type NoopSanitizer struct{}
 
 func (NoopSanitizer) SanitizeTargets(targets []Target) []Target { return targets }
 func (NoopSanitizer) SanitizeCommands(cmds []Command) []Command { return cmds }
+func (NoopSanitizer) SanitizeTrail(t Trail) Trail               { return t }
+func (NoopSanitizer) SanitizeEvent(e Event) Event               { return e }
+
+// AdaptSanitizer returns s as an EventSanitizer. A Sanitizer that only
+// implements the original two methods keeps its behaviour: trail targets and
+// event commands pass through it, other fields are left alone.
+func AdaptSanitizer(s Sanitizer) EventSanitizer {
+	if s == nil {
+		return NoopSanitizer{}
+	}
+	if es, ok := s.(EventSanitizer); ok {
+		return es
+	}
+	return legacySanitizer{s}
+}
+
+type legacySanitizer struct{ Sanitizer }
+
+func (l legacySanitizer) SanitizeTrail(t Trail) Trail {
+	t.Targets = l.SanitizeTargets(t.Targets)
+	return t
+}
+
+func (l legacySanitizer) SanitizeEvent(e Event) Event {
+	if e.Targets != nil {
+		e.Targets = l.SanitizeTargets(e.Targets)
+	}
+	if e.Commands != nil {
+		e.Commands = l.SanitizeCommands(e.Commands)
+	}
+	return e
+}
+
+// TextFunc redacts one free-text value. field is its JSON path within the
+// trail header or event, e.g. "description", "commands.0.output",
+// "result.message"; map values end with their key, e.g. "actor.meta.ip"
+// or "evidence.1.detail.password".
+type TextFunc func(field, text string) string
+
+// TextSanitizer is an EventSanitizer that applies f to every free-text
+// field: titles, descriptions, label and metadata values, actor names,
+// command text, output, diffs and output metadata, result messages and
+// evidence. IDs, types, statuses and hashes are not free text.
+func TextSanitizer(f TextFunc) EventSanitizer {
+	return textSanitizer(f)
+}
+
+type textSanitizer TextFunc
+
+func (f textSanitizer) SanitizeTrail(t Trail) Trail { return SanitizeTrailText(t, TextFunc(f)) }
+func (f textSanitizer) SanitizeEvent(e Event) Event { return SanitizeEventText(e, TextFunc(f)) }
+
+func (f textSanitizer) SanitizeTargets(ts []Target) []Target {
+	return sanitizeTargets("targets", ts, skipEmpty(TextFunc(f)))
+}
+
+func (f textSanitizer) SanitizeCommands(cmds []Command) []Command {
+	return sanitizeCommands("commands", cmds, skipEmpty(TextFunc(f)))
+}
+
+// SanitizeTrailText returns a copy of t with f applied to every free-text
+// field. t is not modified.
+func SanitizeTrailText(t Trail, f TextFunc) Trail {
+	f = skipEmpty(f)
+	t.Title = f("title", t.Title)
+	t.Description = f("description", t.Description)
+	t.Targets = sanitizeTargets("targets", t.Targets, f)
+	return t
+}
+
+// SanitizeEventText returns a copy of e with f applied to every free-text
+// field. e is not modified.
+func SanitizeEventText(e Event, f TextFunc) Event {
+	f = skipEmpty(f)
+	e.Actor.Name = f("actor.name", e.Actor.Name)
+	e.Actor.Meta = sanitizeMap("actor.meta", e.Actor.Meta, f)
+	e.Targets = sanitizeTargets("targets", e.Targets, f)
+	e.Commands = sanitizeCommands("commands", e.Commands, f)
+	if e.Result != nil {
+		r := *e.Result
+		r.Message = f("result.message", r.Message)
+		e.Result = &r
+	}
+	if e.Evidence != nil {
+		ev := make([]Evidence, len(e.Evidence))
+		for i, x := range e.Evidence {
+			p := "evidence." + strconv.Itoa(i)
+			x.Ref = f(p+".ref", x.Ref)
+			x.Detail = sanitizeMap(p+".detail", x.Detail, f)
+			ev[i] = x
+		}
+		e.Evidence = ev
+	}
+	return e
+}
+
+// skipEmpty keeps f from seeing, and possibly filling in, empty fields.
+func skipEmpty(f TextFunc) TextFunc {
+	return func(field, text string) string {
+		if text == "" {
+			return ""
+		}
+		return f(field, text)
+	}
+}
+
+func sanitizeTargets(path string, ts []Target, f TextFunc) []Target {
+	if ts == nil {
+		return nil
+	}
+	out := make([]Target, len(ts))
+	for i, t := range ts {
+		t.Labels = sanitizeMap(path+"."+strconv.Itoa(i)+".labels", t.Labels, f)
+		out[i] = t
+	}
+	return out
+}
+
+func sanitizeCommands(path string, cmds []Command, f TextFunc) []Command {
+	if cmds == nil {
+		return nil
+	}
+	out := make([]Command, len(cmds))
+	for i, c := range cmds {
+		p := path + "." + strconv.Itoa(i)
+		c.Raw = f(p+".raw", c.Raw)
+		c.Output = f(p+".output", c.Output)
+		c.Diff = f(p+".diff", c.Diff)
+		c.OutputMeta = sanitizeMap(p+".output_meta", c.OutputMeta, f)
+		c.Targets = sanitizeTargets(p+".targets", c.Targets, f)
+		out[i] = c
+	}
+	return out
+}
+
+func sanitizeMap(path string, m map[string]string, f TextFunc) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = f(path+"."+k, v)
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+const secret = "hunter2"
+
+func TestTextSanitizerReachesEveryFreeTextField(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+
+	fields := make(map[string]bool)
+	svc := audit.NewService(st, audit.TextSanitizer(func(field, text string) string {
+		fields[field] = true
+		return strings.ReplaceAll(text, secret, "[redacted]")
+	}))
+
+	actor := func(id string) audit.Actor {
+		return audit.Actor{ID: id, Name: "name " + secret, Meta: map[string]string{"token": secret}}
+	}
+	cmds := []audit.Command{{
+		Kind:       "cli",
+		Raw:        "enable secret " + secret,
+		Output:     "ok " + secret,
+		Diff:       "+ enable secret " + secret,
+		OutputMeta: map[string]string{"note": secret},
+	}}
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:       "Rotate " + secret,
+		Description: "password is " + secret,
+		Requester:   actor("u-1"),
+		Targets:     []audit.Target{{Type: "network_device", ID: "sw-12", Labels: map[string]string{"site": secret}}},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, actor("u-2"), "corr", "note "+secret); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, actor("svc-1"), "corr", cmds, audit.Result{Status: "SUCCESS", Message: "done " + secret}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if err := svc.Verify(ctx, trailID, actor("u-3"), "corr", []audit.Evidence{
+		{Kind: "show_cmd", Ref: "show run | i " + secret, Detail: map[string]string{"password": secret}},
+	}); err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+
+	if cmds[0].Raw != "enable secret "+secret {
+		t.Fatalf("caller's commands were modified: %+v", cmds[0])
+	}
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+
+	trail, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	stored, _ := json.Marshal(struct {
+		audit.Trail
+		Events []audit.Event
+	}{trail, events})
+	if strings.Contains(string(stored), secret) {
+		t.Fatalf("secret stored: %s", stored)
+	}
+
+	for _, want := range []string{
+		"title", "description", "targets.0.labels.site",
+		"actor.name", "actor.meta.token", "evidence.0.ref",
+		"commands.0.raw", "commands.0.output", "commands.0.diff", "commands.0.output_meta.note",
+		"result.message", "evidence.0.detail.password",
+	} {
+		if !fields[want] {
+			var got []string
+			for f := range fields {
+				got = append(got, f)
+			}
+			sort.Strings(got)
+			t.Errorf("field %s was not sanitized; saw %v", want, got)
+		}
+	}
+}
+
+// countingSanitizer implements only the original Sanitizer methods.
+type countingSanitizer struct{ targets, commands int }
+
+func (c *countingSanitizer) SanitizeTargets(ts []audit.Target) []audit.Target {
+	c.targets++
+	return ts
+}
+
+func (c *countingSanitizer) SanitizeCommands(cmds []audit.Command) []audit.Command {
+	c.commands++
+	for i := range cmds {
+		cmds[i].Raw = "[redacted]"
+	}
+	return cmds
+}
+
+func TestLegacySanitizerIsAdapted(t *testing.T) {
+	ctx := context.Background()
+	st := memory.New()
+	san := &countingSanitizer{}
+	svc := audit.NewService(st, san)
+
+	trailID := requestApproved(t, svc)
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "corr",
+		[]audit.Command{{Kind: "cli", Raw: "enable secret " + secret}},
+		audit.Result{Status: "SUCCESS", Message: "done " + secret},
+	); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+
+	// Targets once for the trail, commands once for EXECUTED, as before
+	// EventSanitizer existed; other fields are untouched.
+	if san.targets != 1 || san.commands != 1 {
+		t.Fatalf("expected 1 targets and 1 commands call, got %d and %d", san.targets, san.commands)
+	}
+	_, events, _ := st.GetTrail(ctx, trailID)
+	last := events[len(events)-1]
+	if last.Commands[0].Raw != "[redacted]" || last.Result.Message != "done "+secret {
+		t.Fatalf("unexpected executed event %+v", last)
+	}
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+}
+
+func requestApproved(t *testing.T, svc *audit.Service) string {
+	t.Helper()
+	ctx := context.Background()
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Update NTP",
+		Requester: audit.Actor{ID: "u-1"},
+		Targets:   []audit.Target{{Type: "network_device", ID: "sw-12"}},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	return trailID
+}
@@ -5,36 +5,108 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
 type Service struct {
-	store     Store
-	sanitizer Sanitizer
-	now       func() time.Time
+	store        Store
+	sanitizer    EventSanitizer
+	now          func() time.Time
+	transitions  Transitions
+	policies     []Policy
+	signer       Signer
+	keys         KeyResolver
+	hashVersion  int
+	pollInterval time.Duration
 }
 
 type Option func(*Service)
 
+// WithClock sets the source of event and trail times. The Service truncates
+// its output to the microsecond.
 func WithClock(now func() time.Time) Option {
 	return func(s *Service) { s.now = now }
 }
 
-func NewService(store Store, sanitizer Sanitizer, opts ...Option) *Service {
-	if sanitizer == nil {
-		sanitizer = NoopSanitizer{}
+// WithTransitions replaces the lifecycle enforced when events are appended.
+// Passing nil disables lifecycle enforcement.
+func WithTransitions(t Transitions) Option {
+	return func(s *Service) { s.transitions = t }
+}
+
+// WithPolicy adds a policy that every appended event must satisfy.
+// Policies are evaluated in the order they were added.
+func WithPolicy(p Policy) Option {
+	return func(s *Service) {
+		if p != nil {
+			s.policies = append(s.policies, p)
+		}
 	}
+}
+
+// WithSigner signs every appended event.
+func WithSigner(signer Signer) Option {
+	return func(s *Service) { s.signer = signer }
+}
+
+// WithKeyResolver makes VerifyTrail require a valid signature on every event.
+func WithKeyResolver(keys KeyResolver) Option {
+	return func(s *Service) { s.keys = keys }
+}
+
+// WithHashVersion selects the hash scheme for new events.
+// Existing events keep verifying with the scheme they were hashed with.
+func WithHashVersion(version int) Option {
+	return func(s *Service) { s.hashVersion = version }
+}
+
+// WithPollInterval sets how often Subscribe polls stores that do not
+// implement Notifier.
+func WithPollInterval(d time.Duration) Option {
+	return func(s *Service) {
+		if d > 0 {
+			s.pollInterval = d
+		}
+	}
+}
+
+// NewService returns a Service over store. sanitizer may be nil; see
+// EventSanitizer for how it is applied.
+func NewService(store Store, sanitizer Sanitizer, opts ...Option) *Service {
 	s := &Service{
-		store:     store,
-		sanitizer: sanitizer,
-		now:       time.Now().UTC,
+		store:        store,
+		sanitizer:    AdaptSanitizer(sanitizer),
+		now:          func() time.Time { return time.Now().UTC() },
+		transitions:  DefaultTransitions(),
+		hashVersion:  DefaultHashVersion,
+		pollInterval: DefaultPollInterval,
 	}
 	for _, opt := range opts {
 		opt(s)
 	}
+	// Times are hashed at microsecond precision and SQL stores keep no more,
+	// so a time must be truncated before it is hashed and stored, or a store
+	// that rounds it would no longer match the hash.
+	clock := s.now
+	s.now = func() time.Time { return clock().Truncate(time.Microsecond) }
 	return s
 }
 
+// ErrInvalid matches, via errors.Is, every error caused by invalid caller
+// input, such as a missing title.
+var ErrInvalid = errors.New("audit: invalid input")
+
+type invalidError struct{ msg string }
+
+func (e *invalidError) Error() string        { return e.msg }
+func (e *invalidError) Is(target error) bool { return target == ErrInvalid }
+
+func invalidf(format string, args ...any) error {
+	return &invalidError{fmt.Sprintf(format, args...)}
+}
+
 type RequestInput struct {
 	Title         string
 	Description   string
@@ -45,10 +117,11 @@ type RequestInput struct {
 
 func (s *Service) Request(ctx context.Context, in RequestInput) (string, error) {
 	if in.Title == "" {
-		return "", errors.New("title is required")
+		return "", invalidf("title is required")
 	}
-	if in.Requester.ID == " " {
-		return "", errors.New("requester actor id is required")
+	in.Requester.ID = strings.TrimSpace(in.Requester.ID)
+	if in.Requester.ID == "" {
+		return "", invalidf("requester actor id is required")
 	}
 	if in.Requester.Role == "" {
 		in.Requester.Role = RoleRequester
@@ -57,43 +130,57 @@ func (s *Service) Request(ctx context.Context, in RequestInput) (string, error)
 	trailID := newID()
 	now := s.now()
 
-	targets := s.sanitizer.SanitizeTargets(in.Targets)
-
-	t := Trail{
+	t := s.sanitizer.SanitizeTrail(Trail{
 		ID:            trailID,
 		CreatedAt:     now,
 		Title:         in.Title,
 		Description:   in.Description,
 		CorrelationID: in.CorrelationID,
-		Targets:       targets,
-	}
+		Targets:       in.Targets,
+	})
 
-	if err := s.store.CreateTrail(ctx, t); err != nil {
+	trailHash, err := ComputeTrailHash(t)
+	if err != nil {
 		return "", err
 	}
 
-	// Add first event: REQUESTED
+	// Add first event: REQUESTED. It inherits the sanitized targets from
+	// the stored trail.
 	e := Event{
 		ID:            newID(),
 		TrailID:       trailID,
 		Type:          EventRequested,
 		At:            now,
 		Actor:         in.Requester,
-		Targets:       targets,
 		Commands:      nil,
 		Result:        nil,
 		Evidence:      nil,
 		CorrelationID: in.CorrelationID,
-		PrevHash:      "", // first event
+		TrailHash:     trailHash,
 	}
 
-	h, err := ComputeEventHash(e)
-	if err != nil {
+	// Stores cannot delete trails, so reject the event before the trail
+	// exists rather than leave a trail without events behind.
+	check := s.sanitizeEvent(e)
+	check.Targets = t.Targets
+	if err := s.checkAppend(t, nil, check); err != nil {
 		return "", err
 	}
-	e.Hash = h
 
-	if err := s.store.AppendEvent(ctx, e); err != nil {
+	if ts, ok := s.store.(TrailStarter); ok {
+		if err := s.seal(&check); err != nil {
+			return "", err
+		}
+		if err := ts.StartTrail(ctx, t, check); err != nil {
+			return "", err
+		}
+		return trailID, nil
+	}
+
+	if err := s.store.CreateTrail(ctx, t); err != nil {
+		return "", err
+	}
+	if err := s.appendEvent(ctx, e); err != nil {
 		return "", err
 	}
 
@@ -108,45 +195,23 @@ func (s *Service) Approve(ctx context.Context, trailID string, approver Actor, c
 func (s *Service) Execute(ctx context.Context, trailID string, executor Actor, correlationID string, cmds []Command, res Result) error {
 	executor.Role = RoleExecutor
 
-	cmds = s.sanitizer.SanitizeCommands(cmds)
-
-	prev, err := s.store.LatestEvent(ctx, trailID)
-	if err != nil {
-		return err
-	}
-
 	e := Event{
 		ID:            newID(),
 		TrailID:       trailID,
 		Type:          EventExecuted,
 		At:            s.now(),
 		Actor:         executor,
-		Targets:       nil, // optional: can pull from trail if you want
 		Commands:      cmds,
 		Result:        &res,
 		CorrelationID: correlationID,
 	}
-	if prev != nil {
-		e.PrevHash = prev.Hash
-	}
-
-	h, err := ComputeEventHash(e)
-	if err != nil {
-		return err
-	}
-	e.Hash = h
 
-	return s.store.AppendEvent(ctx, e)
+	return s.appendEvent(ctx, e)
 }
 
 func (s *Service) Verify(ctx context.Context, trailID string, verifier Actor, correlationID string, evidence []Evidence) error {
 	verifier.Role = RoleVerifier
 
-	prev, err := s.store.LatestEvent(ctx, trailID)
-	if err != nil {
-		return err
-	}
-
 	e := Event{
 		ID:            newID(),
 		TrailID:       trailID,
@@ -156,17 +221,71 @@ func (s *Service) Verify(ctx context.Context, trailID string, verifier Actor, co
 		Evidence:      evidence,
 		CorrelationID: correlationID,
 	}
-	if prev != nil {
-		e.PrevHash = prev.Hash
+
+	return s.appendEvent(ctx, e)
+}
+
+// Fail records that a change did not succeed, e.g. execution errored or
+// verification did not match. res describes the failure.
+func (s *Service) Fail(ctx context.Context, trailID string, actor Actor, correlationID string, res Result, evidence []Evidence) error {
+	if actor.Role == "" {
+		actor.Role = RoleExecutor
+	}
+	if res.Status == "" {
+		res.Status = "FAILED"
 	}
 
-	h, err := ComputeEventHash(e)
-	if err != nil {
-		return err
+	e := Event{
+		ID:            newID(),
+		TrailID:       trailID,
+		Type:          EventFailed,
+		At:            s.now(),
+		Actor:         actor,
+		Result:        &res,
+		Evidence:      evidence,
+		CorrelationID: correlationID,
+	}
+
+	return s.appendEvent(ctx, e)
+}
+
+// Cancel records that a change was withdrawn before it was executed.
+func (s *Service) Cancel(ctx context.Context, trailID string, actor Actor, correlationID string, res Result, evidence []Evidence) error {
+	if actor.Role == "" {
+		actor.Role = RoleRequester
+	}
+
+	e := Event{
+		ID:            newID(),
+		TrailID:       trailID,
+		Type:          EventCancelled,
+		At:            s.now(),
+		Actor:         actor,
+		Result:        &res,
+		Evidence:      evidence,
+		CorrelationID: correlationID,
 	}
-	e.Hash = h
 
-	return s.store.AppendEvent(ctx, e)
+	return s.appendEvent(ctx, e)
+}
+
+// Rollback records the commands that reverted an executed or failed change.
+func (s *Service) Rollback(ctx context.Context, trailID string, executor Actor, correlationID string, cmds []Command, res Result, evidence []Evidence) error {
+	executor.Role = RoleExecutor
+
+	e := Event{
+		ID:            newID(),
+		TrailID:       trailID,
+		Type:          EventRolledBack,
+		At:            s.now(),
+		Actor:         executor,
+		Commands:      cmds,
+		Result:        &res,
+		Evidence:      evidence,
+		CorrelationID: correlationID,
+	}
+
+	return s.appendEvent(ctx, e)
 }
 
 func (s *Service) WhatChanged(ctx context.Context, target Target, from, to time.Time, limit int) ([]Event, error) {
@@ -177,15 +296,37 @@ func (s *Service) WhatChanged(ctx context.Context, target Target, from, to time.
 		To:         to,
 		Limit:      limit,
 	}
+	page, err := s.store.QueryEvents(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return page.Events, nil
+}
+
+// QueryEvents returns one page of events matching q.
+// Pass the page's NextCursor as q.Cursor to fetch the next one.
+func (s *Service) QueryEvents(ctx context.Context, q Query) (EventPage, error) {
 	return s.store.QueryEvents(ctx, q)
 }
 
-func (s *Service) appendSimpleEvent(ctx context.Context, trailID string, typ EventType, actor Actor, correlationID string, note string) error {
-	prev, err := s.store.LatestEvent(ctx, trailID)
-	if err != nil {
-		return err
-	}
+// GetTrail returns a trail and its events in chain order.
+func (s *Service) GetTrail(ctx context.Context, trailID string) (Trail, []Event, error) {
+	return s.store.GetTrail(ctx, trailID)
+}
+
+// ListTrails returns one page of trails matching q, newest first.
+// Pass the page's NextCursor as q.Cursor to fetch the next one.
+func (s *Service) ListTrails(ctx context.Context, q TrailQuery) (TrailPage, error) {
+	return s.store.ListTrails(ctx, q)
+}
+
+// IterateEvents streams every event matching q in q.Order, for exports that
+// do not fit in memory. The iterator must be closed.
+func (s *Service) IterateEvents(ctx context.Context, q Query) (EventIterator, error) {
+	return s.store.IterateEvents(ctx, q)
+}
 
+func (s *Service) appendSimpleEvent(ctx context.Context, trailID string, typ EventType, actor Actor, correlationID string, note string) error {
 	// Put "note" in evidence for now (keeps schema generic)
 	ev := []Evidence(nil)
 	if note != "" {
@@ -201,17 +342,181 @@ func (s *Service) appendSimpleEvent(ctx context.Context, trailID string, typ Eve
 		Evidence:      ev,
 		CorrelationID: correlationID,
 	}
+
+	return s.appendEvent(ctx, e)
+}
+
+// maxAppendAttempts bounds how often appendEvent re-reads the trail head
+// after losing a race with a concurrent append.
+const maxAppendAttempts = 5
+
+// appendEvent checks e's actor, sanitizes e, chains it onto the latest event
+// of its trail, checks the transition against the lifecycle and policies,
+// hashes it and stores it. If another writer appends to the trail in
+// between, it retries against the new head.
+func (s *Service) appendEvent(ctx context.Context, e Event) error {
+	// Policies compare actor IDs, so a blank one must not count as an actor
+	// and padding must not make one actor look like two.
+	e.Actor.ID = strings.TrimSpace(e.Actor.ID)
+	if e.Actor.ID == "" {
+		return invalidf("actor id is required for %s", e.Type)
+	}
+
+	e = s.sanitizeEvent(e)
+
+	var err error
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		err = s.tryAppendEvent(ctx, e)
+		if !errors.Is(err, ErrConflict) {
+			return err
+		}
+	}
+	return err
+}
+
+func (s *Service) tryAppendEvent(ctx context.Context, e Event) error {
+	trail, events, err := s.store.GetTrail(ctx, e.TrailID)
+	if err != nil {
+		return err
+	}
+	var prev *Event
+	if len(events) > 0 {
+		prev = &events[len(events)-1]
+	}
+
+	// Later events inherit the trail's targets so WhatChanged finds them.
+	if e.Targets == nil {
+		e.Targets, e.Commands, err = scopeTargets(trail, e.Commands)
+		if err != nil {
+			return err
+		}
+	}
+
+	e.PrevHash = ""
 	if prev != nil {
 		e.PrevHash = prev.Hash
 	}
+	if err := s.checkAppend(trail, events, e); err != nil {
+		return err
+	}
+	if err := s.seal(&e); err != nil {
+		return err
+	}
+	return s.store.CompareAndAppend(ctx, e)
+}
 
-	h, err := ComputeEventHash(e)
+// seal hashes e with this Service's scheme and signs it if there is a signer.
+func (s *Service) seal(e *Event) error {
+	e.HashVersion = s.hashVersion
+	h, err := ComputeEventHash(*e)
 	if err != nil {
 		return err
 	}
 	e.Hash = h
 
-	return s.store.AppendEvent(ctx, e)
+	if s.signer != nil {
+		return SignEvent(e, s.signer)
+	}
+	return nil
+}
+
+// sanitizeEvent passes e through the sanitizer, then puts back the
+// Actor.Meta values named by ActorMetaPolicy policies as given, so policies
+// judge what the caller said and not what a redaction rule left of it.
+func (s *Service) sanitizeEvent(e Event) Event {
+	raw := e.Actor.Meta
+	e = s.sanitizer.SanitizeEvent(e)
+
+	var meta map[string]string
+	for _, p := range s.policies {
+		mp, ok := p.(ActorMetaPolicy)
+		if !ok {
+			continue
+		}
+		for _, k := range mp.ActorMetaKeys() {
+			v, ok := raw[k]
+			if !ok || e.Actor.Meta[k] == v {
+				continue
+			}
+			if meta == nil {
+				// Copy rather than write into a map the sanitizer or
+				// caller may still hold.
+				meta = make(map[string]string, len(e.Actor.Meta))
+				for mk, mv := range e.Actor.Meta {
+					meta[mk] = mv
+				}
+				e.Actor.Meta = meta
+			}
+			meta[k] = v
+		}
+	}
+	return e
+}
+
+// checkAppend checks e against the lifecycle and policies, given the events
+// already on trail.
+func (s *Service) checkAppend(trail Trail, events []Event, e Event) error {
+	from := StateNew
+	if n := len(events); n > 0 {
+		from = events[n-1].Type
+	}
+	if s.transitions != nil && !s.transitions.Allows(from, e.Type) {
+		return &TransitionError{TrailID: e.TrailID, From: from, To: e.Type}
+	}
+	for _, p := range s.policies {
+		if err := p.Evaluate(trail, events, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scopeTargets returns the targets an event on trail touched. If any command
+// names the targets it hit, the event touches just those, otherwise every
+// target of the trail. Command targets must belong to the trail; they are
+// replaced by the trail's own records so labels are consistent.
+func scopeTargets(trail Trail, cmds []Command) ([]Target, []Command, error) {
+	scoped := false
+	for _, c := range cmds {
+		if len(c.Targets) > 0 {
+			scoped = true
+			break
+		}
+	}
+	if !scoped {
+		return trail.Targets, cmds, nil
+	}
+
+	index := make(map[[2]string]int, len(trail.Targets))
+	for i, t := range trail.Targets {
+		index[[2]string{t.Type, t.ID}] = i
+	}
+
+	hit := make([]bool, len(trail.Targets))
+	out := make([]Command, len(cmds))
+	for i, c := range cmds {
+		if len(c.Targets) > 0 {
+			ts := make([]Target, 0, len(c.Targets))
+			for _, t := range c.Targets {
+				j, ok := index[[2]string{t.Type, t.ID}]
+				if !ok {
+					return nil, nil, invalidf("command %d targets %s:%s, which is not a target of trail %s", i, t.Type, t.ID, trail.ID)
+				}
+				hit[j] = true
+				ts = append(ts, trail.Targets[j])
+			}
+			c.Targets = ts
+		}
+		out[i] = c
+	}
+
+	var targets []Target
+	for j, t := range trail.Targets {
+		if hit[j] {
+			targets = append(targets, t)
+		}
+	}
+	return targets, out, nil
 }
 
 func newID() string {
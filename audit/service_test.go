@@ -2,6 +2,7 @@ package audit_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -71,3 +72,47 @@ func TestRequestCreatesTrailAndRequestedEvent(t *testing.T) {
 		t.Fatalf("hash mismatch: expected %s got %s", expectedHash, ev.Hash)
 	}
 }
+
+func TestEveryActionRequiresAnActorID(t *testing.T) {
+	ctx := context.Background()
+	svc := audit.NewService(memory.New(), nil)
+
+	for _, id := range []string{"", "   "} {
+		if _, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: id}}); !errors.Is(err, audit.ErrInvalid) {
+			t.Fatalf("Request with actor %q: expected ErrInvalid, got %v", id, err)
+		}
+	}
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: " u-1 "}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	actions := map[string]func(audit.Actor) error{
+		"Approve": func(a audit.Actor) error { return svc.Approve(ctx, trailID, a, "", "") },
+		"Execute": func(a audit.Actor) error {
+			return svc.Execute(ctx, trailID, a, "", nil, audit.Result{Status: "SUCCESS"})
+		},
+		"Verify": func(a audit.Actor) error { return svc.Verify(ctx, trailID, a, "", nil) },
+		"Fail":   func(a audit.Actor) error { return svc.Fail(ctx, trailID, a, "", audit.Result{}, nil) },
+		"Cancel": func(a audit.Actor) error { return svc.Cancel(ctx, trailID, a, "", audit.Result{}, nil) },
+		"Rollback": func(a audit.Actor) error {
+			return svc.Rollback(ctx, trailID, a, "", nil, audit.Result{}, nil)
+		},
+	}
+	for name, action := range actions {
+		for _, id := range []string{"", " \t"} {
+			if err := action(audit.Actor{ID: id, Name: "Someone"}); !errors.Is(err, audit.ErrInvalid) {
+				t.Fatalf("%s with actor %q: expected ErrInvalid, got %v", name, id, err)
+			}
+		}
+	}
+
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2\n"}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	_, events, _ := svc.GetTrail(ctx, trailID)
+	if len(events) != 2 || events[0].Actor.ID != "u-1" || events[1].Actor.ID != "u-2" {
+		t.Fatalf("actor IDs were not trimmed: %+v", events)
+	}
+}
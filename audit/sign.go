@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// Signer produces detached signatures over event hashes.
+// The signed message is the raw (hex-decoded) Event.Hash.
+type Signer interface {
+	KeyID() string
+	Sign(digest []byte) ([]byte, error)
+}
+
+// KeyResolver returns the public key for a key ID recorded on an event.
+type KeyResolver interface {
+	ResolveKey(ctx context.Context, keyID string) (crypto.PublicKey, error)
+}
+
+// Ed25519Signer signs event hashes with an Ed25519 private key.
+type Ed25519Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+func NewEd25519Signer(keyID string, key ed25519.PrivateKey) *Ed25519Signer {
+	return &Ed25519Signer{keyID: keyID, key: key}
+}
+
+func (s *Ed25519Signer) KeyID() string { return s.keyID }
+
+func (s *Ed25519Signer) Sign(digest []byte) ([]byte, error) {
+	if len(s.key) != ed25519.PrivateKeySize {
+		return nil, errors.New("audit: invalid ed25519 private key")
+	}
+	return ed25519.Sign(s.key, digest), nil
+}
+
+// StaticKeys is a KeyResolver backed by a fixed set of public keys.
+type StaticKeys map[string]crypto.PublicKey
+
+func (k StaticKeys) ResolveKey(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	pub, ok := k[keyID]
+	if !ok {
+		return nil, fmt.Errorf("audit: unknown key %q", keyID)
+	}
+	return pub, nil
+}
+
+// SignEvent sets e.KeyID and e.Signature. e.Hash must already be computed.
+func SignEvent(e *Event, s Signer) error {
+	digest, err := hex.DecodeString(e.Hash)
+	if err != nil || len(digest) == 0 {
+		return errors.New("audit: event hash must be set before signing")
+	}
+	sig, err := s.Sign(digest)
+	if err != nil {
+		return err
+	}
+	e.KeyID = s.KeyID()
+	e.Signature = base64.StdEncoding.EncodeToString(sig)
+	return nil
+}
+
+// VerifyEventSignature checks e.Signature over e.Hash with pub.
+// Only Ed25519 keys are supported.
+func VerifyEventSignature(e Event, pub crypto.PublicKey) error {
+	if e.Signature == "" {
+		return errors.New("audit: event is not signed")
+	}
+	digest, err := hex.DecodeString(e.Hash)
+	if err != nil {
+		return fmt.Errorf("audit: malformed event hash: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(e.Signature)
+	if err != nil {
+		return fmt.Errorf("audit: malformed signature: %w", err)
+	}
+
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, digest, sig) {
+			return errors.New("audit: signature mismatch")
+		}
+		return nil
+	default:
+		return fmt.Errorf("audit: unsupported public key type %T", pub)
+	}
+}
@@ -0,0 +1,111 @@
+package audit_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestVerifyTrailChecksSignatures(t *testing.T) {
+	ctx := context.Background()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey error: %v", err)
+	}
+	keys := audit.StaticKeys{"k-1": pub}
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{},
+		audit.WithSigner(audit.NewEd25519Signer("k-1", priv)),
+		audit.WithKeyResolver(keys),
+	)
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Signed change",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail should pass, got error: %v", err)
+	}
+
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	if events[0].KeyID != "k-1" || events[0].Signature == "" {
+		t.Fatalf("expected event to be signed with k-1, got key=%q sig=%q", events[0].KeyID, events[0].Signature)
+	}
+
+	// Someone with database access rewrites the trail and recomputes every hash.
+	rewritten := &rewritingStore{Store: st}
+	verifier := audit.NewService(rewritten, audit.NoopSanitizer{}, audit.WithKeyResolver(keys))
+
+	err = verifier.VerifyTrail(ctx, trailID)
+	if err == nil {
+		t.Fatalf("expected verification to fail for a rewritten trail")
+	}
+	if _, ok := err.(*audit.VerifyError); !ok {
+		t.Fatalf("expected VerifyError, got %T: %v", err, err)
+	}
+}
+
+func TestVerifyTrailRequiresSignaturesWithKeyResolver(t *testing.T) {
+	ctx := context.Background()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey error: %v", err)
+	}
+
+	st := memory.New()
+	unsigned := audit.NewService(st, audit.NoopSanitizer{})
+	trailID, err := unsigned.Request(ctx, audit.RequestInput{
+		Title:     "Unsigned change",
+		Requester: audit.Actor{ID: "u-1"},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	verifier := audit.NewService(st, audit.NoopSanitizer{}, audit.WithKeyResolver(audit.StaticKeys{"k-1": pub}))
+	if err := verifier.VerifyTrail(ctx, trailID); err == nil {
+		t.Fatalf("expected verification to fail for unsigned events")
+	}
+}
+
+// rewritingStore changes the first event and recomputes the whole chain,
+// keeping the old signatures, as an attacker without the signing key would.
+type rewritingStore struct{ audit.Store }
+
+func (r *rewritingStore) GetTrail(ctx context.Context, trailID string) (audit.Trail, []audit.Event, error) {
+	tr, evs, err := r.Store.GetTrail(ctx, trailID)
+	if err != nil {
+		return audit.Trail{}, nil, err
+	}
+	prev := ""
+	for i := range evs {
+		if i == 0 {
+			evs[i].Actor.ID = "someone-else"
+		}
+		evs[i].PrevHash = prev
+		h, err := audit.ComputeEventHash(evs[i])
+		if err != nil {
+			return audit.Trail{}, nil, err
+		}
+		evs[i].Hash = h
+		prev = h
+	}
+	return tr, evs, nil
+}
@@ -2,18 +2,245 @@ package audit
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 )
 
+// ErrConflict is returned by Store.CompareAndAppend when the trail's latest
+// event is no longer the one the new event chains to.
+var ErrConflict = errors.New("audit: trail head changed concurrently")
+
+// ErrTrailNotFound is returned by Store methods given an unknown trail ID.
+var ErrTrailNotFound = errors.New("trail not found")
+
 // Query lets you ask questions like:
 // "what changed on device X last Tuesday?"
+//
+// Zero fields match every event; set fields must all match.
 type Query struct {
-	TargetType string
-	TargetID   string
-	From       time.Time
-	To         time.Time
-	EventTypes []EventType
-	Limit      int
+	TargetType string      `json:"target_type,omitempty"`
+	TargetID   string      `json:"target_id,omitempty"`
+	From       time.Time   `json:"from,omitempty"`
+	To         time.Time   `json:"to,omitempty"`
+	EventTypes []EventType `json:"event_types,omitempty"`
+
+	Targets       []Target          `json:"targets,omitempty"`       // touches any of these (by Type and ID), or TargetType/TargetID
+	TargetLabels  map[string]string `json:"target_labels,omitempty"` // touches a target carrying all of these labels
+	ActorID       string            `json:"actor_id,omitempty"`
+	ActorRole     ActorRole         `json:"actor_role,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	TrailIDs      []string          `json:"trail_ids,omitempty"`
+	ResultStatus  string            `json:"result_status,omitempty"` // Result.Status, e.g. "FAILED"
+
+	Limit  int    `json:"limit,omitempty"`
+	Order  Order  `json:"order,omitempty"`  // OrderDesc (newest first) unless set
+	Cursor string `json:"cursor,omitempty"` // NextCursor of the previous page
+}
+
+// AnyTargets returns the targets an event must touch one of: Targets plus
+// TargetType/TargetID if both are set.
+func (q Query) AnyTargets() []Target {
+	ts := q.Targets
+	if q.TargetType != "" && q.TargetID != "" {
+		ts = append(ts[:len(ts):len(ts)], Target{Type: q.TargetType, ID: q.TargetID})
+	}
+	return ts
+}
+
+// Matches reports whether e passes every filter of q. Limit, Order and
+// Cursor are not filters. Stores that cannot filter natively use it.
+func (q Query) Matches(e Event) bool {
+	if !q.From.IsZero() && e.At.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !e.At.Before(q.To) {
+		return false
+	}
+	if len(q.EventTypes) > 0 && !slices.Contains(q.EventTypes, e.Type) {
+		return false
+	}
+	if ts := q.AnyTargets(); len(ts) > 0 && !touchesAny(e, ts) {
+		return false
+	}
+	if len(q.TargetLabels) > 0 && !touchesLabels(e, q.TargetLabels) {
+		return false
+	}
+	if q.ActorID != "" && e.Actor.ID != q.ActorID {
+		return false
+	}
+	if q.ActorRole != "" && e.Actor.Role != q.ActorRole {
+		return false
+	}
+	if q.CorrelationID != "" && e.CorrelationID != q.CorrelationID {
+		return false
+	}
+	if len(q.TrailIDs) > 0 && !slices.Contains(q.TrailIDs, e.TrailID) {
+		return false
+	}
+	if q.ResultStatus != "" && (e.Result == nil || e.Result.Status != q.ResultStatus) {
+		return false
+	}
+	return true
+}
+
+func touchesAny(e Event, ts []Target) bool {
+	for _, t := range e.Targets {
+		for _, w := range ts {
+			if t.Type == w.Type && t.ID == w.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func touchesLabels(e Event, want map[string]string) bool {
+	for _, t := range e.Targets {
+		ok := true
+		for k, v := range want {
+			if got, found := t.Labels[k]; !found || got != v {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Order is the ledger order QueryEvents returns events in.
+type Order string
+
+const (
+	OrderDesc Order = "desc" // newest first; the default
+	OrderAsc  Order = "asc"  // oldest first
+)
+
+// EventPage is one page of QueryEvents in the order the query asked for.
+// NextCursor is empty on the last page.
+type EventPage struct {
+	Events     []Event `json:"events"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// TrailQuery lists trails, e.g. "changes requested this week".
+// Zero fields match every trail.
+type TrailQuery struct {
+	From          time.Time `json:"from,omitempty"`           // CreatedAt >= From
+	To            time.Time `json:"to,omitempty"`             // CreatedAt < To
+	TitleContains string    `json:"title_contains,omitempty"` // case-insensitive substring of the title
+	CorrelationID string    `json:"correlation_id,omitempty"`
+	TargetType    string    `json:"target_type,omitempty"`  // trails naming a target of this type
+	TargetID      string    `json:"target_id,omitempty"`    // and this ID; requires TargetType
+	RequesterID   string    `json:"requester_id,omitempty"` // actor ID of the REQUESTED event
+	Status        EventType `json:"status,omitempty"`       // type of the trail's latest event
+	Limit         int       `json:"limit,omitempty"`
+	Cursor        string    `json:"cursor,omitempty"` // NextCursor of the previous page
+}
+
+// Validate rejects a TargetID without a TargetType: target IDs are only
+// unique within a type. Stores return its error from ListTrails.
+func (q TrailQuery) Validate() error {
+	if q.TargetID != "" && q.TargetType == "" {
+		return invalidf("trail query: target_id requires target_type")
+	}
+	return nil
+}
+
+// Matches reports whether t passes every filter of q. Limit and Cursor are
+// not filters. Stores that cannot filter natively use it.
+func (q TrailQuery) Matches(t TrailSummary) bool {
+	if !q.From.IsZero() && t.CreatedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !t.CreatedAt.Before(q.To) {
+		return false
+	}
+	if q.TitleContains != "" && !strings.Contains(strings.ToLower(t.Title), strings.ToLower(q.TitleContains)) {
+		return false
+	}
+	if q.CorrelationID != "" && t.CorrelationID != q.CorrelationID {
+		return false
+	}
+	if q.TargetType != "" && !namesTarget(t.Targets, q.TargetType, q.TargetID) {
+		return false
+	}
+	if q.RequesterID != "" && t.Requester.ID != q.RequesterID {
+		return false
+	}
+	if q.Status != "" && t.Status != q.Status {
+		return false
+	}
+	return true
+}
+
+// namesTarget reports whether ts has a target of type typ with ID id, or of
+// any ID if id is empty.
+func namesTarget(ts []Target, typ, id string) bool {
+	for _, t := range ts {
+		if t.Type == typ && (id == "" || t.ID == id) {
+			return true
+		}
+	}
+	return false
+}
+
+// PageTrails applies q to every trail of a store and returns the requested
+// page, for stores that list trails in Go.
+func PageTrails(q TrailQuery, trails []TrailSummary) (TrailPage, error) {
+	if err := q.Validate(); err != nil {
+		return TrailPage{}, err
+	}
+	var after *TrailCursor
+	if q.Cursor != "" {
+		c, err := DecodeTrailCursor(q.Cursor)
+		if err != nil {
+			return TrailPage{}, err
+		}
+		after = &c
+	}
+
+	var out []TrailSummary
+	for _, t := range trails {
+		if after != nil && !after.Precedes(t.Trail) {
+			continue
+		}
+		if q.Matches(t) {
+			out = append(out, t)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return cursorOf(out[i].Trail).Precedes(out[j].Trail)
+	})
+
+	var page TrailPage
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+		page.NextCursor = EncodeTrailCursor(cursorOf(out[len(out)-1].Trail))
+	}
+	page.Trails = out
+	return page, nil
+}
+
+// TrailSummary is a trail header plus its current state.
+type TrailSummary struct {
+	Trail
+	Requester Actor     `json:"requester"`
+	Status    EventType `json:"status"`     // type of the latest event; StateNew if none
+	UpdatedAt time.Time `json:"updated_at"` // time of the latest event
+}
+
+// TrailPage is one page of ListTrails, newest trail first.
+// NextCursor is empty on the last page.
+type TrailPage struct {
+	Trails     []TrailSummary `json:"trails"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
 
 // Store is the plug-in point.
@@ -22,6 +249,23 @@ type Store interface {
 	CreateTrail(ctx context.Context, t Trail) error
 	AppendEvent(ctx context.Context, e Event) error
 	GetTrail(ctx context.Context, trailID string) (Trail, []Event, error)
-	QueryEvents(ctx context.Context, q Query) ([]Event, error)
+	QueryEvents(ctx context.Context, q Query) (EventPage, error)
+	// IterateEvents streams the events QueryEvents would return, without
+	// paging: q.Cursor sets the start and q.Limit caps the total.
+	IterateEvents(ctx context.Context, q Query) (EventIterator, error)
 	LatestEvent(ctx context.Context, trailID string) (*Event, error)
+	ListTrails(ctx context.Context, q TrailQuery) (TrailPage, error)
+
+	// CompareAndAppend atomically appends e only if the hash of the trail's
+	// latest event equals e.PrevHash ("" for a trail with no events yet).
+	// Otherwise it stores nothing and returns ErrConflict.
+	CompareAndAppend(ctx context.Context, e Event) error
+}
+
+// TrailStarter is implemented by stores that cannot run Service.Request's
+// checks before creating a trail, such as store/remote, whose server checks
+// again. Request then hands over the trail and its hashed, signed REQUESTED
+// event together, and StartTrail stores both or neither.
+type TrailStarter interface {
+	StartTrail(ctx context.Context, t Trail, first Event) error
 }
@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+// FromLatest makes Subscribe deliver only events appended after it is called.
+const FromLatest int64 = -1
+
+// DefaultPollInterval is how often Subscribe checks the store for new events
+// when the store cannot notify it, and as a safety net when it can.
+const DefaultPollInterval = time.Second
+
+// Notifier is implemented by stores that can signal appends, so subscribers
+// wake up straight away instead of on the next poll.
+type Notifier interface {
+	// Notify returns a channel that receives a value after events are
+	// appended. Signals may be coalesced. The channel is released when ctx
+	// is done. A nil channel means notifications are unavailable.
+	Notify(ctx context.Context) <-chan struct{}
+}
+
+// Subscription delivers events appended to the store as they arrive.
+type Subscription struct {
+	events chan Event
+	err    error
+}
+
+// Events returns the delivery channel. It is closed when the subscription
+// context is done or reading from the store fails; see Err.
+func (sub *Subscription) Events() <-chan Event { return sub.events }
+
+// Err returns why the subscription ended. Call it after Events is closed.
+// Resubscribe after the Seq of the last event received to continue.
+func (sub *Subscription) Err() error { return sub.err }
+
+// Subscribe delivers every event matching filter, in ledger order, starting
+// after afterSeq: 0 replays the whole history, the Seq of the last event a
+// subscriber processed resumes where it left off across restarts, and
+// FromLatest skips history. filter's Limit, Order and Cursor are ignored.
+func (s *Service) Subscribe(ctx context.Context, filter Query, afterSeq int64) (*Subscription, error) {
+	if afterSeq == FromLatest {
+		page, err := s.store.QueryEvents(ctx, Query{Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		afterSeq = 0
+		if len(page.Events) > 0 {
+			afterSeq = page.Events[0].Seq
+		}
+	}
+
+	var wake <-chan struct{}
+	if n, ok := s.store.(Notifier); ok {
+		wake = n.Notify(ctx)
+	}
+
+	sub := &Subscription{events: make(chan Event)}
+	go func() {
+		defer close(sub.events)
+		sub.err = s.tail(ctx, filter, afterSeq, wake, sub.events)
+	}()
+	return sub, nil
+}
+
+// tailPageSize is how many events tail reads per query.
+const tailPageSize = 256
+
+// tail sends matching events after seq to out until ctx is done, reading
+// again whenever the store signals on wake or the poll interval elapses.
+// It reads a page at a time and sends only after the page is read, so a
+// slow subscriber never holds a read transaction or connection open.
+func (s *Service) tail(ctx context.Context, filter Query, seq int64, wake <-chan struct{}, out chan<- Event) error {
+	filter.Limit = tailPageSize
+	filter.Order = OrderAsc
+
+	poll := time.NewTicker(s.pollInterval)
+	defer poll.Stop()
+
+	for {
+		filter.Cursor = ""
+		if seq > 0 {
+			filter.Cursor = EncodeEventCursor(EventCursor{Seq: seq})
+		}
+
+		page, err := s.store.QueryEvents(ctx, filter)
+		if err != nil {
+			return err
+		}
+		for _, e := range page.Events {
+			if !send(ctx, out, e) {
+				return ctx.Err()
+			}
+			seq = e.Seq
+		}
+		if page.NextCursor != "" {
+			continue // more history before waiting
+		}
+
+		select {
+		case <-wake:
+		case <-poll.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func next(t *testing.T, sub *audit.Subscription) audit.Event {
+	t.Helper()
+	select {
+	case e, ok := <-sub.Events():
+		if !ok {
+			t.Fatalf("subscription ended: %v", sub.Err())
+		}
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for an event")
+	}
+	return audit.Event{}
+}
+
+func TestSubscribeDeliversNewEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A long poll interval shows the memory store's broker wakes subscribers.
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{}, audit.WithPollInterval(time.Hour))
+
+	old, err := svc.Request(ctx, audit.RequestInput{Title: "Before subscribing", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	sub, err := svc.Subscribe(ctx, audit.Query{EventTypes: []audit.EventType{audit.EventApproved, audit.EventExecuted}}, audit.FromLatest)
+	if err != nil {
+		t.Fatalf("Subscribe error: %v", err)
+	}
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Approve(ctx, old, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+
+	first, second := next(t, sub), next(t, sub)
+	if first.TrailID != trailID || first.Type != audit.EventApproved {
+		t.Fatalf("expected APPROVED on %s first, got %s on %s", trailID, first.Type, first.TrailID)
+	}
+	if second.TrailID != old || second.Seq <= first.Seq {
+		t.Fatalf("expected the second approval in ledger order, got %+v", second)
+	}
+
+	cancel()
+	for range sub.Events() {
+	}
+	if sub.Err() == nil {
+		t.Fatalf("expected the subscription to end with the context error")
+	}
+}
+
+func TestSubscribeResumesAfterSeq(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := audit.NewService(memory.New(), audit.NoopSanitizer{})
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}})
+		if err != nil {
+			t.Fatalf("Request error: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	sub, err := svc.Subscribe(ctx, audit.Query{}, 0)
+	if err != nil {
+		t.Fatalf("Subscribe error: %v", err)
+	}
+	processed := next(t, sub)
+	if processed.TrailID != ids[0] {
+		t.Fatalf("expected history to replay from the start, got trail %s", processed.TrailID)
+	}
+	cancel()
+
+	// A restarted subscriber picks up after the last event it processed.
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+	sub, err = svc.Subscribe(ctx2, audit.Query{}, processed.Seq)
+	if err != nil {
+		t.Fatalf("Subscribe error: %v", err)
+	}
+	for _, want := range ids[1:] {
+		if e := next(t, sub); e.TrailID != want {
+			t.Fatalf("expected trail %s, got %s", want, e.TrailID)
+		}
+	}
+}
+
+// pagesOnly fails IterateEvents, whose open rows a slow subscriber would
+// hold on to.
+type pagesOnly struct{ *memory.Store }
+
+func (pagesOnly) IterateEvents(context.Context, audit.Query) (audit.EventIterator, error) {
+	return nil, errors.New("Subscribe must read pages, not hold an iterator open")
+}
+
+func TestSubscribeReplaysHistoryInPages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	svc := audit.NewService(pagesOnly{memory.New()}, audit.NoopSanitizer{})
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "Busy trail", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	const approvals = 600 // more than two pages
+	for i := 0; i < approvals; i++ {
+		if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+			t.Fatalf("Approve error: %v", err)
+		}
+	}
+
+	sub, err := svc.Subscribe(ctx, audit.Query{}, 0)
+	if err != nil {
+		t.Fatalf("Subscribe error: %v", err)
+	}
+	for seq := int64(1); seq <= approvals+1; seq++ {
+		if e := next(t, sub); e.Seq != seq {
+			t.Fatalf("expected seq %d, got %d", seq, e.Seq)
+		}
+	}
+}
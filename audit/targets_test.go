@@ -0,0 +1,145 @@
+package audit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func TestWhatChangedFindsEveryLifecycleEvent(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	sw12 := audit.Target{Type: "network_device", ID: "sw-12"}
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Update NTP",
+		Requester: audit.Actor{ID: "u-1"},
+		Targets:   []audit.Target{sw12},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "corr",
+		[]audit.Command{{Kind: "cli", Raw: "ntp server 10.0.0.1"}},
+		audit.Result{Status: "SUCCESS"},
+	); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if err := svc.Verify(ctx, trailID, audit.Actor{ID: "u-3"}, "corr", nil); err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+
+	events, err := svc.WhatChanged(ctx, sw12, time.Time{}, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("WhatChanged error: %v", err)
+	}
+
+	got := make(map[audit.EventType]bool)
+	for _, e := range events {
+		got[e.Type] = true
+	}
+	for _, typ := range []audit.EventType{audit.EventRequested, audit.EventApproved, audit.EventExecuted, audit.EventVerified} {
+		if !got[typ] {
+			t.Fatalf("expected WhatChanged to return a %s event, got %d events", typ, len(events))
+		}
+	}
+
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+}
+
+func TestCommandTargetsScopeTheEvent(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	sw12 := audit.Target{Type: "network_device", ID: "sw-12"}
+	sw13 := audit.Target{Type: "network_device", ID: "sw-13", Labels: map[string]string{"site": "dc1"}}
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Update NTP",
+		Requester: audit.Actor{ID: "u-1"},
+		Targets:   []audit.Target{sw12, sw13},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+
+	cmds := []audit.Command{{
+		Kind:    "cli",
+		Raw:     "ntp server 10.0.0.1",
+		Targets: []audit.Target{{Type: "network_device", ID: "sw-13"}},
+	}}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "corr", cmds, audit.Result{Status: "SUCCESS"}); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+
+	events, err := svc.WhatChanged(ctx, sw12, time.Time{}, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("WhatChanged error: %v", err)
+	}
+	for _, e := range events {
+		if e.Type == audit.EventExecuted {
+			t.Fatalf("expected EXECUTED scoped to sw-13 to be excluded for sw-12")
+		}
+	}
+
+	_, all, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	exec := all[len(all)-1]
+	if len(exec.Targets) != 1 || exec.Targets[0].ID != "sw-13" {
+		t.Fatalf("expected EXECUTED targets [sw-13], got %+v", exec.Targets)
+	}
+	if exec.Commands[0].Targets[0].Labels["site"] != "dc1" {
+		t.Fatalf("expected command target to carry the trail's labels, got %+v", exec.Commands[0].Targets[0])
+	}
+}
+
+func TestCommandTargetOutsideTrailIsRejected(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Update NTP",
+		Requester: audit.Actor{ID: "u-1"},
+		Targets:   []audit.Target{{Type: "network_device", ID: "sw-12"}},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+
+	err = svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "corr",
+		[]audit.Command{{Kind: "cli", Raw: "reload", Targets: []audit.Target{{Type: "network_device", ID: "sw-99"}}}},
+		audit.Result{Status: "SUCCESS"},
+	)
+	if err == nil {
+		t.Fatalf("expected error for a command target outside the trail")
+	}
+
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected rejected EXECUTED not to be stored, got %d events", len(events))
+	}
+}
@@ -5,11 +5,13 @@ import "time"
 type EventType string
 
 const (
-	EventRequested EventType = "REQUESTED"
-	EventApproved  EventType = "APPROVED"
-	EventExecuted  EventType = "EXECUTED"
-	EventVerified  EventType = "VERIFIED"
-	EventFailed    EventType = "FAILED"
+	EventRequested  EventType = "REQUESTED"
+	EventApproved   EventType = "APPROVED"
+	EventExecuted   EventType = "EXECUTED"
+	EventVerified   EventType = "VERIFIED"
+	EventFailed     EventType = "FAILED"
+	EventCancelled  EventType = "CANCELLED"
+	EventRolledBack EventType = "ROLLED_BACK"
 )
 
 type ActorRole string
@@ -35,15 +37,16 @@ type Target struct {
 }
 
 type Command struct {
-	Kind       string            `json:"kind"`                    // e.g. "cli", "netconf", "rest"
-	Raw        string            `json:"raw"`                     // exact command or payload (store securely!)
-	Diff       string            `json:"diff,omitempty"`          // config diff if applicable
-	Output     string            `json:"output,omitempty"`        // sanitized output
-	OutputMeta map[string]string `json:"output_meta,omitempty"`   // output metadata
+	Kind       string            `json:"kind"`                  // e.g. "cli", "netconf", "rest"
+	Raw        string            `json:"raw"`                   // exact command or payload (store securely!)
+	Diff       string            `json:"diff,omitempty"`        // config diff if applicable
+	Output     string            `json:"output,omitempty"`      // sanitized output
+	OutputMeta map[string]string `json:"output_meta,omitempty"` // output metadata
+	Targets    []Target          `json:"targets,omitempty"`     // subset of the trail's targets this command hit
 }
 
 type Result struct {
-	Status   string `json:"status"`              // "SUCCESS" / "FAILED" / "PARTIAL"
+	Status   string `json:"status"` // "SUCCESS" / "FAILED" / "PARTIAL"
 	Message  string `json:"message,omitempty"`
 	ExitCode *int   `json:"exit_code,omitempty"`
 }
@@ -65,10 +68,19 @@ type Event struct {
 	Result        *Result    `json:"result,omitempty"`
 	Evidence      []Evidence `json:"evidence,omitempty"`
 	CorrelationID string     `json:"correlation_id,omitempty"`
+	TrailHash     string     `json:"trail_hash,omitempty"` // REQUESTED only: commits to the Trail header
 
 	// immutability / tamper-evidence
-	PrevHash string `json:"prev_hash,omitempty"`
-	Hash     string `json:"hash,omitempty"`
+	PrevHash    string `json:"prev_hash,omitempty"`
+	Hash        string `json:"hash,omitempty"`
+	HashVersion int    `json:"hash_version,omitempty"` // scheme Hash was computed with; 0 means HashV1
+
+	// detached signature over Hash (not part of the hash itself)
+	KeyID     string `json:"key_id,omitempty"`
+	Signature string `json:"signature,omitempty"`
+
+	// position in the global ledger, assigned by the store (not hashed)
+	Seq int64 `json:"seq,omitempty"`
 }
 
 // Trail groups multiple events for one logical change (one "write request").
@@ -2,15 +2,33 @@ package audit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 )
 
+// Verification failure kinds, for errors.Is on a *VerifyError.
+var (
+	// ErrChainBroken means an event does not point at its predecessor's hash
+	// (deleted, re-ordered or inserted events).
+	ErrChainBroken = errors.New("PrevHash mismatch")
+	// ErrHashMismatch means an event's fields no longer match its hash.
+	ErrHashMismatch = errors.New("hash mismatch")
+	// ErrBadSignature means an event is unsigned or its signature is invalid.
+	ErrBadSignature = errors.New("signature invalid")
+	// ErrTrailHeaderMismatch means the stored Trail header (title, description,
+	// targets, ...) no longer matches the hash its REQUESTED event committed to.
+	ErrTrailHeaderMismatch = errors.New("trail header hash mismatch")
+)
+
 // VerifyError tells you exactly what failed and where.
 type VerifyError struct {
 	TrailID string
 	EventID string
 	Index   int
 	Reason  string
+
+	// Err, if set, classifies the failure for errors.Is.
+	Err error
 }
 
 func (e *VerifyError) Error() string {
@@ -19,16 +37,75 @@ func (e *VerifyError) Error() string {
 	)
 }
 
-// VerifyTrail checks the hash chain for a trail.
+func (e *VerifyError) Unwrap() error { return e.Err }
+
+// VerifyTrail checks the hash chain for a trail and returns the first failure.
 // It detects:
 // - edits to any event fields (hash mismatch)
 // - deleted/re-ordered events (PrevHash mismatch)
 // - inserted events in the middle (PrevHash mismatch)
+// - rewritten trails with recomputed hashes (signature mismatch, needs WithKeyResolver)
+// - edits to the Trail header (ErrTrailHeaderMismatch)
+// - trails whose events were all deleted (ErrChainBroken)
 func (s *Service) VerifyTrail(ctx context.Context, trailID string) error {
-	_, events, err := s.store.GetTrail(ctx, trailID)
+	trail, events, err := s.store.GetTrail(ctx, trailID)
+	if err != nil {
+		return err
+	}
+
+	if len(events) == 0 {
+		return noEvents(trailID)
+	}
+
+	failures, err := s.checkEvents(ctx, trailID, trail, events, true)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return failures[0]
+	}
+	return nil
+}
+
+// noEvents is the failure of a stored trail without events. Request never
+// leaves one behind, so its REQUESTED event, which commits to the header,
+// was deleted.
+func noEvents(trailID string) *VerifyError {
+	return &VerifyError{
+		TrailID: trailID,
+		Reason:  "trail has no events; its REQUESTED event is missing",
+		Err:     ErrChainBroken,
+	}
+}
+
+// VerifyEvents runs VerifyTrail's checks on a trail read from somewhere
+// other than a Service's store, such as a remote server. Signatures are
+// required and checked only if keys is not nil.
+func VerifyEvents(ctx context.Context, trail Trail, events []Event, keys KeyResolver) error {
+	failures, err := (&Service{keys: keys}).checkEvents(ctx, trail.ID, trail, events, true)
 	if err != nil {
 		return err
 	}
+	if len(failures) > 0 {
+		return failures[0]
+	}
+	return nil
+}
+
+// checkEvents runs every check on every event and returns the failures in
+// order. With failFast it stops at the first one.
+func (s *Service) checkEvents(ctx context.Context, trailID string, trail Trail, events []Event, failFast bool) ([]*VerifyError, error) {
+	var failures []*VerifyError
+	fail := func(i int, ev Event, kind error, reason string) bool {
+		failures = append(failures, &VerifyError{
+			TrailID: trailID,
+			EventID: ev.ID,
+			Index:   i,
+			Reason:  reason,
+			Err:     kind,
+		})
+		return failFast
+	}
 
 	var prevHash string
 
@@ -37,46 +114,78 @@ func (s *Service) VerifyTrail(ctx context.Context, trailID string) error {
 		if i == 0 {
 			// first event must not point to anything
 			if ev.PrevHash != "" {
-				return &VerifyError{
-					TrailID: trailID,
-					EventID: ev.ID,
-					Index:   i,
-					Reason:  "first event PrevHash must be empty",
+				if fail(i, ev, ErrChainBroken, "first event PrevHash must be empty") {
+					return failures, nil
 				}
 			}
 		} else {
 			if ev.PrevHash != prevHash {
-				return &VerifyError{
-					TrailID: trailID,
-					EventID: ev.ID,
-					Index:   i,
-					Reason:  fmt.Sprintf("PrevHash mismatch (expected %s, got %s)", short(prevHash), short(ev.PrevHash)),
+				if fail(i, ev, ErrChainBroken, fmt.Sprintf("PrevHash mismatch (expected %s, got %s)", short(prevHash), short(ev.PrevHash))) {
+					return failures, nil
 				}
 			}
 		}
 
 		// 2) Recompute the hash
 		// IMPORTANT: ComputeEventHash does NOT use the Event.Hash field,
-		// so we can compute directly from ev.
+		// so we can compute directly from ev. It uses the scheme recorded in
+		// ev.HashVersion, so old and new schemes can share one chain.
 		expectedHash, err := ComputeEventHash(ev)
 		if err != nil {
-			return err
+			if fail(i, ev, ErrHashMismatch, fmt.Sprintf("cannot compute hash: %v", err)) {
+				return failures, nil
+			}
+		} else if ev.Hash != expectedHash {
+			// 3) Compare stored hash to computed hash
+			if fail(i, ev, ErrHashMismatch, fmt.Sprintf("Hash mismatch (expected %s, got %s)", short(expectedHash), short(ev.Hash))) {
+				return failures, nil
+			}
+		}
+
+		// 4) Check the Trail header against the hash the event committed to.
+		// Trails requested before headers were hashed have no TrailHash.
+		if ev.TrailHash != "" {
+			headerHash, err := ComputeTrailHash(trail)
+			if err != nil {
+				return nil, err
+			}
+			if headerHash != ev.TrailHash {
+				if fail(i, ev, ErrTrailHeaderMismatch, fmt.Sprintf("%s (expected %s, got %s)", ErrTrailHeaderMismatch, short(ev.TrailHash), short(headerHash))) {
+					return failures, nil
+				}
+			}
 		}
 
-		// 3) Compare stored hash to computed hash
-		if ev.Hash != expectedHash {
-			return &VerifyError{
-				TrailID: trailID,
-				EventID: ev.ID,
-				Index:   i,
-				Reason:  fmt.Sprintf("Hash mismatch (expected %s, got %s)", short(expectedHash), short(ev.Hash)),
+		// 5) Check the signature over the hash
+		if s.keys != nil {
+			if reason := s.verifySignature(ctx, ev); reason != "" {
+				if fail(i, ev, ErrBadSignature, reason) {
+					return failures, nil
+				}
 			}
 		}
 
+		// Chain on the stored hash, so one edited event is reported once
+		// rather than as a break at every later event.
 		prevHash = ev.Hash
 	}
 
-	return nil
+	return failures, nil
+}
+
+// verifySignature returns why ev's signature is not valid, or "" if it is.
+func (s *Service) verifySignature(ctx context.Context, ev Event) string {
+	if ev.Signature == "" {
+		return "missing signature"
+	}
+	pub, err := s.keys.ResolveKey(ctx, ev.KeyID)
+	if err != nil {
+		return fmt.Sprintf("cannot resolve key %q: %v", ev.KeyID, err)
+	}
+	if err := VerifyEventSignature(ev, pub); err != nil {
+		return fmt.Sprintf("invalid signature (key %q): %v", ev.KeyID, err)
+	}
+	return ""
 }
 
 // short is just for readable errors/logs.
@@ -2,6 +2,7 @@ package audit_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -85,6 +86,9 @@ func TestVerifyTrailDetectsTampering(t *testing.T) {
 	}
 
 	// Create at least 2 events so chain exists
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
 	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1", Role: audit.RoleExecutor}, "corr",
 		[]audit.Command{{Kind: "cli", Raw: "do thing", Output: "OK"}},
 		audit.Result{Status: "SUCCESS"},
@@ -116,3 +120,40 @@ func (t *tamperingStore) GetTrail(ctx context.Context, trailID string) (audit.Tr
 	}
 	return tr, evs, nil
 }
+
+func TestVerifyTrailDetectsEditedTrailHeader(t *testing.T) {
+	ctx := context.Background()
+
+	st := memory.New()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Update NTP",
+		Requester: audit.Actor{ID: "u-1"},
+		Targets:   []audit.Target{{Type: "network_device", ID: "sw-12", Labels: map[string]string{"site": "dc1"}}},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail should pass, got error: %v", err)
+	}
+
+	retitled := &retitlingStore{Store: st}
+	err = audit.NewService(retitled, audit.NoopSanitizer{}).VerifyTrail(ctx, trailID)
+	if !errors.Is(err, audit.ErrTrailHeaderMismatch) {
+		t.Fatalf("expected ErrTrailHeaderMismatch, got %v", err)
+	}
+}
+
+// retitlingStore changes the stored title of every trail.
+type retitlingStore struct{ audit.Store }
+
+func (r *retitlingStore) GetTrail(ctx context.Context, trailID string) (audit.Trail, []audit.Event, error) {
+	tr, evs, err := r.Store.GetTrail(ctx, trailID)
+	if err != nil {
+		return audit.Trail{}, nil, err
+	}
+	tr.Title = "Open firewall"
+	return tr, evs, nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/ajazfarhad/provenance"
+	"github.com/ajazfarhad/provenance/redact"
 	"github.com/ajazfarhad/provenance/store/memory"
 )
 
@@ -13,7 +14,7 @@ func main() {
 	ctx := context.Background()
 
 	st := memory.New()
-	svc := provenance.New(st, provenance.WithSanitizer(redactingSanitizer{}))
+	svc := provenance.New(st, provenance.WithSanitizer(redact.New()))
 
 	reqActor := provenance.Actor{ID: "u-1", Name: "Ahmed", Role: provenance.RoleRequester}
 	target := provenance.Target{
@@ -45,7 +46,10 @@ func main() {
 
 	must(svc.Execute(ctx, trailID, provenance.Actor{ID: "svc-spectre", Name: "Spectre"},
 		"req-abc-123",
-		[]provenance.Command{{Kind: "cli", Raw: "ntp server 10.0.0.10", Diff: "+ ntp server 10.0.0.10", Output: "OK"}},
+		[]provenance.Command{
+			{Kind: "cli", Raw: "ntp server 10.0.0.10", Diff: "+ ntp server 10.0.0.10", Output: "OK"},
+			{Kind: "cli", Raw: "snmp-server community s3cr3t RO", Output: "OK"},
+		},
 		provenance.Result{Status: "SUCCESS", Message: "Applied"},
 	))
 	printSanitizedOutput(ctx, st, trailID)
@@ -89,24 +93,16 @@ func printSanitizedOutput(ctx context.Context, st *memory.Store, trailID string)
 	must(err)
 
 	for _, ev := range events {
-		if len(ev.Commands) == 0 {
-			continue
+		for _, c := range ev.Commands {
+			fmt.Printf("Sanitized command: %s", c.Raw)
+			if rules := c.OutputMeta[redact.MetaKey]; rules != "" {
+				fmt.Printf(" (redacted by %s)", rules)
+			}
+			fmt.Println()
+		}
+		if len(ev.Commands) > 0 {
+			return
 		}
-		fmt.Println("Sanitized command:", ev.Commands[0].Raw)
-		return
 	}
 	fmt.Println("Sanitized command: <none>")
 }
-
-type redactingSanitizer struct{}
-
-func (redactingSanitizer) SanitizeTargets(targets []provenance.Target) []provenance.Target {
-	return targets
-}
-
-func (redactingSanitizer) SanitizeCommands(cmds []provenance.Command) []provenance.Command {
-	for i := range cmds {
-		cmds[i].Raw = "[redacted]"
-	}
-	return cmds
-}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ajazfarhad/provenance"
+)
+
+// listFlag collects a repeatable flag.
+type listFlag []string
+
+func (l *listFlag) String() string     { return strings.Join(*l, ",") }
+func (l *listFlag) Set(v string) error { *l = append(*l, v); return nil }
+
+func eventsQuery(ctx context.Context, args []string, w io.Writer) error {
+	var c commonFlags
+	var targets, types, trails listFlag
+	fs := newFlagSet("events query", &c)
+	fs.Var(&targets, "target", "events touching this target, as type:id (repeatable)")
+	fs.Var(&types, "type", "event type, e.g. EXECUTED (repeatable)")
+	fs.Var(&trails, "trail", "events of this trail (repeatable)")
+	since := fs.String("since", "", "at or after: a duration such as 24h or 7d, or an RFC 3339 time")
+	until := fs.String("until", "", "before: a duration or an RFC 3339 time")
+	actor := fs.String("actor", "", "actor ID")
+	correlation := fs.String("correlation", "", "correlation ID")
+	status := fs.String("status", "", "result status, e.g. FAILED")
+	order := fs.String("order", "desc", "desc (newest first) or asc")
+	limit := fs.Int("limit", 100, "maximum events per page")
+	cursor := fs.String("cursor", "", "cursor of the next page, from a previous run")
+	if _, err := parse(fs, &c, args); err != nil {
+		return err
+	}
+
+	q := provenance.Query{
+		ActorID:       *actor,
+		CorrelationID: *correlation,
+		TrailIDs:      trails,
+		ResultStatus:  *status,
+		Order:         provenance.Order(*order),
+		Limit:         *limit,
+		Cursor:        *cursor,
+	}
+	if q.Order != provenance.OrderAsc && q.Order != provenance.OrderDesc {
+		return usagef("--order must be asc or desc")
+	}
+	for _, t := range types {
+		q.EventTypes = append(q.EventTypes, provenance.EventType(strings.ToUpper(t)))
+	}
+	for _, s := range targets {
+		t, err := parseTarget(s)
+		if err != nil {
+			return usagef("--target: %v", err)
+		}
+		q.Targets = append(q.Targets, t)
+	}
+	var err error
+	if q.From, err = parseTime(*since); err != nil {
+		return usagef("--since: %v", err)
+	}
+	if q.To, err = parseTime(*until); err != nil {
+		return usagef("--until: %v", err)
+	}
+
+	svc, closeDB, err := open(ctx, c.db)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	page, err := svc.QueryEvents(ctx, q)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return writeJSON(w, page)
+	}
+
+	tw := newTable(w, "SEQ", "AT", "TYPE", "TRAIL", "ACTOR", "TARGETS", "DETAILS")
+	for _, e := range page.Events {
+		row(tw, fmt.Sprint(e.Seq), formatTime(e.At), string(e.Type), e.TrailID, e.Actor.ID, formatTargets(e.Targets), summary(e))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	nextPage(w, page.NextCursor)
+	return nil
+}
+
+func parseTarget(s string) (provenance.Target, error) {
+	typ, id, ok := strings.Cut(s, ":")
+	if !ok || typ == "" || id == "" {
+		return provenance.Target{}, fmt.Errorf("%q is not type:id", s)
+	}
+	return provenance.Target{Type: typ, ID: id}, nil
+}
+
+// now is replaced in tests.
+var now = time.Now
+
+// parseTime accepts a duration before now (24h, 90m, 7d) or an RFC 3339
+// time, and returns it in UTC. "" is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("%q is not a number of days", s)
+		}
+		return now().AddDate(0, 0, -n).UTC(), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, errors.New("want a duration such as 24h or 7d, or an RFC 3339 time")
+	}
+	return now().Add(-d).UTC(), nil
+}
@@ -0,0 +1,141 @@
+// Command provenance inspects and verifies audit trails in a SQLite file or
+// Postgres database without writing Go.
+//
+//	provenance trail list --since 7d --status EXECUTED
+//	provenance trail show <trail>
+//	provenance events query --target network_device:sw-12 --since 24h
+//	provenance verify <trail>
+//	provenance verify --all
+//
+// The database is given by --db or PROVENANCE_DB: a postgres:// URL or
+// key=value DSN, or a SQLite file path. Every command prints a table, or
+// JSON with -o json. It only reads; verify does not append VERIFIED events.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+)
+
+// errFailed means the command ran but found a problem (a trail that does
+// not verify); the details are already printed.
+var errFailed = errors.New("verification failed")
+
+const usage = `usage: provenance <command> [flags]
+
+commands:
+  trail list            list trails, newest first
+  trail show <trail>    show a trail and its events
+  events query          list events matching filters
+  verify <trail>        verify one trail's hash chain
+  verify --all          verify every trail and the global ledger
+
+Run "provenance <command> -h" for the command's flags.
+`
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes one command and returns the exit status: 0 on success, 1 if
+// the command failed and 2 on a usage error.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var cmd func(context.Context, []string, io.Writer) error
+	name := args[0]
+	switch {
+	case name == "trail" && len(args) > 1 && args[1] == "list":
+		cmd, name, args = trailList, "trail list", args[2:]
+	case name == "trail" && len(args) > 1 && args[1] == "show":
+		cmd, name, args = trailShow, "trail show", args[2:]
+	case name == "events" && len(args) > 1 && args[1] == "query":
+		cmd, name, args = eventsQuery, "events query", args[2:]
+	case name == "verify":
+		cmd, args = verify, args[1:]
+	case name == "help" || name == "-h" || name == "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "provenance: unknown command %q\n\n%s", name, usage)
+		return 2
+	}
+
+	err := cmd(ctx, args, stdout)
+	var uerr usageError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &uerr):
+		fmt.Fprintf(stderr, "provenance %s: %v\n", name, err)
+		return 2
+	case errors.Is(err, errFailed):
+		return 1
+	default:
+		fmt.Fprintf(stderr, "provenance %s: %v\n", name, err)
+		return 1
+	}
+}
+
+// usageError is a bad flag or argument.
+type usageError struct{ err error }
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Errorf(format, args...)}
+}
+
+// commonFlags are accepted by every command.
+type commonFlags struct {
+	db     string
+	output string
+}
+
+func newFlagSet(name string, c *commonFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&c.db, "db", os.Getenv("PROVENANCE_DB"), "postgres DSN or SQLite file (default $PROVENANCE_DB)")
+	fs.StringVar(&c.output, "o", "table", "output format: table or json")
+	return fs
+}
+
+// parse parses flags that may appear before or after positional arguments
+// and returns the positional ones.
+func parse(fs *flag.FlagSet, c *commonFlags, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, usageError{err}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+
+	if c.output != "table" && c.output != "json" {
+		return nil, usagef("unknown output format %q", c.output)
+	}
+	if c.db == "" {
+		return nil, usagef("no database: pass --db or set PROVENANCE_DB")
+	}
+	return pos, nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajazfarhad/provenance"
+	"github.com/ajazfarhad/provenance/store/sqlite"
+)
+
+// seed creates a SQLite file with one executed change on sw-12 and one
+// requested change on sw-13.
+func seed(t *testing.T) (path, trailID string) {
+	t.Helper()
+	ctx := context.Background()
+	path = filepath.Join(t.TempDir(), "audit.db")
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer db.Close()
+	if err := sqlite.Migrate(ctx, db); err != nil {
+		t.Fatalf("Migrate error: %v", err)
+	}
+	svc := provenance.New(sqlite.New(db))
+
+	trailID, err = svc.Request(ctx, provenance.RequestInput{
+		Title:     "Update NTP",
+		Requester: provenance.Actor{ID: "u-1"},
+		Targets:   []provenance.Target{{Type: "network_device", ID: "sw-12"}},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, provenance.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, provenance.Actor{ID: "svc-1"}, "corr",
+		[]provenance.Command{{Kind: "cli", Raw: "ntp server 10.0.0.1"}},
+		provenance.Result{Status: "SUCCESS"},
+	); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if _, err := svc.Request(ctx, provenance.RequestInput{
+		Title:     "Reboot",
+		Requester: provenance.Actor{ID: "u-3"},
+		Targets:   []provenance.Target{{Type: "network_device", ID: "sw-13"}},
+	}); err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	return path, trailID
+}
+
+func runCLI(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestEventsQueryByTarget(t *testing.T) {
+	db, trailID := seed(t)
+
+	out, stderr, code := runCLI(t, "events", "query", "--db", db, "--target", "network_device:sw-12", "--since", "24h")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "SEQ") || !strings.Contains(lines[1], "EXECUTED") || !strings.Contains(lines[1], `"ntp server 10.0.0.1" SUCCESS`) {
+		t.Fatalf("unexpected table:\n%s", out)
+	}
+
+	out, _, _ = runCLI(t, "events", "query", "--db", db, "--target", "network_device:sw-12", "-o", "json", "--limit", "2")
+	var page provenance.EventPage
+	if err := json.Unmarshal([]byte(out), &page); err != nil {
+		t.Fatalf("not JSON: %v\n%s", err, out)
+	}
+	if len(page.Events) != 2 || page.Events[0].TrailID != trailID || page.NextCursor == "" {
+		t.Fatalf("unexpected page %+v", page)
+	}
+}
+
+func TestTrailListAndShow(t *testing.T) {
+	db, trailID := seed(t)
+
+	out, stderr, code := runCLI(t, "trail", "list", "--db", db, "--status", "EXECUTED")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if !strings.Contains(out, trailID) || strings.Contains(out, "Reboot") {
+		t.Fatalf("unexpected list:\n%s", out)
+	}
+
+	// Flags may follow the trail ID.
+	out, stderr, code = runCLI(t, "trail", "show", trailID, "--db", db, "-o", "json")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	var trail struct {
+		provenance.Trail
+		Events []provenance.Event `json:"events"`
+	}
+	if err := json.Unmarshal([]byte(out), &trail); err != nil {
+		t.Fatalf("not JSON: %v\n%s", err, out)
+	}
+	if trail.ID != trailID || len(trail.Events) != 3 {
+		t.Fatalf("unexpected trail %+v", trail)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	db, trailID := seed(t)
+
+	out, stderr, code := runCLI(t, "verify", trailID, "--db", db)
+	if code != 0 || !strings.Contains(out, "OK") {
+		t.Fatalf("exit %d: %s%s", code, out, stderr)
+	}
+
+	out, _, code = runCLI(t, "verify", "--all", "--db", db, "-o", "json")
+	var results []verifyResult
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		t.Fatalf("not JSON: %v\n%s", err, out)
+	}
+	if code != 0 || len(results) != 3 {
+		t.Fatalf("exit %d, results %+v", code, results)
+	}
+
+	// Tamper with the executed command.
+	sqlDB, err := sql.Open("sqlite", db)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := sqlDB.Exec(`UPDATE audit_events SET commands = '[{"kind":"cli","raw":"no ntp"}]' WHERE type = 'EXECUTED'`); err != nil {
+		t.Fatalf("tamper: %v", err)
+	}
+	sqlDB.Close()
+
+	out, _, code = runCLI(t, "verify", "--all", "--db", db)
+	if code != 1 || !strings.Contains(out, "FAILED") || !strings.Contains(out, "Hash mismatch") {
+		t.Fatalf("expected a failed verification, exit %d:\n%s", code, out)
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"frobnicate"},
+		{"verify", "--db", "x.db"},
+		{"events", "query", "--db", "x.db", "--target", "sw-12"},
+		{"events", "query", "--db", "x.db", "--since", "yesterday"},
+		{"trail", "list", "--db", "x.db", "-o", "yaml"},
+	} {
+		if _, _, code := runCLI(t, args...); code != 2 {
+			t.Errorf("%q: expected exit 2, got %d", args, code)
+		}
+	}
+
+	// A missing file is an error, not a new empty database.
+	missing := filepath.Join(t.TempDir(), "missing.db")
+	if _, _, code := runCLI(t, "trail", "list", "--db", missing); code != 1 {
+		t.Fatalf("expected exit 1 for a missing database, got %d", code)
+	}
+}
+
+func TestSQLiteDSNWithParameters(t *testing.T) {
+	db, trailID := seed(t)
+
+	for _, dsn := range []string{
+		"file:" + db + "?_pragma=busy_timeout(100)",
+		"file:" + db + "?_pragma=foreign_keys(1)&mode=rw",
+	} {
+		out, stderr, code := runCLI(t, "verify", trailID, "--db", dsn)
+		if code != 0 || !strings.Contains(out, "OK") {
+			t.Fatalf("%s: exit %d: %s%s", dsn, code, out, stderr)
+		}
+	}
+
+	// mode=ro wins over a DSN that asks for more.
+	ro, err := readOnlySQLite("file:" + db + "?mode=rwc")
+	if err != nil {
+		t.Fatalf("readOnlySQLite error: %v", err)
+	}
+	sqlDB, err := sql.Open("sqlite", ro)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer sqlDB.Close()
+	if _, err := sqlDB.Exec(`DELETE FROM audit_events`); err == nil {
+		t.Fatalf("expected %s to be read-only", ro)
+	}
+}
+
+func TestParseTimeReturnsUTC(t *testing.T) {
+	for _, s := range []string{"2026-10-01T00:00:00+02:00", "24h", "7d"} {
+		got, err := parseTime(s)
+		if err != nil {
+			t.Fatalf("parseTime(%q) error: %v", s, err)
+		}
+		if got.Location() != time.UTC {
+			t.Fatalf("parseTime(%q) = %v, not in UTC", s, got)
+		}
+	}
+	if got, _ := parseTime("2026-10-01T00:00:00+02:00"); !got.Equal(time.Date(2026, 9, 30, 22, 0, 0, 0, time.UTC)) {
+		t.Fatalf("parseTime kept the wrong instant: %v", got)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ajazfarhad/provenance"
+)
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func newTable(w io.Writer, header ...string) *tabwriter.Writer {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	return tw
+}
+
+func row(tw *tabwriter.Writer, cols ...string) {
+	for i, c := range cols {
+		if c == "" {
+			cols[i] = "-"
+		}
+	}
+	fmt.Fprintln(tw, strings.Join(cols, "\t"))
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatTargets(ts []provenance.Target) string {
+	s := make([]string, len(ts))
+	for i, t := range ts {
+		s[i] = t.Type + ":" + t.ID
+	}
+	return strings.Join(s, ",")
+}
+
+// summary is a one-line description of what an event did.
+func summary(e provenance.Event) string {
+	var parts []string
+	for _, c := range e.Commands {
+		parts = append(parts, strconv.Quote(c.Raw))
+	}
+	if e.Result != nil {
+		r := e.Result.Status
+		if e.Result.Message != "" {
+			r += ": " + e.Result.Message
+		}
+		parts = append(parts, r)
+	}
+	for _, ev := range e.Evidence {
+		parts = append(parts, ev.Kind+"="+ev.Ref)
+	}
+	return strings.Join(parts, " ")
+}
+
+func short(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// nextPage tells table readers how to fetch the rest.
+func nextPage(w io.Writer, cursor string) {
+	if cursor != "" {
+		fmt.Fprintf(w, "\nmore results: --cursor %s\n", cursor)
+	}
+}
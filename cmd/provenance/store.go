@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+
+	"github.com/ajazfarhad/provenance"
+	"github.com/ajazfarhad/provenance/store/postgres"
+	"github.com/ajazfarhad/provenance/store/sqlite"
+)
+
+// isPostgres reports whether dsn is a Postgres URL or key=value DSN rather
+// than a SQLite file path.
+func isPostgres(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") ||
+		strings.HasPrefix(dsn, "postgresql://") ||
+		strings.Contains(dsn, "host=") ||
+		strings.Contains(dsn, "dbname=")
+}
+
+// readOnlySQLite turns dsn, a SQLite path or file: URI with optional query
+// parameters, into a URI that opens the existing file read-only, so a typo
+// cannot create an empty database. Parameters in dsn are kept, and a
+// busy_timeout is added unless dsn sets one.
+func readOnlySQLite(dsn string) (string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", fmt.Errorf("sqlite dsn: %w", err)
+	}
+	if u.Scheme != "" && u.Scheme != "file" {
+		return "", fmt.Errorf("sqlite dsn: unsupported scheme %q", u.Scheme)
+	}
+	path := u.Path
+	if u.Opaque != "" { // file:relative/path
+		if path, err = url.PathUnescape(u.Opaque); err != nil {
+			return "", fmt.Errorf("sqlite dsn: %w", err)
+		}
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("mode", "ro")
+	if !slices.ContainsFunc(q["_pragma"], func(p string) bool { return strings.HasPrefix(p, "busy_timeout") }) {
+		q.Add("_pragma", "busy_timeout(5000)")
+	}
+	// Opaque, not Path: file://x.db would name a host.
+	ro := url.URL{Scheme: "file", Opaque: (&url.URL{Path: path}).EscapedPath(), RawQuery: q.Encode()}
+	return ro.String(), nil
+}
+
+// open connects to the database named by dsn. The schema must already
+// exist; the CLI never migrates.
+func open(ctx context.Context, dsn string, opts ...provenance.Option) (*provenance.Client, func() error, error) {
+	driver := "sqlite"
+	if isPostgres(dsn) {
+		driver = "postgres"
+	} else {
+		var err error
+		if dsn, err = readOnlySQLite(dsn); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	var st provenance.Store
+	if driver == "postgres" {
+		st = postgres.New(db)
+	} else {
+		st = sqlite.New(db)
+	}
+	return provenance.New(st, opts...), db.Close, nil
+}
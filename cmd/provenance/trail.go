@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/ajazfarhad/provenance"
+)
+
+func trailList(ctx context.Context, args []string, w io.Writer) error {
+	var c commonFlags
+	fs := newFlagSet("trail list", &c)
+	since := fs.String("since", "", "created at or after: a duration such as 24h or 7d, or an RFC 3339 time")
+	until := fs.String("until", "", "created before: a duration or an RFC 3339 time")
+	target := fs.String("target", "", "trails naming this target, as type:id")
+	status := fs.String("status", "", "latest event type, e.g. EXECUTED")
+	title := fs.String("title", "", "title contains (case-insensitive)")
+	requester := fs.String("requester", "", "requester actor ID")
+	correlation := fs.String("correlation", "", "correlation ID")
+	limit := fs.Int("limit", 50, "maximum trails per page")
+	cursor := fs.String("cursor", "", "cursor of the next page, from a previous run")
+	if _, err := parse(fs, &c, args); err != nil {
+		return err
+	}
+
+	q := provenance.TrailQuery{
+		TitleContains: *title,
+		CorrelationID: *correlation,
+		RequesterID:   *requester,
+		Status:        provenance.EventType(*status),
+		Limit:         *limit,
+		Cursor:        *cursor,
+	}
+	var err error
+	if q.From, err = parseTime(*since); err != nil {
+		return usagef("--since: %v", err)
+	}
+	if q.To, err = parseTime(*until); err != nil {
+		return usagef("--until: %v", err)
+	}
+	if *target != "" {
+		t, err := parseTarget(*target)
+		if err != nil {
+			return usagef("--target: %v", err)
+		}
+		q.TargetType, q.TargetID = t.Type, t.ID
+	}
+
+	svc, closeDB, err := open(ctx, c.db)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	page, err := svc.ListTrails(ctx, q)
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return writeJSON(w, page)
+	}
+
+	tw := newTable(w, "CREATED", "TRAIL", "STATUS", "REQUESTER", "TARGETS", "TITLE")
+	for _, t := range page.Trails {
+		row(tw, formatTime(t.CreatedAt), t.ID, string(t.Status), t.Requester.ID, formatTargets(t.Targets), t.Title)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	nextPage(w, page.NextCursor)
+	return nil
+}
+
+func trailShow(ctx context.Context, args []string, w io.Writer) error {
+	var c commonFlags
+	fs := newFlagSet("trail show", &c)
+	pos, err := parse(fs, &c, args)
+	if err != nil {
+		return err
+	}
+	if len(pos) != 1 {
+		return usagef("want exactly one trail ID")
+	}
+
+	svc, closeDB, err := open(ctx, c.db)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	trail, events, err := svc.GetTrail(ctx, pos[0])
+	if err != nil {
+		return err
+	}
+	if c.output == "json" {
+		return writeJSON(w, struct {
+			provenance.Trail
+			Events []provenance.Event `json:"events"`
+		}{trail, events})
+	}
+
+	fmt.Fprintf(w, "Trail:       %s\n", trail.ID)
+	fmt.Fprintf(w, "Title:       %s\n", trail.Title)
+	if trail.Description != "" {
+		fmt.Fprintf(w, "Description: %s\n", trail.Description)
+	}
+	fmt.Fprintf(w, "Created:     %s\n", formatTime(trail.CreatedAt))
+	if trail.CorrelationID != "" {
+		fmt.Fprintf(w, "Correlation: %s\n", trail.CorrelationID)
+	}
+	fmt.Fprintf(w, "Targets:     %s\n\n", formatTargets(trail.Targets))
+
+	tw := newTable(w, "SEQ", "AT", "TYPE", "ACTOR", "TARGETS", "HASH", "DETAILS")
+	for _, e := range events {
+		row(tw, fmt.Sprint(e.Seq), formatTime(e.At), string(e.Type), e.Actor.ID, formatTargets(e.Targets), short(e.Hash), summary(e))
+	}
+	return tw.Flush()
+}
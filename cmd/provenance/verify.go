@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ajazfarhad/provenance"
+)
+
+// verifyResult is one trail's outcome, or the ledger's when TrailID is "".
+type verifyResult struct {
+	TrailID  string    `json:"trail_id,omitempty"`
+	OK       bool      `json:"ok"`
+	Events   int       `json:"events,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Failures []failure `json:"failures,omitempty"`
+}
+
+type failure struct {
+	Index   int    `json:"index"`
+	EventID string `json:"event_id"`
+	Reason  string `json:"reason"`
+}
+
+func verify(ctx context.Context, args []string, w io.Writer) error {
+	var c commonFlags
+	var keys listFlag
+	fs := newFlagSet("verify", &c)
+	all := fs.Bool("all", false, "verify every trail and the global ledger")
+	workers := fs.Int("workers", 8, "trails verified concurrently with --all")
+	fs.Var(&keys, "key", "trusted Ed25519 public key as keyID=base64; events must then be signed (repeatable)")
+	pos, err := parse(fs, &c, args)
+	if err != nil {
+		return err
+	}
+	if *all == (len(pos) > 0) {
+		return usagef("pass trail IDs or --all")
+	}
+
+	var opts []provenance.Option
+	if len(keys) > 0 {
+		resolver, err := parseKeys(keys)
+		if err != nil {
+			return usagef("--key: %v", err)
+		}
+		opts = append(opts, provenance.WithKeyResolver(resolver))
+	}
+
+	svc, closeDB, err := open(ctx, c.db, opts...)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	var results []verifyResult
+	if *all {
+		for res := range svc.VerifyAll(ctx, *workers) {
+			results = append(results, fromReport(res.TrailID, res.Report, res.Err))
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ledger := verifyResult{OK: true}
+		if err := svc.VerifyLedger(ctx, 0, 0); err != nil {
+			ledger = verifyResult{Error: err.Error()}
+		}
+		results = append(results, ledger)
+	} else {
+		for _, id := range pos {
+			r, err := svc.VerifyTrailReport(ctx, id)
+			results = append(results, fromReport(id, r, err))
+		}
+	}
+
+	if c.output == "json" {
+		err = writeJSON(w, results)
+	} else {
+		err = verifyTable(w, results)
+	}
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if !r.OK {
+			return errFailed
+		}
+	}
+	return nil
+}
+
+func fromReport(trailID string, r *provenance.VerifyReport, err error) verifyResult {
+	if err != nil {
+		return verifyResult{TrailID: trailID, Error: err.Error()}
+	}
+	out := verifyResult{TrailID: trailID, OK: r.OK(), Events: r.Events}
+	for _, f := range r.Failures {
+		out.Failures = append(out.Failures, failure{Index: f.Index, EventID: f.EventID, Reason: f.Reason})
+	}
+	return out
+}
+
+func verifyTable(w io.Writer, results []verifyResult) error {
+	tw := newTable(w, "TRAIL", "EVENTS", "RESULT", "DETAILS")
+	for _, r := range results {
+		name, result := r.TrailID, "OK"
+		if name == "" {
+			name = "(ledger)"
+		}
+		if !r.OK {
+			result = "FAILED"
+		}
+
+		var details []string
+		if r.Error != "" {
+			details = append(details, r.Error)
+		}
+		for _, f := range r.Failures {
+			details = append(details, fmt.Sprintf("event %d (%s): %s", f.Index, short(f.EventID), f.Reason))
+		}
+		events := ""
+		if r.TrailID != "" {
+			events = fmt.Sprint(r.Events)
+		}
+		row(tw, name, events, result, strings.Join(details, "; "))
+	}
+	return tw.Flush()
+}
+
+func parseKeys(keys []string) (provenance.StaticKeys, error) {
+	out := make(provenance.StaticKeys)
+	for _, k := range keys {
+		id, b64, ok := strings.Cut(k, "=")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("%q is not keyID=base64", k)
+		}
+		pub, err := base64.StdEncoding.DecodeString(b64)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("key %s is not a base64 Ed25519 public key", id)
+		}
+		out[id] = ed25519.PublicKey(pub)
+	}
+	return out, nil
+}
@@ -0,0 +1,236 @@
+// Package redact is an audit.EventSanitizer that replaces secrets found by
+// regular expressions with placeholders, before events are hashed:
+//
+//	svc := provenance.New(st, provenance.WithSanitizer(redact.New()))
+//
+// The built-in rules (DefaultRules) cover secrets common in network and
+// infrastructure commands. Every free-text field is scanned, and map
+// values under sensitive keys such as "password" are replaced whole. The
+// names of the rules that fired in a command are recorded in its
+// OutputMeta under MetaKey.
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ajazfarhad/provenance/audit"
+)
+
+// MetaKey is the Command.OutputMeta key listing the rules that redacted
+// something in the command, comma-separated and sorted.
+const MetaKey = "redacted"
+
+// SensitiveFieldRule names redactions of whole map values, such as
+// Evidence.Detail["password"], made because of their key.
+const SensitiveFieldRule = "sensitive-field"
+
+// Rule finds one kind of secret. If Pattern has a subexpression named
+// "secret", only that part of each match is replaced; otherwise the whole
+// match is.
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// DefaultRules returns the built-in rules, in the order they are applied.
+func DefaultRules() []Rule {
+	return []Rule{
+		{"pem-private-key", regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY-----[\s\S]*?-----END (?:[A-Z0-9]+ )*PRIVATE KEY-----`)},
+		{"cisco-enable-secret", regexp.MustCompile(`(?i)\benable\s+(?:secret|password)\s+(?:level\s+\d+\s+)?(?:\d\s+)?(?P<secret>\S+)`)},
+		{"cisco-username-secret", regexp.MustCompile(`(?i)\busername\s+\S+(?:\s+privilege\s+\d+)?\s+(?:secret|password)\s+(?:\d\s+)?(?P<secret>\S+)`)},
+		{"cisco-password", regexp.MustCompile(`(?i)\b(?:password|secret|key)\s+\d\s+(?P<secret>\S+)`)},
+		{"snmp-community", regexp.MustCompile(`(?i)\b(?:snmp-server\s+community|r[ow]community6?)\s+(?P<secret>\S+)`)},
+		{"bearer-token", regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]+=*)`)},
+		{"aws-access-key-id", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{"aws-secret-access-key", regexp.MustCompile(`(?i)\baws_?secret_?access_?key["']?\s*[=:]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})`)},
+		{"url-credentials", regexp.MustCompile(`(?i)\b[a-z][a-z0-9+.\-]*://[^\s/:@]+:(?P<secret>[^\s/@]+)@`)},
+		{"json-secret", regexp.MustCompile(`(?i)"(?:password|passwd|secret|token|api[_-]?key|access[_-]?token|refresh[_-]?token|client[_-]?secret|private[_-]?key)"\s*:\s*"(?P<secret>(?:[^"\\]|\\.)*)"`)},
+	}
+}
+
+// sensitiveKey matches map keys whose values are secrets.
+var sensitiveKey = regexp.MustCompile(`(?i)^(?:password|passwd|secret|token|api[_-]?key|access[_-]?token|refresh[_-]?token|client[_-]?secret|private[_-]?key|community)$`)
+
+const placeholderPrefix = "[REDACTED:"
+
+// Sanitizer redacts secrets matched by its rules. Build it with New.
+type Sanitizer struct {
+	rules []Rule
+	key   []byte // for fingerprints; nil for none
+}
+
+var _ audit.EventSanitizer = (*Sanitizer)(nil)
+
+// Option configures a Sanitizer.
+type Option func(*Sanitizer)
+
+// WithRules adds rules, applied after the built-in ones.
+func WithRules(rules ...Rule) Option {
+	return func(s *Sanitizer) { s.rules = append(s.rules, rules...) }
+}
+
+// WithoutDefaults drops the built-in rules.
+func WithoutDefaults() Option {
+	return func(s *Sanitizer) { s.rules = nil }
+}
+
+// WithFingerprints adds a keyed fingerprint of each secret to its
+// placeholder, "[REDACTED:rule:1a2b3c4d]", so reviewers can tell whether two
+// events carried the same secret or a rotation really changed it. The key
+// keeps short or guessable secrets from being recovered by brute force;
+// keep it stable, or fingerprints change.
+func WithFingerprints(key []byte) Option {
+	return func(s *Sanitizer) { s.key = key }
+}
+
+// New returns a Sanitizer with DefaultRules, adjusted by opts. Options apply
+// in order, so WithoutDefaults goes before WithRules.
+func New(opts ...Option) *Sanitizer {
+	s := &Sanitizer{rules: DefaultRules()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Redact replaces every secret in text and returns the names of the rules
+// that fired, in rule order.
+func (s *Sanitizer) Redact(text string) (string, []string) {
+	var fired []string
+	for _, r := range s.rules {
+		var hit bool
+		text, hit = s.apply(r, text)
+		if hit {
+			fired = append(fired, r.Name)
+		}
+	}
+	return text, fired
+}
+
+func (s *Sanitizer) apply(r Rule, text string) (string, bool) {
+	matches := r.Pattern.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text, false
+	}
+	group := r.Pattern.SubexpIndex("secret")
+	if group < 0 {
+		group = 0
+	}
+
+	var b strings.Builder
+	last, hit := 0, false
+	for _, m := range matches {
+		start, end := m[2*group], m[2*group+1]
+		if start < 0 || start == end || strings.HasPrefix(text[start:end], placeholderPrefix) {
+			continue // already redacted by an earlier rule or pass
+		}
+		b.WriteString(text[last:start])
+		b.WriteString(s.placeholder(r.Name, text[start:end]))
+		last, hit = end, true
+	}
+	b.WriteString(text[last:])
+	return b.String(), hit
+}
+
+func (s *Sanitizer) placeholder(rule, secret string) string {
+	if s.key == nil {
+		return placeholderPrefix + rule + "]"
+	}
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(secret))
+	return placeholderPrefix + rule + ":" + hex.EncodeToString(mac.Sum(nil)[:4]) + "]"
+}
+
+// text redacts one field, replacing map values under sensitive keys whole.
+func (s *Sanitizer) text(field, text string) (string, []string) {
+	if i := strings.LastIndexByte(field, '.'); i >= 0 && isMapField(field) && sensitiveKey.MatchString(field[i+1:]) {
+		if strings.HasPrefix(text, placeholderPrefix) {
+			return text, nil
+		}
+		return s.placeholder(SensitiveFieldRule, text), []string{SensitiveFieldRule}
+	}
+	return s.Redact(text)
+}
+
+// isMapField reports whether field is a map value, whose last path segment
+// is the key.
+func isMapField(field string) bool {
+	for _, m := range []string{".meta.", ".labels.", ".detail.", ".output_meta."} {
+		if strings.Contains(field, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// SanitizeTrail redacts the trail's title, description and target labels.
+func (s *Sanitizer) SanitizeTrail(t audit.Trail) audit.Trail {
+	return audit.SanitizeTrailText(t, func(field, text string) string {
+		out, _ := s.text(field, text)
+		return out
+	})
+}
+
+// SanitizeEvent redacts every free-text field of e and records, in each
+// command's OutputMeta, the rules that fired in that command.
+func (s *Sanitizer) SanitizeEvent(e audit.Event) audit.Event {
+	fired := make(map[int]map[string]bool) // command index => rule names
+	e = audit.SanitizeEventText(e, func(field, text string) string {
+		out, rules := s.text(field, text)
+		if i, ok := commandIndex(field); ok && len(rules) > 0 {
+			if fired[i] == nil {
+				fired[i] = make(map[string]bool)
+			}
+			for _, r := range rules {
+				fired[i][r] = true
+			}
+		}
+		return out
+	})
+
+	for i, rules := range fired {
+		c := &e.Commands[i]
+		if prev := c.OutputMeta[MetaKey]; prev != "" {
+			for _, r := range strings.Split(prev, ",") {
+				rules[r] = true
+			}
+		}
+		names := make([]string, 0, len(rules))
+		for r := range rules {
+			names = append(names, r)
+		}
+		sort.Strings(names)
+
+		if c.OutputMeta == nil {
+			c.OutputMeta = make(map[string]string, 1)
+		}
+		c.OutputMeta[MetaKey] = strings.Join(names, ",")
+	}
+	return e
+}
+
+// commandIndex returns i for fields of command i, "commands.i.*".
+func commandIndex(field string) (int, bool) {
+	rest, ok := strings.CutPrefix(field, "commands.")
+	if !ok {
+		return 0, false
+	}
+	n, _, _ := strings.Cut(rest, ".")
+	i, err := strconv.Atoi(n)
+	return i, err == nil
+}
+
+// SanitizeTargets and SanitizeCommands make Sanitizer an audit.Sanitizer too.
+func (s *Sanitizer) SanitizeTargets(ts []audit.Target) []audit.Target {
+	return s.SanitizeTrail(audit.Trail{Targets: ts}).Targets
+}
+
+func (s *Sanitizer) SanitizeCommands(cmds []audit.Command) []audit.Command {
+	return s.SanitizeEvent(audit.Event{Commands: cmds}).Commands
+}
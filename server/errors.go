@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ajazfarhad/provenance/audit"
+)
+
+// Error codes sent in error responses.
+const (
+	CodeInvalidArgument    = "invalid_argument"    // 400: bad body, parameter or input
+	CodePolicyRejected     = "policy_rejected"     // 403: *audit.PolicyError
+	CodeNotFound           = "not_found"           // 404: unknown trail or route
+	CodeInvalidTransition  = "invalid_transition"  // 409: *audit.TransitionError
+	CodeConflict           = "conflict"            // 409: audit.ErrConflict; retry
+	CodeVerificationFailed = "verification_failed" // 409: *audit.VerifyError
+	CodeInternal           = "internal"            // 500
+	CodeUnimplemented      = "unimplemented"       // 501: e.g. the store keeps no ledger
+)
+
+// Error is the error response body, under "error".
+type Error struct {
+	Status  int               `json:"-"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// ErrorResponse wraps Error on the wire.
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
+
+func invalid(format string, args ...any) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeInvalidArgument, Message: fmt.Sprintf(format, args...)}
+}
+
+// toError maps a Service error to its response.
+func toError(err error) *Error {
+	var (
+		apiErr        *Error
+		verifyErr     *audit.VerifyError
+		transitionErr *audit.TransitionError
+		policyErr     *audit.PolicyError
+	)
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &verifyErr):
+		return &Error{Status: http.StatusConflict, Code: CodeVerificationFailed, Message: err.Error(), Details: map[string]string{
+			"trail_id": verifyErr.TrailID,
+			"event_id": verifyErr.EventID,
+			"index":    fmt.Sprint(verifyErr.Index),
+			"reason":   verifyErr.Reason,
+		}}
+	case errors.As(err, &transitionErr):
+		return &Error{Status: http.StatusConflict, Code: CodeInvalidTransition, Message: err.Error(), Details: map[string]string{
+			"trail_id": transitionErr.TrailID,
+			"from":     string(transitionErr.From),
+			"to":       string(transitionErr.To),
+		}}
+	case errors.As(err, &policyErr):
+		return &Error{Status: http.StatusForbidden, Code: CodePolicyRejected, Message: err.Error(), Details: map[string]string{
+			"trail_id": policyErr.TrailID,
+			"type":     string(policyErr.Type),
+			"actor_id": policyErr.ActorID,
+			"reason":   policyErr.Reason,
+		}}
+	case errors.Is(err, audit.ErrTrailNotFound):
+		return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: err.Error(), Details: map[string]string{"resource": "trail"}}
+	case errors.Is(err, audit.ErrInvalid), errors.Is(err, audit.ErrBadCursor):
+		return invalid("%s", err.Error())
+	case errors.Is(err, audit.ErrNoLedger):
+		return &Error{Status: http.StatusNotImplemented, Code: CodeUnimplemented, Message: err.Error()}
+	case errors.Is(err, audit.ErrConflict):
+		return &Error{Status: http.StatusConflict, Code: CodeConflict, Message: err.Error()}
+	default:
+		return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: "internal error"}
+	}
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	e := toError(err)
+	if e.Status >= 500 && h.log != nil {
+		h.log.ErrorContext(r.Context(), "provenance api", "method", r.Method, "path", r.URL.Path, "err", err)
+	}
+	writeJSON(w, e.Status, ErrorResponse{Error: e})
+}
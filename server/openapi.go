@@ -0,0 +1,190 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPI returns the OpenAPI 3.0 document for the API, built from the same
+// routes and Go types the handler serves.
+func OpenAPI() map[string]any {
+	g := &schemaGen{schemas: make(map[string]any)}
+	paths := make(map[string]any)
+
+	for _, rt := range (&Handler{}).routes() {
+		op := map[string]any{
+			"summary":     rt.summary,
+			"operationId": operationID(rt.method, rt.path),
+		}
+
+		var params []any
+		for _, name := range pathParams(rt.path) {
+			params = append(params, map[string]any{
+				"name": name, "in": "path", "required": true,
+				"schema": map[string]any{"type": "string"},
+			})
+		}
+		for _, p := range rt.params {
+			params = append(params, map[string]any{
+				"name": p.Name, "in": "query", "description": p.Description,
+				"schema": paramSchema(p.Type),
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if rt.req != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": g.schema(rt.req)}},
+			}
+		}
+
+		ok := map[string]any{"description": http.StatusText(rt.status)}
+		if rt.resp != nil {
+			ok["content"] = map[string]any{"application/json": map[string]any{"schema": g.schema(rt.resp)}}
+		}
+		op["responses"] = map[string]any{
+			strconv.Itoa(rt.status): ok,
+			"default": map[string]any{
+				"description": "Error",
+				"content": map[string]any{"application/json": map[string]any{
+					"schema": g.schema(reflect.TypeFor[ErrorResponse]()),
+				}},
+			},
+		}
+
+		item, _ := paths[rt.path].(map[string]any)
+		if item == nil {
+			item = make(map[string]any)
+			paths[rt.path] = item
+		}
+		item[strings.ToLower(rt.method)] = op
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Provenance",
+			"version": "1",
+		},
+		"paths":      paths,
+		"components": map[string]any{"schemas": g.schemas},
+	}
+}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+func pathParams(path string) []string {
+	var out []string
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		out = append(out, m[1])
+	}
+	return out
+}
+
+// operationID turns "POST /v1/trails/{id}/approve" into "postTrailsIdApprove".
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.Split(path, "/")[2:] {
+		part = strings.Trim(part, "{}")
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+func paramSchema(typ string) map[string]any {
+	if typ == "date-time" {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	return map[string]any{"type": typ}
+}
+
+// schemaGen converts Go types to JSON Schema, following encoding/json.
+// Named structs become components referenced by name.
+type schemaGen struct {
+	schemas map[string]any
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+func (g *schemaGen) schema(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]any{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			g.schemas[t.Name()] = nil // placeholder for recursive types
+			g.schemas[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+func (g *schemaGen) object(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	g.fields(t, props, &required)
+
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func (g *schemaGen) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, props, required)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+// Package server exposes an audit.Service as a versioned JSON API, so
+// services not written in Go can record and inspect changes:
+//
+//	http.Handle("/", server.New(svc))
+//
+// Request and response bodies use the JSON form of the audit types. Errors
+// are {"error": {"code", "message", "details"}} with a matching status; see
+// Error. The OpenAPI document is served at /v1/openapi.json. The handler
+// does no authentication; wrap it in your own middleware.
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+)
+
+// DefaultMaxBodyBytes caps request bodies unless WithMaxBodyBytes is used.
+const DefaultMaxBodyBytes = 1 << 20
+
+// Handler serves the API for one Service.
+type Handler struct {
+	svc     *audit.Service
+	store   audit.Store // see WithStore
+	mux     *http.ServeMux
+	maxBody int64
+	log     *slog.Logger
+}
+
+type Option func(*Handler)
+
+// WithMaxBodyBytes caps the size of request bodies.
+func WithMaxBodyBytes(n int64) Option {
+	return func(h *Handler) { h.maxBody = n }
+}
+
+// WithLogger logs internal errors, whose details are not sent to clients.
+func WithLogger(l *slog.Logger) Option {
+	return func(h *Handler) { h.log = l }
+}
+
+// WithStore also serves st under /v1/store, the raw Store API used by
+// store/remote clients. Reads come from st; writes go through svc's
+// AcceptTrail and AcceptEvent, so clients cannot bypass the server's
+// sanitizer, lifecycle, policies or key resolver, fork a trail, or leave
+// one without its REQUESTED event. st must
+// be the store svc was created with.
+func WithStore(st audit.Store) Option {
+	return func(h *Handler) { h.store = st }
+}
+
+func New(svc *audit.Service, opts ...Option) *Handler {
+	h := &Handler{svc: svc, mux: http.NewServeMux(), maxBody: DefaultMaxBodyBytes}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	for _, rt := range h.routes() {
+		if rt.store && h.store == nil {
+			continue
+		}
+		h.mux.HandleFunc(rt.method+" "+rt.path, rt.handler)
+	}
+	doc, err := json.Marshal(OpenAPI())
+	if err != nil {
+		panic(err) // the document is built from static Go types
+	}
+	h.mux.HandleFunc("GET /v1/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	})
+	h.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		h.writeError(w, r, &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path)})
+	})
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// RequestBody is the body of POST /v1/trails.
+type RequestBody struct {
+	Title         string         `json:"title"`
+	Description   string         `json:"description,omitempty"`
+	CorrelationID string         `json:"correlation_id,omitempty"`
+	Requester     audit.Actor    `json:"requester"`
+	Targets       []audit.Target `json:"targets,omitempty"`
+}
+
+// Created is the response to POST /v1/trails.
+type Created struct {
+	TrailID string `json:"trail_id"`
+}
+
+// ApproveBody is the body of POST /v1/trails/{id}/approve.
+type ApproveBody struct {
+	Approver      audit.Actor `json:"approver"`
+	CorrelationID string      `json:"correlation_id,omitempty"`
+	Note          string      `json:"note,omitempty"`
+}
+
+// ExecuteBody is the body of POST /v1/trails/{id}/execute.
+type ExecuteBody struct {
+	Executor      audit.Actor     `json:"executor"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Commands      []audit.Command `json:"commands"`
+	Result        audit.Result    `json:"result"`
+}
+
+// VerifyBody is the body of POST /v1/trails/{id}/verify.
+type VerifyBody struct {
+	Verifier      audit.Actor      `json:"verifier"`
+	CorrelationID string           `json:"correlation_id,omitempty"`
+	Evidence      []audit.Evidence `json:"evidence,omitempty"`
+}
+
+// TrailResponse is a trail and its events in chain order.
+type TrailResponse struct {
+	Trail  audit.Trail   `json:"trail"`
+	Events []audit.Event `json:"events"`
+}
+
+// Verification is the response to GET /v1/trails/{id}/verification when
+// the trail verifies; otherwise the error is CodeVerificationFailed.
+type Verification struct {
+	TrailID string `json:"trail_id"`
+	OK      bool   `json:"ok"`
+}
+
+// EventsResponse is a list of events, newest first.
+type EventsResponse struct {
+	Events []audit.Event `json:"events"`
+}
+
+// StartBody is the body of POST /v1/store/trails: a trail and its REQUESTED
+// event, which the server stores together or not at all.
+type StartBody struct {
+	Trail audit.Trail `json:"trail"`
+	Event audit.Event `json:"event"`
+}
+
+// AppendBody is the body of POST /v1/store/events. The append is always
+// conditional, as Store.CompareAndAppend: it fails with CodeConflict if the
+// trail head is not Event.PrevHash.
+type AppendBody struct {
+	Event audit.Event `json:"event"`
+}
+
+// LedgerResponse is a range of the global ledger.
+type LedgerResponse struct {
+	Entries []audit.LedgerEntry `json:"entries"`
+}
+
+// none marks a route without a request or response body.
+type none struct{}
+
+func (h *Handler) routes() []route {
+	return []route{
+		newRoute(h, http.MethodPost, "/v1/trails", http.StatusCreated,
+			"Request a change, opening a new trail", nil,
+			func(r *http.Request, in RequestBody) (Created, error) {
+				id, err := h.svc.Request(r.Context(), audit.RequestInput{
+					Title:         in.Title,
+					Description:   in.Description,
+					CorrelationID: in.CorrelationID,
+					Requester:     in.Requester,
+					Targets:       in.Targets,
+				})
+				return Created{TrailID: id}, err
+			}),
+		newRoute(h, http.MethodGet, "/v1/trails/{id}", http.StatusOK,
+			"Get a trail and its events", nil,
+			func(r *http.Request, _ none) (TrailResponse, error) {
+				t, events, err := h.svc.GetTrail(r.Context(), r.PathValue("id"))
+				if events == nil {
+					events = []audit.Event{}
+				}
+				return TrailResponse{Trail: t, Events: events}, err
+			}),
+		newRoute(h, http.MethodPost, "/v1/trails/{id}/approve", http.StatusNoContent,
+			"Record an approval", nil,
+			func(r *http.Request, in ApproveBody) (none, error) {
+				return none{}, h.svc.Approve(r.Context(), r.PathValue("id"), in.Approver, in.CorrelationID, in.Note)
+			}),
+		newRoute(h, http.MethodPost, "/v1/trails/{id}/execute", http.StatusNoContent,
+			"Record the commands that executed the change", nil,
+			func(r *http.Request, in ExecuteBody) (none, error) {
+				return none{}, h.svc.Execute(r.Context(), r.PathValue("id"), in.Executor, in.CorrelationID, in.Commands, in.Result)
+			}),
+		newRoute(h, http.MethodPost, "/v1/trails/{id}/verify", http.StatusNoContent,
+			"Record evidence that the change took effect", nil,
+			func(r *http.Request, in VerifyBody) (none, error) {
+				return none{}, h.svc.Verify(r.Context(), r.PathValue("id"), in.Verifier, in.CorrelationID, in.Evidence)
+			}),
+		newRoute(h, http.MethodGet, "/v1/trails/{id}/verification", http.StatusOK,
+			"Check the trail's hash chain and signatures", nil,
+			func(r *http.Request, _ none) (Verification, error) {
+				id := r.PathValue("id")
+				if err := h.svc.VerifyTrail(r.Context(), id); err != nil {
+					return Verification{}, err
+				}
+				return Verification{TrailID: id, OK: true}, nil
+			}),
+		newRoute(h, http.MethodGet, "/v1/targets/{type}/{id}/events", http.StatusOK,
+			"List the events that touched a target, newest first",
+			[]param{
+				{Name: "from", Type: "date-time", Description: "events at or after this time"},
+				{Name: "to", Type: "date-time", Description: "events before this time"},
+				{Name: "limit", Type: "integer", Description: "maximum number of events"},
+			},
+			func(r *http.Request, _ none) (EventsResponse, error) {
+				q := r.URL.Query()
+				from, err := timeParam(q.Get("from"), "from")
+				if err != nil {
+					return EventsResponse{}, err
+				}
+				to, err := timeParam(q.Get("to"), "to")
+				if err != nil {
+					return EventsResponse{}, err
+				}
+				limit, err := intParam(q.Get("limit"), "limit")
+				if err != nil {
+					return EventsResponse{}, err
+				}
+				target := audit.Target{Type: r.PathValue("type"), ID: r.PathValue("id")}
+				events, err := h.svc.WhatChanged(r.Context(), target, from, to, limit)
+				if events == nil {
+					events = []audit.Event{}
+				}
+				return EventsResponse{Events: events}, err
+			}),
+
+		storeRoute(newRoute(h, http.MethodPost, "/v1/store/trails", http.StatusNoContent,
+			"Store: create a trail with its REQUESTED event", nil,
+			func(r *http.Request, in StartBody) (none, error) {
+				return none{}, h.svc.AcceptTrail(r.Context(), in.Trail, in.Event)
+			})),
+		storeRoute(newRoute(h, http.MethodGet, "/v1/store/trails/{id}", http.StatusOK,
+			"Store: get a trail and its events", nil,
+			func(r *http.Request, _ none) (TrailResponse, error) {
+				t, events, err := h.store.GetTrail(r.Context(), r.PathValue("id"))
+				if events == nil {
+					events = []audit.Event{}
+				}
+				return TrailResponse{Trail: t, Events: events}, err
+			})),
+		storeRoute(newRoute(h, http.MethodPost, "/v1/store/trails/query", http.StatusOK,
+			"Store: list trails", nil,
+			func(r *http.Request, q audit.TrailQuery) (audit.TrailPage, error) {
+				return h.store.ListTrails(r.Context(), q)
+			})),
+		storeRoute(newRoute(h, http.MethodPost, "/v1/store/events", http.StatusNoContent,
+			"Store: append an event that extends its trail's head", nil,
+			func(r *http.Request, in AppendBody) (none, error) {
+				return none{}, h.svc.AcceptEvent(r.Context(), in.Event)
+			})),
+		storeRoute(newRoute(h, http.MethodPost, "/v1/store/events/query", http.StatusOK,
+			"Store: query events", nil,
+			func(r *http.Request, q audit.Query) (audit.EventPage, error) {
+				page, err := h.store.QueryEvents(r.Context(), q)
+				if page.Events == nil {
+					page.Events = []audit.Event{}
+				}
+				return page, err
+			})),
+		storeRoute(newRoute(h, http.MethodGet, "/v1/store/ledger", http.StatusOK,
+			"Store: read a range of the global ledger",
+			[]param{
+				{Name: "from_seq", Type: "integer", Description: "first seq, inclusive"},
+				{Name: "to_seq", Type: "integer", Description: "last seq, inclusive; 0 for the latest"},
+			},
+			func(r *http.Request, _ none) (LedgerResponse, error) {
+				l, ok := h.store.(audit.Ledger)
+				if !ok {
+					return LedgerResponse{}, audit.ErrNoLedger
+				}
+				from, err := intParam(r.URL.Query().Get("from_seq"), "from_seq")
+				if err != nil {
+					return LedgerResponse{}, err
+				}
+				to, err := intParam(r.URL.Query().Get("to_seq"), "to_seq")
+				if err != nil {
+					return LedgerResponse{}, err
+				}
+				entries, err := l.LedgerEntries(r.Context(), int64(from), int64(to))
+				if entries == nil {
+					entries = []audit.LedgerEntry{}
+				}
+				return LedgerResponse{Entries: entries}, err
+			})),
+	}
+}
+
+// storeRoute marks rt as served only WithStore.
+func storeRoute(rt route) route {
+	rt.store = true
+	return rt
+}
+
+// route is one endpoint. The same value registers the handler and
+// describes the endpoint in the OpenAPI document.
+type route struct {
+	method  string
+	path    string
+	status  int
+	summary string
+	params  []param // query parameters; path parameters come from path
+	store   bool    // served only WithStore
+	req     reflect.Type
+	resp    reflect.Type
+	handler http.HandlerFunc
+}
+
+type param struct {
+	Name        string
+	Type        string // "string", "integer" or "date-time"
+	Description string
+}
+
+// newRoute binds fn to a route. Req is decoded from the JSON body and Resp
+// encoded as the response; none means no body.
+func newRoute[Req, Resp any](h *Handler, method, path string, status int, summary string, params []param, fn func(*http.Request, Req) (Resp, error)) route {
+	rt := route{method: method, path: path, status: status, summary: summary, params: params}
+	noBody := reflect.TypeFor[none]()
+	if t := reflect.TypeFor[Req](); t != noBody {
+		rt.req = t
+	}
+	if t := reflect.TypeFor[Resp](); t != noBody {
+		rt.resp = t
+	}
+
+	rt.handler = func(w http.ResponseWriter, r *http.Request) {
+		var in Req
+		if rt.req != nil {
+			if err := h.decode(w, r, &in); err != nil {
+				h.writeError(w, r, err)
+				return
+			}
+		}
+		out, err := fn(r, in)
+		if err != nil {
+			h.writeError(w, r, err)
+			return
+		}
+		if rt.resp == nil {
+			w.WriteHeader(status)
+			return
+		}
+		writeJSON(w, status, out)
+	}
+	return rt
+}
+
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.maxBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return &Error{Status: http.StatusRequestEntityTooLarge, Code: CodeInvalidArgument, Message: fmt.Sprintf("body exceeds %d bytes", tooLarge.Limit)}
+		}
+		return invalid("invalid JSON body: %v", err)
+	}
+	if dec.More() {
+		return invalid("invalid JSON body: trailing data")
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func timeParam(s, name string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, invalid("%s must be an RFC 3339 time", name)
+	}
+	return t.UTC(), nil
+}
+
+func intParam(s, name string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, invalid("%s must be a non-negative integer", name)
+	}
+	return n, nil
+}
@@ -0,0 +1,245 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/server"
+	"github.com/ajazfarhad/provenance/store/memory"
+)
+
+func newServer(t *testing.T, st audit.Store, opts ...audit.Option) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(server.New(audit.NewService(st, audit.NoopSanitizer{}, opts...)))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path, body string, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+	if out != nil && len(b) > 0 {
+		if err := json.Unmarshal(b, out); err != nil {
+			t.Fatalf("%s %s: response is not JSON: %v\n%s", method, path, err, b)
+		}
+	}
+	return resp.StatusCode
+}
+
+func request(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	var created server.Created
+	status := do(t, srv, "POST", "/v1/trails", `{
+		"title": "Update NTP",
+		"requester": {"id": "u-1"},
+		"targets": [{"type": "network_device", "id": "sw-12", "labels": {"site": "dc1"}}]
+	}`, &created)
+	if status != http.StatusCreated || created.TrailID == "" {
+		t.Fatalf("POST /v1/trails: status %d, %+v", status, created)
+	}
+	return created.TrailID
+}
+
+func TestLifecycle(t *testing.T) {
+	srv := newServer(t, memory.New())
+	id := request(t, srv)
+
+	for _, step := range []struct{ path, body string }{
+		{"/approve", `{"approver": {"id": "u-2"}, "correlation_id": "chg-1", "note": "ok"}`},
+		{"/execute", `{"executor": {"id": "svc-1"}, "commands": [{"kind": "cli", "raw": "ntp server 10.0.0.1"}], "result": {"status": "SUCCESS"}}`},
+		{"/verify", `{"verifier": {"id": "u-3"}, "evidence": [{"kind": "show_cmd", "ref": "show ntp"}]}`},
+	} {
+		if status := do(t, srv, "POST", "/v1/trails/"+id+step.path, step.body, nil); status != http.StatusNoContent {
+			t.Fatalf("POST %s: status %d", step.path, status)
+		}
+	}
+
+	var trail server.TrailResponse
+	if status := do(t, srv, "GET", "/v1/trails/"+id, "", &trail); status != http.StatusOK {
+		t.Fatalf("GET trail: status %d", status)
+	}
+	if trail.Trail.Title != "Update NTP" || len(trail.Events) != 4 || trail.Events[2].Commands[0].Raw != "ntp server 10.0.0.1" {
+		t.Fatalf("unexpected trail %+v", trail)
+	}
+
+	var v server.Verification
+	if status := do(t, srv, "GET", "/v1/trails/"+id+"/verification", "", &v); status != http.StatusOK || !v.OK {
+		t.Fatalf("GET verification: status %d, %+v", status, v)
+	}
+
+	var events server.EventsResponse
+	status := do(t, srv, "GET", "/v1/targets/network_device/sw-12/events?from=2000-01-01T00:00:00Z&limit=2", "", &events)
+	if status != http.StatusOK || len(events.Events) != 2 || events.Events[0].Type != audit.EventVerified {
+		t.Fatalf("GET target events: status %d, %+v", status, events)
+	}
+}
+
+// tamperingStore rewrites the executed command on read.
+type tamperingStore struct{ audit.Store }
+
+func (s tamperingStore) GetTrail(ctx context.Context, id string) (audit.Trail, []audit.Event, error) {
+	t, events, err := s.Store.GetTrail(ctx, id)
+	for i := range events {
+		if events[i].Type == audit.EventExecuted {
+			events[i].Commands = []audit.Command{{Kind: "cli", Raw: "no ntp"}}
+		}
+	}
+	return t, events, err
+}
+
+// queryRecorder keeps the last query passed to QueryEvents.
+type queryRecorder struct {
+	audit.Store
+	last audit.Query
+}
+
+func (s *queryRecorder) QueryEvents(ctx context.Context, q audit.Query) (audit.EventPage, error) {
+	s.last = q
+	return s.Store.QueryEvents(ctx, q)
+}
+
+func TestTimeParamsAreUTC(t *testing.T) {
+	st := &queryRecorder{Store: memory.New()}
+	srv := newServer(t, st)
+
+	path := "/v1/targets/network_device/sw-12/events?from=" + url.QueryEscape("2026-10-01T13:00:00+02:00") + "&to=" + url.QueryEscape("2026-10-02T13:00:00+02:00")
+	if status := do(t, srv, "GET", path, "", nil); status != http.StatusOK {
+		t.Fatalf("GET target events: status %d", status)
+	}
+	from := time.Date(2026, 10, 1, 11, 0, 0, 0, time.UTC)
+	if q := st.last; q.From != from || q.To != from.Add(24*time.Hour) {
+		t.Fatalf("store queried from %v to %v, want %v to %v", q.From, q.To, from, from.Add(24*time.Hour))
+	}
+}
+
+func TestErrors(t *testing.T) {
+	st := memory.New()
+	srv := newServer(t, st, audit.WithPolicy(audit.ApprovalPolicy{SeparateDuties: true}))
+	id := request(t, srv)
+
+	tampered := newServer(t, tamperingStore{st})
+	approved := request(t, srv)
+	do(t, srv, "POST", "/v1/trails/"+approved+"/approve", `{"approver": {"id": "u-2"}}`, nil)
+	do(t, srv, "POST", "/v1/trails/"+approved+"/execute", `{"executor": {"id": "svc-1"}, "commands": [], "result": {"status": "SUCCESS"}}`, nil)
+
+	cases := []struct {
+		name    string
+		srv     *httptest.Server
+		method  string
+		path    string
+		body    string
+		status  int
+		code    string
+		details map[string]string
+	}{
+		{"missing title", srv, "POST", "/v1/trails", `{"requester": {"id": "u-1"}}`, 400, server.CodeInvalidArgument, nil},
+		{"unknown field", srv, "POST", "/v1/trails", `{"title": "x", "requester": {"id": "u-1"}, "requestor": {}}`, 400, server.CodeInvalidArgument, nil},
+		{"malformed body", srv, "POST", "/v1/trails/" + id + "/approve", `{"approver":`, 400, server.CodeInvalidArgument, nil},
+		{"bad time", srv, "GET", "/v1/targets/network_device/sw-12/events?from=yesterday", "", 400, server.CodeInvalidArgument, nil},
+		{"unknown trail", srv, "GET", "/v1/trails/nope", "", 404, server.CodeNotFound, nil},
+		{"unknown trail append", srv, "POST", "/v1/trails/nope/approve", `{"approver": {"id": "u-2"}}`, 404, server.CodeNotFound, nil},
+		{"unknown route", srv, "GET", "/v2/trails", "", 404, server.CodeNotFound, nil},
+		{"store API not enabled", srv, "POST", "/v1/store/events/query", `{}`, 404, server.CodeNotFound, nil},
+		{"out of order", srv, "POST", "/v1/trails/" + id + "/execute", `{"executor": {"id": "svc-1"}, "commands": [], "result": {"status": "SUCCESS"}}`,
+			409, server.CodeInvalidTransition, map[string]string{"from": "REQUESTED", "to": "EXECUTED"}},
+		{"policy", srv, "POST", "/v1/trails/" + id + "/approve", `{"approver": {"id": "u-1"}}`,
+			403, server.CodePolicyRejected, map[string]string{"actor_id": "u-1", "type": "APPROVED"}},
+		{"tampered", tampered, "GET", "/v1/trails/" + approved + "/verification", "",
+			409, server.CodeVerificationFailed, map[string]string{"trail_id": approved, "index": "2"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp server.ErrorResponse
+			status := do(t, tc.srv, tc.method, tc.path, tc.body, &resp)
+			if status != tc.status || resp.Error == nil || resp.Error.Code != tc.code || resp.Error.Message == "" {
+				t.Fatalf("expected %d %s, got %d %+v", tc.status, tc.code, status, resp.Error)
+			}
+			for k, v := range tc.details {
+				if resp.Error.Details[k] != v {
+					t.Fatalf("details[%s] = %q, want %q (%+v)", k, resp.Error.Details[k], v, resp.Error.Details)
+				}
+			}
+		})
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	srv := httptest.NewServer(server.New(audit.NewService(memory.New(), nil), server.WithMaxBodyBytes(64)))
+	defer srv.Close()
+
+	body := `{"title": "` + strings.Repeat("x", 100) + `", "requester": {"id": "u-1"}}`
+	resp, err := http.Post(srv.URL+"/v1/trails", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", resp.StatusCode)
+	}
+}
+
+func TestOpenAPIDescribesEveryRoute(t *testing.T) {
+	srv := newServer(t, memory.New())
+
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if status := do(t, srv, "GET", "/v1/openapi.json", "", &doc); status != http.StatusOK {
+		t.Fatalf("GET openapi.json: status %d", status)
+	}
+
+	for path, methods := range map[string][]string{
+		"/v1/trails":                     {"post"},
+		"/v1/trails/{id}":                {"get"},
+		"/v1/trails/{id}/approve":        {"post"},
+		"/v1/trails/{id}/execute":        {"post"},
+		"/v1/trails/{id}/verify":         {"post"},
+		"/v1/trails/{id}/verification":   {"get"},
+		"/v1/targets/{type}/{id}/events": {"get"},
+	} {
+		for _, m := range methods {
+			if doc.Paths[path][m] == nil {
+				t.Errorf("missing %s %s", m, path)
+			}
+		}
+	}
+
+	// Every $ref points at a defined schema.
+	b, _ := json.Marshal(doc)
+	for _, m := range regexp.MustCompile(`"#/components/schemas/(\w+)"`).FindAllStringSubmatch(string(b), -1) {
+		if doc.Components.Schemas[m[1]] == nil {
+			t.Errorf("dangling $ref to %s", m[1])
+		}
+	}
+
+	event, _ := json.Marshal(doc.Components.Schemas["Event"])
+	for _, field := range []string{`"trail_id"`, `"prev_hash"`, `"date-time"`} {
+		if !strings.Contains(string(event), field) {
+			t.Errorf("Event schema lacks %s: %s", field, event)
+		}
+	}
+}
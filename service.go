@@ -13,12 +13,17 @@ type Option func(*config)
 type config struct {
 	now       func() time.Time
 	sanitizer Sanitizer
+	auditOpts []audit.Option
 }
 
 func WithClock(now func() time.Time) Option {
 	return func(c *config) { c.now = now }
 }
 
+// WithSanitizer applies s to every trail and event before it is hashed and
+// stored. The Actor.Meta keys named by ActorMetaPolicy policies, such as an
+// ApprovalPolicy's GroupKey when it has Groups, are exempt and stored as
+// given.
 func WithSanitizer(s Sanitizer) Option {
 	return func(c *config) {
 		if s != nil {
@@ -27,9 +32,40 @@ func WithSanitizer(s Sanitizer) Option {
 	}
 }
 
+// WithTransitions replaces the trail lifecycle enforced by the client.
+// Passing nil disables lifecycle enforcement.
+func WithTransitions(t Transitions) Option {
+	return func(c *config) { c.auditOpts = append(c.auditOpts, audit.WithTransitions(t)) }
+}
+
+// WithPolicy adds a policy that every appended event must satisfy.
+func WithPolicy(p Policy) Option {
+	return func(c *config) { c.auditOpts = append(c.auditOpts, audit.WithPolicy(p)) }
+}
+
+// WithSigner signs every appended event.
+func WithSigner(signer Signer) Option {
+	return func(c *config) { c.auditOpts = append(c.auditOpts, audit.WithSigner(signer)) }
+}
+
+// WithKeyResolver makes VerifyTrail require a valid signature on every event.
+func WithKeyResolver(keys KeyResolver) Option {
+	return func(c *config) { c.auditOpts = append(c.auditOpts, audit.WithKeyResolver(keys)) }
+}
+
+// WithHashVersion selects the hash scheme for new events.
+func WithHashVersion(version int) Option {
+	return func(c *config) { c.auditOpts = append(c.auditOpts, audit.WithHashVersion(version)) }
+}
+
+// WithPollInterval sets how often Subscribe polls stores that cannot notify.
+func WithPollInterval(d time.Duration) Option {
+	return func(c *config) { c.auditOpts = append(c.auditOpts, audit.WithPollInterval(d)) }
+}
+
 func New(store Store, opts ...Option) *Client {
 	cfg := config{
-		now:       time.Now().UTC,
+		now:       func() time.Time { return time.Now().UTC() },
 		sanitizer: NoopSanitizer{},
 	}
 	for _, opt := range opts {
@@ -40,6 +76,7 @@ func New(store Store, opts ...Option) *Client {
 	if cfg.now != nil {
 		auditOpts = append(auditOpts, audit.WithClock(cfg.now))
 	}
+	auditOpts = append(auditOpts, cfg.auditOpts...)
 
 	return audit.NewService(store, cfg.sanitizer, auditOpts...)
 }
@@ -5,3 +5,13 @@ import "github.com/ajazfarhad/provenance/audit"
 type Store = audit.Store
 type Sanitizer = audit.Sanitizer
 type NoopSanitizer = audit.NoopSanitizer
+type EventSanitizer = audit.EventSanitizer
+type TextFunc = audit.TextFunc
+
+func TextSanitizer(f TextFunc) EventSanitizer {
+	return audit.TextSanitizer(f)
+}
+
+func AdaptSanitizer(s Sanitizer) EventSanitizer {
+	return audit.AdaptSanitizer(s)
+}
@@ -0,0 +1,573 @@
+// Package bolt implements audit.Store on bbolt, an embedded pure-Go
+// key/value engine, for agents that cannot ship a cgo SQLite driver.
+//
+// Key layout, one bucket each (seq is 8 bytes big-endian, so keys sort in
+// ledger order; t is the event time as 8 sortable bytes):
+//
+//	trails        trail ID                  => trail JSON
+//	events        seq                       => event JSON plus ledger link
+//	trail_events  trail ID 0x00 seq         => (empty)
+//	time_index    t seq                     => (empty)
+//	target_index  type 0x00 id 0x00 seq     => t
+//	heads         trail ID                  => hash of the latest event
+//	meta          "ledger_head"             => hash of the latest ledger entry
+//
+// QueryEvents by target, trail or time range reads the matching index and
+// loads only the events it names; other queries scan events in seq order.
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+	bbolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketTrails      = []byte("trails")
+	bucketEvents      = []byte("events")
+	bucketTrailEvents = []byte("trail_events")
+	bucketTimeIndex   = []byte("time_index")
+	bucketTargetIndex = []byte("target_index")
+	bucketHeads       = []byte("heads")
+	bucketMeta        = []byte("meta")
+
+	keyLedgerHead = []byte("ledger_head")
+)
+
+// record is the value stored under an event's seq.
+type record struct {
+	Event  audit.Event `json:"event"`
+	Ledger link        `json:"ledger"`
+}
+
+// link is an event's entry in the global ledger; see audit.LedgerEntry.
+type link struct {
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash"`
+}
+
+type Store struct {
+	db *bbolt.DB
+}
+
+// Open opens or creates the database file at path.
+func Open(path string) (*Store, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{bucketTrails, bucketEvents, bucketTrailEvents, bucketTimeIndex, bucketTargetIndex, bucketHeads, bucketMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func seqKey(seq int64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(seq))
+	return b[:]
+}
+
+// timeKey encodes t so that byte order is time order, including before 1970.
+func timeKey(t time.Time) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(t.UnixNano())^(1<<63))
+	return b[:]
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, []byte{0})
+}
+
+func trailPrefix(trailID string) []byte {
+	return join([]byte(trailID), nil)
+}
+
+func targetPrefix(typ, id string) []byte {
+	return join([]byte(typ), []byte(id), nil)
+}
+
+// seqSuffix returns the seq at the end of an index key.
+func seqSuffix(k []byte) int64 {
+	return int64(binary.BigEndian.Uint64(k[len(k)-8:]))
+}
+
+func (s *Store) CreateTrail(ctx context.Context, t audit.Trail) error {
+	if t.ID == "" {
+		return errors.New("trail id is required")
+	}
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		trails := tx.Bucket(bucketTrails)
+		if trails.Get([]byte(t.ID)) != nil {
+			return errors.New("trail already exists")
+		}
+		return trails.Put([]byte(t.ID), b)
+	})
+}
+
+func (s *Store) AppendEvent(ctx context.Context, e audit.Event) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return appendEvent(tx, e, false)
+	})
+}
+
+func (s *Store) CompareAndAppend(ctx context.Context, e audit.Event) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return appendEvent(tx, e, true)
+	})
+}
+
+// appendEvent stores e, its index keys and its ledger link in tx. bbolt has
+// a single writer, which also serializes the ledger.
+func appendEvent(tx *bbolt.Tx, e audit.Event, checkHead bool) error {
+	if e.ID == "" {
+		return errors.New("event id is required")
+	}
+	if tx.Bucket(bucketTrails).Get([]byte(e.TrailID)) == nil {
+		return audit.ErrTrailNotFound
+	}
+
+	heads := tx.Bucket(bucketHeads)
+	if checkHead && string(heads.Get([]byte(e.TrailID))) != e.PrevHash {
+		return audit.ErrConflict
+	}
+
+	meta := tx.Bucket(bucketMeta)
+	entry := audit.LedgerEntry{
+		TrailID:   e.TrailID,
+		EventID:   e.ID,
+		EventHash: e.Hash,
+		PrevHash:  string(meta.Get(keyLedgerHead)),
+	}
+	h, err := audit.ComputeLedgerHash(entry)
+	if err != nil {
+		return err
+	}
+
+	events := tx.Bucket(bucketEvents)
+	seq, err := events.NextSequence()
+	if err != nil {
+		return err
+	}
+	e.Seq = int64(seq)
+
+	b, err := json.Marshal(record{Event: e, Ledger: link{PrevHash: entry.PrevHash, Hash: h}})
+	if err != nil {
+		return err
+	}
+
+	sk, tk := seqKey(e.Seq), timeKey(e.At)
+	if err := events.Put(sk, b); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketTrailEvents).Put(append(trailPrefix(e.TrailID), sk...), nil); err != nil {
+		return err
+	}
+	if err := tx.Bucket(bucketTimeIndex).Put(append(tk, sk...), nil); err != nil {
+		return err
+	}
+	targets := tx.Bucket(bucketTargetIndex)
+	for _, t := range e.Targets {
+		if err := targets.Put(append(targetPrefix(t.Type, t.ID), sk...), tk); err != nil {
+			return err
+		}
+	}
+	if err := heads.Put([]byte(e.TrailID), []byte(e.Hash)); err != nil {
+		return err
+	}
+	return meta.Put(keyLedgerHead, []byte(h))
+}
+
+func getEvent(tx *bbolt.Tx, seq int64) (record, error) {
+	b := tx.Bucket(bucketEvents).Get(seqKey(seq))
+	if b == nil {
+		return record{}, fmt.Errorf("bolt store: no event at seq %d", seq)
+	}
+	var rec record
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return record{}, err
+	}
+	return rec, nil
+}
+
+func getTrail(tx *bbolt.Tx, trailID string) (audit.Trail, error) {
+	b := tx.Bucket(bucketTrails).Get([]byte(trailID))
+	if b == nil {
+		return audit.Trail{}, audit.ErrTrailNotFound
+	}
+	var t audit.Trail
+	if err := json.Unmarshal(b, &t); err != nil {
+		return audit.Trail{}, err
+	}
+	return t, nil
+}
+
+// trailSeqs returns the seqs of a trail's events in append order.
+func trailSeqs(tx *bbolt.Tx, trailID string) []int64 {
+	var seqs []int64
+	prefix := trailPrefix(trailID)
+	c := tx.Bucket(bucketTrailEvents).Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		seqs = append(seqs, seqSuffix(k))
+	}
+	return seqs
+}
+
+func (s *Store) GetTrail(ctx context.Context, trailID string) (audit.Trail, []audit.Event, error) {
+	var t audit.Trail
+	var events []audit.Event
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		if t, err = getTrail(tx, trailID); err != nil {
+			return err
+		}
+		for _, seq := range trailSeqs(tx, trailID) {
+			rec, err := getEvent(tx, seq)
+			if err != nil {
+				return err
+			}
+			events = append(events, rec.Event)
+		}
+		return nil
+	})
+	if err != nil {
+		return audit.Trail{}, nil, err
+	}
+	return t, events, nil
+}
+
+func (s *Store) LatestEvent(ctx context.Context, trailID string) (*audit.Event, error) {
+	var out *audit.Event
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		if _, err := getTrail(tx, trailID); err != nil {
+			return err
+		}
+		seqs := trailSeqs(tx, trailID)
+		if len(seqs) == 0 {
+			return nil
+		}
+		rec, err := getEvent(tx, seqs[len(seqs)-1])
+		if err != nil {
+			return err
+		}
+		out = &rec.Event
+		return nil
+	})
+	return out, err
+}
+
+func (s *Store) ListTrails(ctx context.Context, q audit.TrailQuery) (audit.TrailPage, error) {
+	var trails []audit.TrailSummary
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketTrails).ForEach(func(k, v []byte) error {
+			var sum audit.TrailSummary
+			if err := json.Unmarshal(v, &sum.Trail); err != nil {
+				return err
+			}
+			if seqs := trailSeqs(tx, sum.ID); len(seqs) > 0 {
+				first, err := getEvent(tx, seqs[0])
+				if err != nil {
+					return err
+				}
+				last, err := getEvent(tx, seqs[len(seqs)-1])
+				if err != nil {
+					return err
+				}
+				sum.Requester = first.Event.Actor
+				sum.Status = last.Event.Type
+				sum.UpdatedAt = last.Event.At
+			}
+			trails = append(trails, sum)
+			return nil
+		})
+	})
+	if err != nil {
+		return audit.TrailPage{}, err
+	}
+	return audit.PageTrails(q, trails)
+}
+
+func (s *Store) LedgerEntries(ctx context.Context, fromSeq, toSeq int64) ([]audit.LedgerEntry, error) {
+	var out []audit.LedgerEntry
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(bucketEvents).Cursor()
+		for k, v := c.Seek(seqKey(fromSeq)); k != nil; k, v = c.Next() {
+			var rec record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			e := rec.Event
+			if toSeq > 0 && e.Seq > toSeq {
+				break
+			}
+			out = append(out, audit.LedgerEntry{
+				Seq:       e.Seq,
+				TrailID:   e.TrailID,
+				EventID:   e.ID,
+				EventHash: e.Hash,
+				PrevHash:  rec.Ledger.PrevHash,
+				Hash:      rec.Ledger.Hash,
+			})
+		}
+		return nil
+	})
+	return out, err
+}
+
+func (s *Store) QueryEvents(ctx context.Context, q audit.Query) (audit.EventPage, error) {
+	limit := q.Limit
+	q.Limit = 0
+
+	it, err := s.IterateEvents(ctx, q)
+	if err != nil {
+		return audit.EventPage{}, err
+	}
+	defer it.Close()
+
+	var page audit.EventPage
+	for it.Next() {
+		if limit > 0 && len(page.Events) == limit {
+			page.NextCursor = audit.EncodeEventCursor(audit.EventCursor{Seq: page.Events[limit-1].Seq})
+			break
+		}
+		page.Events = append(page.Events, it.Event())
+	}
+	if err := it.Err(); err != nil {
+		return audit.EventPage{}, err
+	}
+	return page, nil
+}
+
+// IterateEvents picks the most selective index for q: targets, then trail
+// IDs, then the time range. Without one it scans events in seq order.
+// Events are loaded in short read transactions, a batch at a time, so a slow
+// consumer does not hold up writers.
+func (s *Store) IterateEvents(ctx context.Context, q audit.Query) (audit.EventIterator, error) {
+	var after int64
+	if q.Cursor != "" {
+		c, err := audit.DecodeEventCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c.Seq
+	}
+
+	it := &eventIterator{ctx: ctx, db: s.db, q: q, asc: q.Order.Ascending(), after: after}
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		seqs, indexed := candidates(tx, q)
+		if !indexed {
+			return nil
+		}
+		it.indexed = true
+		for _, seq := range seqs {
+			if after > 0 && (it.asc && seq <= after || !it.asc && seq >= after) {
+				continue
+			}
+			it.seqs = append(it.seqs, seq)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(it.seqs, func(i, j int) bool {
+		if it.asc {
+			return it.seqs[i] < it.seqs[j]
+		}
+		return it.seqs[i] > it.seqs[j]
+	})
+	return it, nil
+}
+
+// candidates returns the seqs an index says may match q, deduplicated, or
+// false if no index applies.
+func candidates(tx *bbolt.Tx, q audit.Query) ([]int64, bool) {
+	seen := make(map[int64]bool)
+	var seqs []int64
+	add := func(seq int64) {
+		if !seen[seq] {
+			seen[seq] = true
+			seqs = append(seqs, seq)
+		}
+	}
+
+	from, to := timeKey(q.From), timeKey(q.To)
+	inRange := func(tk []byte) bool {
+		if !q.From.IsZero() && bytes.Compare(tk, from) < 0 {
+			return false
+		}
+		return q.To.IsZero() || bytes.Compare(tk, to) < 0
+	}
+
+	switch {
+	case len(q.AnyTargets()) > 0:
+		c := tx.Bucket(bucketTargetIndex).Cursor()
+		for _, t := range q.AnyTargets() {
+			prefix := targetPrefix(t.Type, t.ID)
+			for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+				if inRange(v) {
+					add(seqSuffix(k))
+				}
+			}
+		}
+	case len(q.TrailIDs) > 0:
+		for _, id := range q.TrailIDs {
+			for _, seq := range trailSeqs(tx, id) {
+				add(seq)
+			}
+		}
+	case !q.From.IsZero() || !q.To.IsZero():
+		c := tx.Bucket(bucketTimeIndex).Cursor()
+		k, _ := c.First()
+		if !q.From.IsZero() {
+			k, _ = c.Seek(from)
+		}
+		for ; k != nil && inRange(k[:8]); k, _ = c.Next() {
+			add(seqSuffix(k))
+		}
+	default:
+		return nil, false
+	}
+	return seqs, true
+}
+
+// iteratorBatch is how many events one read transaction loads.
+const iteratorBatch = 256
+
+type eventIterator struct {
+	ctx context.Context
+	db  *bbolt.DB
+	q   audit.Query
+	asc bool
+
+	indexed bool
+	seqs    []int64 // indexed: remaining candidates, in order
+	after   int64   // scan: last seq read
+
+	buf   []audit.Event
+	done  bool
+	count int
+	ev    audit.Event
+	err   error
+}
+
+func (it *eventIterator) Next() bool {
+	for it.err == nil {
+		if it.q.Limit > 0 && it.count == it.q.Limit {
+			return false
+		}
+		if it.err = it.ctx.Err(); it.err != nil {
+			return false
+		}
+		if len(it.buf) == 0 {
+			if it.done {
+				return false
+			}
+			if it.err = it.fill(); it.err != nil {
+				return false
+			}
+			continue
+		}
+
+		e := it.buf[0]
+		it.buf = it.buf[1:]
+		if it.q.Matches(e) {
+			it.ev = e
+			it.count++
+			return true
+		}
+	}
+	return false
+}
+
+// fill loads the next batch of events into buf.
+func (it *eventIterator) fill() error {
+	return it.db.View(func(tx *bbolt.Tx) error {
+		if it.indexed {
+			n := min(iteratorBatch, len(it.seqs))
+			for _, seq := range it.seqs[:n] {
+				rec, err := getEvent(tx, seq)
+				if err != nil {
+					return err
+				}
+				it.buf = append(it.buf, rec.Event)
+			}
+			it.seqs = it.seqs[n:]
+			it.done = len(it.seqs) == 0
+			return nil
+		}
+
+		c := tx.Bucket(bucketEvents).Cursor()
+		var k, v []byte
+		switch {
+		case it.asc && it.after == 0:
+			k, v = c.First()
+		case it.asc:
+			if k, v = c.Seek(seqKey(it.after)); k != nil && seqSuffix(k) == it.after {
+				k, v = c.Next()
+			}
+		case it.after == 0:
+			k, v = c.Last()
+		default:
+			c.Seek(seqKey(it.after))
+			k, v = c.Prev()
+		}
+
+		for ; k != nil && len(it.buf) < iteratorBatch; k, v = step(c, it.asc) {
+			var rec record
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			it.buf = append(it.buf, rec.Event)
+			it.after = rec.Event.Seq
+		}
+		it.done = k == nil
+		return nil
+	})
+}
+
+func step(c *bbolt.Cursor, asc bool) ([]byte, []byte) {
+	if asc {
+		return c.Next()
+	}
+	return c.Prev()
+}
+
+func (it *eventIterator) Event() audit.Event { return it.ev }
+func (it *eventIterator) Err() error         { return it.err }
+
+func (it *eventIterator) Close() error {
+	it.buf, it.seqs, it.done = nil, nil, true
+	return nil
+}
@@ -0,0 +1,190 @@
+package bolt_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/bolt"
+	"github.com/ajazfarhad/provenance/store/storetest"
+)
+
+func open(t *testing.T, path string) *bolt.Store {
+	t.Helper()
+	st, err := bolt.Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	return st
+}
+
+func seed(t *testing.T, svc *audit.Service, target string) string {
+	t.Helper()
+	ctx := context.Background()
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Update NTP",
+		Requester: audit.Actor{ID: "u-1"},
+		Targets:   []audit.Target{{Type: "network_device", ID: target}},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "corr",
+		[]audit.Command{{Kind: "cli", Raw: "ntp server 10.0.0.1"}},
+		audit.Result{Status: "SUCCESS"},
+	); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	return trailID
+}
+
+func TestStoreSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.db")
+
+	st := open(t, path)
+	trailID := seed(t, audit.NewService(st, audit.NoopSanitizer{}), "sw-12")
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	st = open(t, path)
+	defer st.Close()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+	second := seed(t, svc, "sw-12")
+	if err := svc.VerifyLedger(ctx, 0, 0); err != nil {
+		t.Fatalf("VerifyLedger error: %v", err)
+	}
+
+	events, err := svc.WhatChanged(ctx, audit.Target{Type: "network_device", ID: "sw-12"}, time.Time{}, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("WhatChanged error: %v", err)
+	}
+	if len(events) != 6 || events[0].TrailID != second || events[0].Seq != 6 {
+		t.Fatalf("expected 6 events newest first, got %d (first %+v)", len(events), events[0])
+	}
+
+	page, err := svc.ListTrails(ctx, audit.TrailQuery{Status: audit.EventExecuted})
+	if err != nil {
+		t.Fatalf("ListTrails error: %v", err)
+	}
+	if len(page.Trails) != 2 || page.Trails[0].Requester.ID != "u-1" {
+		t.Fatalf("unexpected trails %+v", page.Trails)
+	}
+}
+
+func TestQueryEventsUsesIndexes(t *testing.T) {
+	ctx := context.Background()
+
+	st := open(t, filepath.Join(t.TempDir(), "audit.db"))
+	defer st.Close()
+
+	base := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	now := base
+	svc := audit.NewService(st, audit.NoopSanitizer{}, audit.WithClock(func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}))
+	first := seed(t, svc, "sw-12")
+	seed(t, svc, "sw-13")
+	seed(t, svc, "sw-12")
+
+	cases := []struct {
+		name string
+		q    audit.Query
+		want []int64
+	}{
+		{"target", audit.Query{TargetType: "network_device", TargetID: "sw-13"}, []int64{6, 5, 4}},
+		{"targets in range", audit.Query{
+			Targets: []audit.Target{{Type: "network_device", ID: "sw-12"}, {Type: "network_device", ID: "sw-13"}},
+			From:    base.Add(3 * time.Minute),
+			To:      base.Add(8 * time.Minute),
+			Order:   audit.OrderAsc,
+		}, []int64{3, 4, 5, 6, 7}},
+		{"trail", audit.Query{TrailIDs: []string{first}, EventTypes: []audit.EventType{audit.EventExecuted}}, []int64{3}},
+		{"time", audit.Query{From: base.Add(8 * time.Minute)}, []int64{9, 8}},
+		{"scan", audit.Query{ActorID: "u-2", Order: audit.OrderAsc}, []int64{2, 5, 8}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			page, err := svc.QueryEvents(ctx, tc.q)
+			if err != nil {
+				t.Fatalf("QueryEvents error: %v", err)
+			}
+			var got []int64
+			for _, e := range page.Events {
+				got = append(got, e.Seq)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected seqs %v, got %v", tc.want, got)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("expected seqs %v, got %v", tc.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestQueryEventsPagesBothWays(t *testing.T) {
+	ctx := context.Background()
+
+	st := open(t, filepath.Join(t.TempDir(), "audit.db"))
+	defer st.Close()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+	for i := 0; i < 200; i++ {
+		seed(t, svc, "sw-12")
+	}
+
+	for _, q := range []audit.Query{
+		{Order: audit.OrderAsc, Limit: 70},
+		{Order: audit.OrderDesc, Limit: 70},
+		{Order: audit.OrderAsc, Limit: 70, TargetType: "network_device", TargetID: "sw-12"},
+		{Order: audit.OrderDesc, Limit: 70, TargetType: "network_device", TargetID: "sw-12"},
+	} {
+		var seqs []int64
+		for {
+			page, err := svc.QueryEvents(ctx, q)
+			if err != nil {
+				t.Fatalf("QueryEvents error: %v", err)
+			}
+			for _, e := range page.Events {
+				seqs = append(seqs, e.Seq)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		if len(seqs) != 600 {
+			t.Fatalf("%s: expected 600 events, got %d", q.Order, len(seqs))
+		}
+		for i := 1; i < len(seqs); i++ {
+			if q.Order == audit.OrderAsc && seqs[i] != seqs[i-1]+1 || q.Order == audit.OrderDesc && seqs[i] != seqs[i-1]-1 {
+				t.Fatalf("%s: events out of order at %d: %v", q.Order, i, seqs[i-1:i+1])
+			}
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) audit.Store {
+		st, err := bolt.Open(filepath.Join(t.TempDir(), "audit.db"))
+		if err != nil {
+			t.Fatalf("Open error: %v", err)
+		}
+		t.Cleanup(func() { st.Close() })
+		return st
+	})
+}
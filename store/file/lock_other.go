@@ -0,0 +1,8 @@
+//go:build !unix
+
+package file
+
+import "os"
+
+// lock is a no-op where flock is unavailable; see the package comment.
+func lock(f *os.File) error { return nil }
@@ -0,0 +1,569 @@
+// Package file implements audit.Store on an append-only JSON Lines log, for
+// sites without a database.
+//
+// A store is a directory holding:
+//
+//	log.jsonl   one record per line, fsync'd before an append returns
+//	index.json  byte offsets into the log; rebuilt from the log when missing
+//	            or stale
+//
+// Each line is {"trail": {...}} or {"event": {...}, "ledger": {...}}, so
+// standard tools can read it, e.g. `jq -c 'select(.event) | .event' log.jsonl`.
+// Event hashes verify as in any other store, which also makes a log a
+// portable way to move trails between environments.
+//
+// A directory must only be opened by one Store at a time. On Unix, Open
+// enforces this with an flock on dir/lock and fails with ErrLocked.
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ajazfarhad/provenance/audit"
+)
+
+const (
+	logName   = "log.jsonl"
+	indexName = "index.json"
+	lockName  = "lock"
+)
+
+// ErrLocked is returned by Open when another Store has the directory open.
+var ErrLocked = errors.New("file store: directory is in use by another Store")
+
+// record is one line of the log.
+type record struct {
+	Trail  *audit.Trail `json:"trail,omitempty"`
+	Event  *audit.Event `json:"event,omitempty"`
+	Ledger *link        `json:"ledger,omitempty"`
+}
+
+// link is an event's entry in the global ledger; see audit.LedgerEntry.
+type link struct {
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash"`
+}
+
+// span locates a record in the log.
+type span struct {
+	Off int64 `json:"off"`
+	Len int64 `json:"len"`
+}
+
+// index is everything needed to serve reads without scanning the log.
+// Size is the log length it covers; a mismatch means it is stale.
+type index struct {
+	Size        int64              `json:"size"`
+	Trails      map[string]span    `json:"trails"`
+	TrailEvents map[string][]int64 `json:"trail_events"` // trail ID => seqs, in append order
+	Events      []span             `json:"events"`       // seq-1 => event record
+	Heads       map[string]string  `json:"heads"`        // trail ID => latest event hash
+	LedgerHead  string             `json:"ledger_head"`
+}
+
+func newIndex() index {
+	return index{
+		Trails:      make(map[string]span),
+		TrailEvents: make(map[string][]int64),
+		Heads:       make(map[string]string),
+	}
+}
+
+type Store struct {
+	mu     sync.RWMutex
+	dir    string
+	lock   *os.File // flock'd for the life of the Store
+	log    *os.File
+	idx    index
+	broken error // set when the log may no longer match idx; see appendRecord
+}
+
+// Open opens the store in dir, creating it if needed. A torn last line left
+// by a crash mid-append is truncated; any other unreadable line is an error.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	lf, err := os.OpenFile(filepath.Join(dir, lockName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lock(lf); err != nil {
+		lf.Close()
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, logName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		lf.Close()
+		return nil, err
+	}
+
+	s := &Store{dir: dir, lock: lf, log: f}
+	if err := s.loadIndex(); err != nil {
+		f.Close()
+		lf.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Rebuild regenerates the index of the store in dir from its log.
+func Rebuild(dir string) error {
+	if err := os.Remove(filepath.Join(dir, indexName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s, err := Open(dir)
+	if err != nil {
+		return err
+	}
+	return s.Close()
+}
+
+// Close writes the index, closes the log and releases the directory.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.log == nil {
+		return nil
+	}
+	var err error
+	if s.broken == nil {
+		// A broken index is not worth keeping; the next Open rescans.
+		err = s.writeIndex()
+	}
+	if cerr := s.log.Close(); err == nil {
+		err = cerr
+	}
+	s.lock.Close()
+	s.log = nil
+	return err
+}
+
+func (s *Store) loadIndex() error {
+	fi, err := s.log.Stat()
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(filepath.Join(s.dir, indexName))
+	if err == nil {
+		idx := newIndex()
+		if json.Unmarshal(b, &idx) == nil && idx.Size == fi.Size() {
+			s.idx = idx
+			return nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := s.scan(); err != nil {
+		return err
+	}
+	return s.writeIndex()
+}
+
+// scan rebuilds the index by reading the whole log.
+func (s *Store) scan() error {
+	s.idx = newIndex()
+
+	r := bufio.NewReader(io.NewSectionReader(s.log, 0, 1<<62))
+	var off int64
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(b) > 0 {
+				// The append that wrote this never returned; drop it.
+				if err := s.log.Truncate(off); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec record
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return fmt.Errorf("file store: %s line %d: %w", logName, line, err)
+		}
+		if err := s.idx.add(rec, span{Off: off, Len: int64(len(b))}); err != nil {
+			return fmt.Errorf("file store: %s line %d: %w", logName, line, err)
+		}
+		off += int64(len(b))
+	}
+	s.idx.Size = off
+	return nil
+}
+
+// add records rec, stored at sp, in the index.
+func (idx *index) add(rec record, sp span) error {
+	switch {
+	case rec.Trail != nil:
+		idx.Trails[rec.Trail.ID] = sp
+	case rec.Event != nil && rec.Ledger != nil:
+		e := rec.Event
+		if e.Seq != int64(len(idx.Events))+1 {
+			return fmt.Errorf("event %s has seq %d, expected %d", e.ID, e.Seq, len(idx.Events)+1)
+		}
+		idx.Events = append(idx.Events, sp)
+		idx.TrailEvents[e.TrailID] = append(idx.TrailEvents[e.TrailID], e.Seq)
+		idx.Heads[e.TrailID] = e.Hash
+		idx.LedgerHead = rec.Ledger.Hash
+	default:
+		return errors.New("record is neither a trail nor an event")
+	}
+	idx.Size = sp.Off + sp.Len
+	return nil
+}
+
+func (s *Store) writeIndex() error {
+	b, err := json.Marshal(s.idx)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(s.dir, indexName+".tmp")
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, indexName))
+}
+
+// appendRecord writes rec as one line, fsyncs it and indexes it.
+// s.mu must be held.
+func (s *Store) appendRecord(rec record) error {
+	if s.log == nil {
+		return errors.New("file store is closed")
+	}
+	if s.broken != nil {
+		return s.broken
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	_, err = s.log.Write(b)
+	if err == nil {
+		err = s.log.Sync()
+	}
+	if err != nil {
+		// Neither a partial line nor an unsynced whole one may stay past
+		// idx.Size, or every later record is indexed at the wrong offset.
+		if terr := s.log.Truncate(s.idx.Size); terr != nil {
+			s.broken = fmt.Errorf("file store: log no longer matches its index, reopen the store: %w", terr)
+		}
+		return err
+	}
+	return s.idx.add(rec, span{Off: s.idx.Size, Len: int64(len(b))})
+}
+
+func (s *Store) read(sp span) (record, error) {
+	b := make([]byte, sp.Len)
+	if _, err := s.log.ReadAt(b, sp.Off); err != nil {
+		return record{}, err
+	}
+	var rec record
+	if err := json.Unmarshal(bytes.TrimSuffix(b, []byte("\n")), &rec); err != nil {
+		return record{}, err
+	}
+	return rec, nil
+}
+
+func (s *Store) readEvent(seq int64) (audit.Event, *link, error) {
+	rec, err := s.read(s.idx.Events[seq-1])
+	if err != nil {
+		return audit.Event{}, nil, err
+	}
+	if rec.Event == nil {
+		return audit.Event{}, nil, fmt.Errorf("file store: seq %d is not an event", seq)
+	}
+	return *rec.Event, rec.Ledger, nil
+}
+
+func (s *Store) CreateTrail(ctx context.Context, t audit.Trail) error {
+	if t.ID == "" {
+		return errors.New("trail id is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.idx.Trails[t.ID]; exists {
+		return errors.New("trail already exists")
+	}
+	return s.appendRecord(record{Trail: &t})
+}
+
+func (s *Store) AppendEvent(ctx context.Context, e audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.appendLocked(e)
+}
+
+func (s *Store) CompareAndAppend(ctx context.Context, e audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.idx.Trails[e.TrailID]; !ok {
+		return audit.ErrTrailNotFound
+	}
+	if e.PrevHash != s.idx.Heads[e.TrailID] {
+		return audit.ErrConflict
+	}
+	return s.appendLocked(e)
+}
+
+// appendLocked links e into the ledger and appends it. s.mu must be held.
+func (s *Store) appendLocked(e audit.Event) error {
+	if e.ID == "" {
+		return errors.New("event id is required")
+	}
+	if _, ok := s.idx.Trails[e.TrailID]; !ok {
+		return audit.ErrTrailNotFound
+	}
+
+	entry := audit.LedgerEntry{
+		TrailID:   e.TrailID,
+		EventID:   e.ID,
+		EventHash: e.Hash,
+		PrevHash:  s.idx.LedgerHead,
+	}
+	h, err := audit.ComputeLedgerHash(entry)
+	if err != nil {
+		return err
+	}
+
+	e.Seq = int64(len(s.idx.Events)) + 1
+	return s.appendRecord(record{Event: &e, Ledger: &link{PrevHash: entry.PrevHash, Hash: h}})
+}
+
+func (s *Store) GetTrail(ctx context.Context, trailID string) (audit.Trail, []audit.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sp, ok := s.idx.Trails[trailID]
+	if !ok {
+		return audit.Trail{}, nil, audit.ErrTrailNotFound
+	}
+	rec, err := s.read(sp)
+	if err != nil {
+		return audit.Trail{}, nil, err
+	}
+
+	var events []audit.Event
+	for _, seq := range s.idx.TrailEvents[trailID] {
+		e, _, err := s.readEvent(seq)
+		if err != nil {
+			return audit.Trail{}, nil, err
+		}
+		events = append(events, e)
+	}
+	return *rec.Trail, events, nil
+}
+
+func (s *Store) LatestEvent(ctx context.Context, trailID string) (*audit.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.idx.Trails[trailID]; !ok {
+		return nil, audit.ErrTrailNotFound
+	}
+	seqs := s.idx.TrailEvents[trailID]
+	if len(seqs) == 0 {
+		return nil, nil
+	}
+	e, _, err := s.readEvent(seqs[len(seqs)-1])
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *Store) QueryEvents(ctx context.Context, q audit.Query) (audit.EventPage, error) {
+	limit := q.Limit
+	q.Limit = 0
+
+	it, err := s.IterateEvents(ctx, q)
+	if err != nil {
+		return audit.EventPage{}, err
+	}
+	defer it.Close()
+
+	var page audit.EventPage
+	for it.Next() {
+		if limit > 0 && len(page.Events) == limit {
+			page.NextCursor = audit.EncodeEventCursor(audit.EventCursor{Seq: page.Events[limit-1].Seq})
+			break
+		}
+		page.Events = append(page.Events, it.Event())
+	}
+	if err := it.Err(); err != nil {
+		return audit.EventPage{}, err
+	}
+	return page, nil
+}
+
+// IterateEvents scans the log in seq order, reading one record at a time.
+func (s *Store) IterateEvents(ctx context.Context, q audit.Query) (audit.EventIterator, error) {
+	var after int64
+	if q.Cursor != "" {
+		c, err := audit.DecodeEventCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c.Seq
+	}
+
+	s.mu.RLock()
+	n := int64(len(s.idx.Events))
+	s.mu.RUnlock()
+
+	it := &scanIterator{ctx: ctx, s: s, q: q, step: -1, next: n}
+	if q.Order.Ascending() {
+		it.step, it.next, it.last = 1, 1, n
+		if after > 0 {
+			it.next = after + 1
+		}
+	} else {
+		it.last = 1
+		if after > 0 && after <= n {
+			it.next = after - 1
+		}
+	}
+	return it, nil
+}
+
+// scanIterator walks seqs from next towards last, returning events that
+// match q. Events appended after it was created are not included.
+type scanIterator struct {
+	ctx   context.Context
+	s     *Store
+	q     audit.Query
+	step  int64
+	next  int64
+	last  int64
+	count int
+	ev    audit.Event
+	err   error
+}
+
+func (it *scanIterator) Next() bool {
+	for it.err == nil && it.next >= 1 && (it.step > 0 && it.next <= it.last || it.step < 0 && it.next >= it.last) {
+		if it.q.Limit > 0 && it.count == it.q.Limit {
+			return false
+		}
+		if it.err = it.ctx.Err(); it.err != nil {
+			return false
+		}
+
+		seq := it.next
+		it.next += it.step
+
+		it.s.mu.RLock()
+		e, _, err := it.s.readEvent(seq)
+		it.s.mu.RUnlock()
+		if err != nil {
+			it.err = err
+			return false
+		}
+		if it.q.Matches(e) {
+			it.ev = e
+			it.count++
+			return true
+		}
+	}
+	return false
+}
+
+func (it *scanIterator) Event() audit.Event { return it.ev }
+func (it *scanIterator) Err() error         { return it.err }
+func (it *scanIterator) Close() error       { it.next = 0; return nil }
+
+func (s *Store) ListTrails(ctx context.Context, q audit.TrailQuery) (audit.TrailPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	trails := make([]audit.TrailSummary, 0, len(s.idx.Trails))
+	for id, sp := range s.idx.Trails {
+		rec, err := s.read(sp)
+		if err != nil {
+			return audit.TrailPage{}, err
+		}
+		sum := audit.TrailSummary{Trail: *rec.Trail}
+		if seqs := s.idx.TrailEvents[id]; len(seqs) > 0 {
+			first, _, err := s.readEvent(seqs[0])
+			if err != nil {
+				return audit.TrailPage{}, err
+			}
+			last, _, err := s.readEvent(seqs[len(seqs)-1])
+			if err != nil {
+				return audit.TrailPage{}, err
+			}
+			sum.Requester = first.Actor
+			sum.Status = last.Type
+			sum.UpdatedAt = last.At
+		}
+		trails = append(trails, sum)
+	}
+	return audit.PageTrails(q, trails)
+}
+
+func (s *Store) LedgerEntries(ctx context.Context, fromSeq, toSeq int64) ([]audit.LedgerEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := int64(len(s.idx.Events))
+	if toSeq <= 0 || toSeq > n {
+		toSeq = n
+	}
+	if fromSeq < 1 {
+		fromSeq = 1
+	}
+
+	var out []audit.LedgerEntry
+	for seq := fromSeq; seq <= toSeq; seq++ {
+		e, l, err := s.readEvent(seq)
+		if err != nil {
+			return nil, err
+		}
+		if l == nil {
+			return nil, fmt.Errorf("file store: event %s has no ledger entry", e.ID)
+		}
+		out = append(out, audit.LedgerEntry{
+			Seq:       e.Seq,
+			TrailID:   e.TrailID,
+			EventID:   e.ID,
+			EventHash: e.Hash,
+			PrevHash:  l.PrevHash,
+			Hash:      l.Hash,
+		})
+	}
+	return out, nil
+}
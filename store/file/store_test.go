@@ -0,0 +1,237 @@
+package file_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/file"
+	"github.com/ajazfarhad/provenance/store/storetest"
+)
+
+func open(t *testing.T, dir string) *file.Store {
+	t.Helper()
+	st, err := file.Open(dir)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	return st
+}
+
+func seed(t *testing.T, svc *audit.Service) string {
+	t.Helper()
+	ctx := context.Background()
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Update NTP",
+		Requester: audit.Actor{ID: "u-1"},
+		Targets:   []audit.Target{{Type: "network_device", ID: "sw-12"}},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "corr",
+		[]audit.Command{{Kind: "cli", Raw: "ntp server 10.0.0.1"}},
+		audit.Result{Status: "SUCCESS"},
+	); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	return trailID
+}
+
+func TestStoreSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	st := open(t, dir)
+	trailID := seed(t, audit.NewService(st, audit.NoopSanitizer{}))
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	st = open(t, dir)
+	defer st.Close()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+	if err := svc.VerifyLedger(ctx, 0, 0); err != nil {
+		t.Fatalf("VerifyLedger error: %v", err)
+	}
+
+	// Appends continue the chain and the ledger after reopening.
+	second := seed(t, svc)
+	if err := svc.VerifyLedger(ctx, 0, 0); err != nil {
+		t.Fatalf("VerifyLedger after reopen error: %v", err)
+	}
+
+	events, err := svc.WhatChanged(ctx, audit.Target{Type: "network_device", ID: "sw-12"}, time.Time{}, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("WhatChanged error: %v", err)
+	}
+	if len(events) != 6 || events[0].TrailID != second || events[0].Seq != 6 {
+		t.Fatalf("expected 6 events newest first, got %d (first %+v)", len(events), events[0])
+	}
+
+	page, err := svc.ListTrails(ctx, audit.TrailQuery{Status: audit.EventExecuted})
+	if err != nil {
+		t.Fatalf("ListTrails error: %v", err)
+	}
+	if len(page.Trails) != 2 || page.Trails[0].Requester.ID != "u-1" {
+		t.Fatalf("unexpected trails %+v", page.Trails)
+	}
+}
+
+func TestLogIsPlainJSONLines(t *testing.T) {
+	dir := t.TempDir()
+
+	st := open(t, dir)
+	seed(t, audit.NewService(st, audit.NoopSanitizer{}))
+	st.Close()
+
+	f, err := os.Open(filepath.Join(dir, "log.jsonl"))
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	defer f.Close()
+
+	var lines, events int
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines++
+		var rec struct {
+			Event *audit.Event `json:"event"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("line %d is not JSON: %v", lines, err)
+		}
+		if rec.Event != nil {
+			events++
+			h, err := audit.ComputeEventHash(*rec.Event)
+			if err != nil || h != rec.Event.Hash {
+				t.Fatalf("line %d: event hash does not recompute", lines)
+			}
+		}
+	}
+	if lines != 4 || events != 3 {
+		t.Fatalf("expected 1 trail and 3 event lines, got %d lines, %d events", lines, events)
+	}
+}
+
+func TestOpenRebuildsStaleIndexAndDropsTornLine(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	st := open(t, dir)
+	trailID := seed(t, audit.NewService(st, audit.NoopSanitizer{}))
+	st.Close()
+
+	// Simulate a crash mid-append: a partial line and an index that no
+	// longer matches the log.
+	f, err := os.OpenFile(filepath.Join(dir, "log.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	f.WriteString(`{"event":{"id":"torn`)
+	f.Close()
+
+	st = open(t, dir)
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	if err := svc.Verify(ctx, trailID, audit.Actor{ID: "u-3"}, "corr", nil); err != nil {
+		t.Fatalf("Verify after recovery error: %v", err)
+	}
+	st.Close()
+
+	if err := os.Remove(filepath.Join(dir, "index.json")); err != nil {
+		t.Fatalf("remove index: %v", err)
+	}
+	if err := file.Rebuild(dir); err != nil {
+		t.Fatalf("Rebuild error: %v", err)
+	}
+	st = open(t, dir)
+	defer st.Close()
+	if err := audit.NewService(st, audit.NoopSanitizer{}).VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail after rebuild error: %v", err)
+	}
+}
+
+func TestOpenLocksDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directory locking needs flock")
+	}
+	dir := t.TempDir()
+
+	st := open(t, dir)
+	if _, err := file.Open(dir); !errors.Is(err, file.ErrLocked) {
+		t.Fatalf("expected ErrLocked for a second Open, got %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	open(t, dir).Close()
+}
+
+func TestQueryEventsPagesBothWays(t *testing.T) {
+	ctx := context.Background()
+
+	st := open(t, t.TempDir())
+	defer st.Close()
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+	seed(t, svc)
+	seed(t, svc)
+
+	for _, order := range []audit.Order{audit.OrderAsc, audit.OrderDesc} {
+		var seqs []int64
+		q := audit.Query{Order: order, Limit: 4}
+		for {
+			page, err := svc.QueryEvents(ctx, q)
+			if err != nil {
+				t.Fatalf("QueryEvents error: %v", err)
+			}
+			for _, e := range page.Events {
+				seqs = append(seqs, e.Seq)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		if len(seqs) != 6 {
+			t.Fatalf("%s: expected 6 events, got %v", order, seqs)
+		}
+		for i := 1; i < len(seqs); i++ {
+			if order == audit.OrderAsc && seqs[i] != seqs[i-1]+1 || order == audit.OrderDesc && seqs[i] != seqs[i-1]-1 {
+				t.Fatalf("%s: events out of order: %v", order, seqs)
+			}
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) audit.Store {
+		st, err := file.Open(t.TempDir())
+		if err != nil {
+			t.Fatalf("Open error: %v", err)
+		}
+		t.Cleanup(func() { st.Close() })
+		return st
+	})
+}
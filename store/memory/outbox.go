@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+)
+
+// WithOutbox records a delivery for every appended event and each of sinks
+// (audit.DefaultSink if none are named), like the SQL stores' audit_outbox,
+// so sinks can be tested without a database.
+func WithOutbox(sinks ...string) Option {
+	if len(sinks) == 0 {
+		sinks = []string{audit.DefaultSink}
+	}
+	return func(s *Store) { s.sinks = sinks }
+}
+
+type delivery struct {
+	audit.Delivery
+	lockedUntil time.Time // claimed until then; see ClaimDeliveries
+}
+
+func (s *Store) checkSink(sink string) error {
+	if !slices.Contains(s.sinks, sink) {
+		return fmt.Errorf("%w: %q", audit.ErrUnknownSink, sink)
+	}
+	return nil
+}
+
+func (s *Store) ClaimDeliveries(ctx context.Context, sink string, now time.Time, lease time.Duration, limit int) ([]audit.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkSink(sink); err != nil {
+		return nil, err
+	}
+	var out []audit.Delivery
+	for i := range s.deliveries {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		d := &s.deliveries[i]
+		if d.Sink != sink || d.Dead || d.NextAt.After(now) || d.lockedUntil.After(now) {
+			continue
+		}
+		d.lockedUntil = now.Add(lease)
+		out = append(out, d.Delivery)
+	}
+	return out, nil
+}
+
+func (s *Store) AckDelivery(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(d delivery) bool { return d.ID == id })
+	return nil
+}
+
+func (s *Store) RetryDelivery(ctx context.Context, id int64, next time.Time, lastErr string) error {
+	return s.updateDelivery(id, func(d *delivery) {
+		d.NextAt = next
+		d.LastError = lastErr
+	})
+}
+
+func (s *Store) DeadLetter(ctx context.Context, id int64, lastErr string) error {
+	return s.updateDelivery(id, func(d *delivery) {
+		d.Dead = true
+		d.LastError = lastErr
+	})
+}
+
+// updateDelivery records a failed attempt on delivery id, releases its
+// lease and applies fn.
+func (s *Store) updateDelivery(id int64, fn func(*delivery)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.deliveries {
+		if d := &s.deliveries[i]; d.ID == id {
+			d.Attempts++
+			d.lockedUntil = time.Time{}
+			fn(d)
+			return nil
+		}
+	}
+	return errors.New("delivery not found")
+}
+
+func (s *Store) Deliveries(ctx context.Context, sink string, limit int) ([]audit.Delivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := s.checkSink(sink); err != nil {
+		return nil, err
+	}
+	var out []audit.Delivery
+	for _, d := range s.deliveries {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		if d.Sink == sink {
+			out = append(out, d.Delivery)
+		}
+	}
+	return out, nil
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"sort"
 	"sync"
-	"time"
 
 	"github.com/ajazfarhad/provenance/audit"
 )
@@ -14,13 +13,28 @@ type Store struct {
 	mu     sync.RWMutex
 	trails map[string]audit.Trail
 	events map[string][]audit.Event // trailID => ordered events
+	ledger []audit.LedgerEntry      // all events, in append order
+	checks []audit.Checkpoint       // ordered by tree size
+
+	subs map[chan struct{}]struct{} // Notify channels, signalled on append
+
+	sinks      []string // see WithOutbox
+	deliveries []delivery
+	nextID     int64
 }
 
-func New() *Store {
-	return &Store{
+type Option func(*Store)
+
+func New(opts ...Option) *Store {
+	s := &Store{
 		trails: make(map[string]audit.Trail),
 		events: make(map[string][]audit.Event),
+		subs:   make(map[chan struct{}]struct{}),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Store) CreateTrail(ctx context.Context, t audit.Trail) error {
@@ -40,21 +54,105 @@ func (s *Store) AppendEvent(ctx context.Context, e audit.Event) error {
 	defer s.mu.Unlock()
 
 	if _, ok := s.trails[e.TrailID]; !ok {
-		return errors.New("trail not found")
+		return audit.ErrTrailNotFound
 	}
 
 	// enforce append-only ordering by time + type? We keep it simple:
+	return s.appendLocked(e)
+}
+
+func (s *Store) CompareAndAppend(ctx context.Context, e audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	evs, ok := s.events[e.TrailID]
+	if !ok {
+		return audit.ErrTrailNotFound
+	}
+
+	head := ""
+	if len(evs) > 0 {
+		head = evs[len(evs)-1].Hash
+	}
+	if e.PrevHash != head {
+		return audit.ErrConflict
+	}
+
+	return s.appendLocked(e)
+}
+
+// appendLocked stores e and links it into the ledger. s.mu must be held.
+func (s *Store) appendLocked(e audit.Event) error {
+	entry := audit.LedgerEntry{
+		Seq:       int64(len(s.ledger)) + 1,
+		TrailID:   e.TrailID,
+		EventID:   e.ID,
+		EventHash: e.Hash,
+	}
+	if n := len(s.ledger); n > 0 {
+		entry.PrevHash = s.ledger[n-1].Hash
+	}
+	h, err := audit.ComputeLedgerHash(entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = h
+
+	e.Seq = entry.Seq
+	s.ledger = append(s.ledger, entry)
 	s.events[e.TrailID] = append(s.events[e.TrailID], e)
+	for _, sink := range s.sinks {
+		s.nextID++
+		s.deliveries = append(s.deliveries, delivery{Delivery: audit.Delivery{ID: s.nextID, Sink: sink, Event: e, NextAt: e.At}})
+	}
+
+	for ch := range s.subs {
+		select {
+		case ch <- struct{}{}:
+		default: // a wake-up is already pending
+		}
+	}
 	return nil
 }
 
+// Notify implements audit.Notifier with an in-process broker.
+func (s *Store) Notify(ctx context.Context) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}()
+	return ch
+}
+
+func (s *Store) LedgerEntries(ctx context.Context, fromSeq, toSeq int64) ([]audit.LedgerEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var out []audit.LedgerEntry
+	for _, le := range s.ledger {
+		if le.Seq < fromSeq || (toSeq > 0 && le.Seq > toSeq) {
+			continue
+		}
+		out = append(out, le)
+	}
+	return out, nil
+}
+
 func (s *Store) LatestEvent(ctx context.Context, trailID string) (*audit.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	evs, ok := s.events[trailID]
 	if !ok {
-		return nil, errors.New("trail not found")
+		return nil, audit.ErrTrailNotFound
 	}
 	if len(evs) == 0 {
 		return nil, nil
@@ -63,77 +161,120 @@ func (s *Store) LatestEvent(ctx context.Context, trailID string) (*audit.Event,
 	return &last, nil
 }
 
+func (s *Store) SaveCheckpoint(ctx context.Context, c audit.Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n := len(s.checks); n > 0 && s.checks[n-1].TreeSize >= c.TreeSize {
+		return errors.New("checkpoint tree size must grow")
+	}
+	s.checks = append(s.checks, c)
+	return nil
+}
+
+func (s *Store) LatestCheckpoint(ctx context.Context) (*audit.Checkpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.checks) == 0 {
+		return nil, nil
+	}
+	c := s.checks[len(s.checks)-1]
+	return &c, nil
+}
+
 func (s *Store) GetTrail(ctx context.Context, trailID string) (audit.Trail, []audit.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	t, ok := s.trails[trailID]
 	if !ok {
-		return audit.Trail{}, nil, errors.New("trail not found")
+		return audit.Trail{}, nil, audit.ErrTrailNotFound
 	}
 	evs := append([]audit.Event(nil), s.events[trailID]...)
 	return t, evs, nil
 }
 
-func (s *Store) QueryEvents(ctx context.Context, q audit.Query) ([]audit.Event, error) {
+func (s *Store) QueryEvents(ctx context.Context, q audit.Query) (audit.EventPage, error) {
+	out, err := s.matching(q)
+	if err != nil {
+		return audit.EventPage{}, err
+	}
+
+	var page audit.EventPage
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+		page.NextCursor = audit.EncodeEventCursor(audit.EventCursor{Seq: out[len(out)-1].Seq})
+	}
+	page.Events = out
+	return page, nil
+}
+
+// IterateEvents snapshots the matching events; the memory store holds every
+// event anyway.
+func (s *Store) IterateEvents(ctx context.Context, q audit.Query) (audit.EventIterator, error) {
+	out, err := s.matching(q)
+	if err != nil {
+		return nil, err
+	}
+	if q.Limit > 0 && len(out) > q.Limit {
+		out = out[:q.Limit]
+	}
+	return audit.SliceIterator(ctx, out), nil
+}
+
+// matching returns every event matching q after q.Cursor, in q.Order.
+func (s *Store) matching(q audit.Query) ([]audit.Event, error) {
+	var after int64
+	if q.Cursor != "" {
+		c, err := audit.DecodeEventCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c.Seq
+	}
+	asc := q.Order.Ascending()
+
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var out []audit.Event
 
-	for trailID, evs := range s.events {
-		_ = trailID
+	for _, evs := range s.events {
 		for _, e := range evs {
-			if !inRange(e.At, q.From, q.To) {
+			if after > 0 && (asc && e.Seq <= after || !asc && e.Seq >= after) {
 				continue
 			}
-			if len(q.EventTypes) > 0 && !containsType(q.EventTypes, e.Type) {
+			if !q.Matches(e) {
 				continue
 			}
-			if q.TargetType != "" && q.TargetID != "" {
-				if !eventHasTarget(e, q.TargetType, q.TargetID) {
-					continue
-				}
-			}
 			out = append(out, e)
 		}
 	}
 
-	// stable ordering: newest first
+	// ledger order, like the SQL stores
 	sort.Slice(out, func(i, j int) bool {
-		return out[i].At.After(out[j].At)
+		if asc {
+			return out[i].Seq < out[j].Seq
+		}
+		return out[i].Seq > out[j].Seq
 	})
-
-	if q.Limit > 0 && len(out) > q.Limit {
-		out = out[:q.Limit]
-	}
 	return out, nil
 }
 
-func inRange(t, from, to time.Time) bool {
-	if !from.IsZero() && t.Before(from) {
-		return false
-	}
-	if !to.IsZero() && !t.Before(to) {
-		return false
-	}
-	return true
-}
-
-func containsType(types []audit.EventType, t audit.EventType) bool {
-	for _, x := range types {
-		if x == t {
-			return true
-		}
-	}
-	return false
-}
+func (s *Store) ListTrails(ctx context.Context, q audit.TrailQuery) (audit.TrailPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-func eventHasTarget(e audit.Event, typ, id string) bool {
-	for _, tgt := range e.Targets {
-		if tgt.Type == typ && tgt.ID == id {
-			return true
+	trails := make([]audit.TrailSummary, 0, len(s.trails))
+	for id, t := range s.trails {
+		sum := audit.TrailSummary{Trail: t}
+		if evs := s.events[id]; len(evs) > 0 {
+			sum.Requester = evs[0].Actor
+			sum.Status = evs[len(evs)-1].Type
+			sum.UpdatedAt = evs[len(evs)-1].At
 		}
+		trails = append(trails, sum)
 	}
-	return false
+	return audit.PageTrails(q, trails)
 }
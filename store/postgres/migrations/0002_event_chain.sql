@@ -0,0 +1,9 @@
+ALTER TABLE audit_events
+    ADD COLUMN IF NOT EXISTS trail_hash TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS hash_version INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS key_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS signature TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS ledger_prev_hash TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS ledger_hash TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS audit_events_trail_prev_hash_idx ON audit_events (trail_id, prev_hash);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS audit_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    sink TEXT NOT NULL,
+    event_seq BIGINT NOT NULL REFERENCES audit_events(seq) ON DELETE RESTRICT,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ,
+    dead BOOLEAN NOT NULL DEFAULT false,
+    last_error TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS audit_outbox_sink_next_attempt_idx ON audit_outbox (sink, next_attempt_at);
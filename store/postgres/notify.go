@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// notifyChannel is signalled by every append.
+const notifyChannel = "provenance_audit_events"
+
+// WithNotifications lets Service.Subscribe wake up on LISTEN/NOTIFY instead
+// of polling. LISTEN needs a dedicated connection, so the store opens one to
+// dsn the first time a subscriber asks for notifications.
+func WithNotifications(dsn string) Option {
+	return func(s *Store) { s.dsn = dsn }
+}
+
+// Notify implements audit.Notifier. It returns nil unless the store was
+// created with WithNotifications.
+func (s *Store) Notify(ctx context.Context) <-chan struct{} {
+	if s.dsn == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		l := pq.NewListener(s.dsn, 100*time.Millisecond, time.Minute, nil)
+		if err := l.Listen(notifyChannel); err != nil {
+			l.Close()
+			return nil
+		}
+		s.listener = l
+		go s.fanOut(l)
+	}
+
+	ch := make(chan struct{}, 1)
+	s.subs[ch] = struct{}{}
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}()
+	return ch
+}
+
+// fanOut wakes every subscriber on each notification. pq sends nil after
+// reconnecting, when notifications may have been missed, which also wakes
+// them.
+func (s *Store) fanOut(l *pq.Listener) {
+	for range l.Notify {
+		s.mu.Lock()
+		for ch := range s.subs {
+			select {
+			case ch <- struct{}{}:
+			default: // a wake-up is already pending
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Close stops the LISTEN connection, if one was opened. It does not close
+// the *sql.DB passed to New.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	s.listener = nil
+	return err
+}
@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+)
+
+// WithOutbox records a delivery in audit_outbox for every appended event and
+// each of sinks (audit.DefaultSink if none are named), in the event's
+// transaction. Something must drain each sink, e.g. a webhook.Sink of the
+// same name.
+func WithOutbox(sinks ...string) Option {
+	if len(sinks) == 0 {
+		sinks = []string{audit.DefaultSink}
+	}
+	return func(s *Store) { s.sinks = sinks }
+}
+
+func insertDeliveries(ctx context.Context, tx *sql.Tx, e audit.Event, sinks []string) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	for _, sink := range sinks {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO audit_outbox (sink, event_seq, payload, next_attempt_at)
+			VALUES ($1, $2, $3, $4)
+		`, sink, e.Seq, payload, e.At.UTC())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) checkSink(sink string) error {
+	if !slices.Contains(s.sinks, sink) {
+		return fmt.Errorf("%w: %q", audit.ErrUnknownSink, sink)
+	}
+	return nil
+}
+
+// ClaimDeliveries sets locked_until on the rows it returns. SKIP LOCKED lets
+// replicas claiming at the same moment pass over each other's rows instead
+// of waiting for them and then claiming them again.
+func (s *Store) ClaimDeliveries(ctx context.Context, sink string, now time.Time, lease time.Duration, limit int) ([]audit.Delivery, error) {
+	if err := s.checkSink(sink); err != nil {
+		return nil, err
+	}
+	var max sql.NullInt64 // LIMIT NULL: no limit
+	if limit > 0 {
+		max = sql.NullInt64{Int64: int64(limit), Valid: true}
+	}
+	now = now.UTC()
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE audit_outbox SET locked_until = $1
+		WHERE id IN (
+			SELECT id FROM audit_outbox
+			WHERE sink = $2 AND NOT dead AND next_attempt_at <= $3
+				AND (locked_until IS NULL OR locked_until <= $3)
+			ORDER BY id ASC
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, sink, payload, attempts, next_attempt_at, dead, last_error
+	`, now.Add(lease), sink, now, max)
+	if err != nil {
+		return nil, err
+	}
+	out, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING does not follow the subquery's order.
+	slices.SortFunc(out, func(a, b audit.Delivery) int { return cmp.Compare(a.ID, b.ID) })
+	return out, nil
+}
+
+func (s *Store) AckDelivery(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM audit_outbox WHERE id = $1`, id)
+	return err
+}
+
+func (s *Store) RetryDelivery(ctx context.Context, id int64, next time.Time, lastErr string) error {
+	return s.updateDelivery(ctx, `
+		UPDATE audit_outbox
+		SET attempts = attempts + 1, next_attempt_at = $1, last_error = $2, locked_until = NULL
+		WHERE id = $3
+	`, next.UTC(), lastErr, id)
+}
+
+func (s *Store) DeadLetter(ctx context.Context, id int64, lastErr string) error {
+	return s.updateDelivery(ctx, `
+		UPDATE audit_outbox
+		SET attempts = attempts + 1, dead = true, last_error = $1, locked_until = NULL
+		WHERE id = $2
+	`, lastErr, id)
+}
+
+func (s *Store) updateDelivery(ctx context.Context, query string, args ...any) error {
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errors.New("delivery not found")
+	}
+	return nil
+}
+
+func (s *Store) Deliveries(ctx context.Context, sink string, limit int) ([]audit.Delivery, error) {
+	if err := s.checkSink(sink); err != nil {
+		return nil, err
+	}
+	var max sql.NullInt64 // LIMIT NULL: no limit
+	if limit > 0 {
+		max = sql.NullInt64{Int64: int64(limit), Valid: true}
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, sink, payload, attempts, next_attempt_at, dead, last_error
+		FROM audit_outbox
+		WHERE sink = $1
+		ORDER BY id ASC
+		LIMIT $2
+	`, sink, max)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+func scanDeliveries(rows *sql.Rows) ([]audit.Delivery, error) {
+	defer rows.Close()
+
+	var out []audit.Delivery
+	for rows.Next() {
+		var d audit.Delivery
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.Sink, &payload, &d.Attempts, &d.NextAt, &d.Dead, &d.LastError); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &d.Event); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
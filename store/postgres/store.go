@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/ajazfarhad/provenance/audit"
 	"github.com/lib/pq"
@@ -14,10 +15,22 @@ import (
 
 type Store struct {
 	db *sql.DB
+
+	sinks    []string // see WithOutbox
+	dsn      string   // for the LISTEN connection; see WithNotifications
+	mu       sync.Mutex
+	listener *pq.Listener
+	subs     map[chan struct{}]struct{}
 }
 
-func New(db *sql.DB) *Store {
-	return &Store{db: db}
+type Option func(*Store)
+
+func New(db *sql.DB, opts ...Option) *Store {
+	s := &Store{db: db, subs: make(map[chan struct{}]struct{})}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Store) CreateTrail(ctx context.Context, t audit.Trail) error {
@@ -37,47 +50,201 @@ func (s *Store) CreateTrail(ctx context.Context, t audit.Trail) error {
 	return err
 }
 
+// ledgerLockKey is the transaction-level advisory lock that serializes
+// appends to the global ledger.
+const ledgerLockKey = 0x70726f76 // "prov"
+
 func (s *Store) AppendEvent(ctx context.Context, e audit.Event) error {
+	return s.appendEvent(ctx, e, false)
+}
+
+func (s *Store) CompareAndAppend(ctx context.Context, e audit.Event) error {
+	return s.appendEvent(ctx, e, true)
+}
+
+// appendEvent inserts e and links it into the ledger in one transaction.
+// With checkHead it first checks that e chains to the trail's latest event.
+func (s *Store) appendEvent(ctx context.Context, e audit.Event, checkHead bool) error {
 	if e.ID == "" {
 		return errors.New("event id is required")
 	}
 
-	actorJSON, err := json.Marshal(e.Actor)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	targetsJSON, err := json.Marshal(e.Targets)
+	defer tx.Rollback()
+
+	// One ledger means one writer at a time; the lock is held until commit,
+	// so seq values are assigned in ledger order.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, ledgerLockKey); err != nil {
+		return err
+	}
+
+	var id string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM audit_trails WHERE id = $1 FOR UPDATE`, e.TrailID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return audit.ErrTrailNotFound
+	}
 	if err != nil {
 		return err
 	}
-	commandsJSON, err := json.Marshal(e.Commands)
+
+	if checkHead {
+		var head string
+		err = tx.QueryRowContext(ctx, `
+			SELECT hash
+			FROM audit_events
+			WHERE trail_id = $1
+			ORDER BY seq DESC
+			LIMIT 1
+		`, e.TrailID).Scan(&head)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if head != e.PrevHash {
+			return audit.ErrConflict
+		}
+	}
+
+	entry := audit.LedgerEntry{TrailID: e.TrailID, EventID: e.ID, EventHash: e.Hash}
+	err = tx.QueryRowContext(ctx, `
+		SELECT ledger_hash
+		FROM audit_events
+		ORDER BY seq DESC
+		LIMIT 1
+	`).Scan(&entry.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if entry.Hash, err = audit.ComputeLedgerHash(entry); err != nil {
+		return err
+	}
+
+	seq, err := insertEvent(ctx, tx, e, entry)
 	if err != nil {
+		// audit_events_trail_prev_hash_idx is the last line of defence
+		// against two events chaining to the same predecessor, with or
+		// without checkHead.
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "audit_events_trail_prev_hash_idx" {
+			return audit.ErrConflict
+		}
+		return err
+	}
+
+	if len(s.sinks) > 0 {
+		e.Seq = seq
+		if err := insertDeliveries(ctx, tx, e, s.sinks); err != nil {
+			return err
+		}
+	}
+
+	// Delivered on commit to every session listening, in any process.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, '')`, notifyChannel); err != nil {
 		return err
 	}
+	return tx.Commit()
+}
+
+// insertEvent inserts e with its ledger entry and returns the assigned seq.
+func insertEvent(ctx context.Context, tx *sql.Tx, e audit.Event, entry audit.LedgerEntry) (int64, error) {
+	actorJSON, err := json.Marshal(e.Actor)
+	if err != nil {
+		return 0, err
+	}
+	targetsJSON, err := json.Marshal(e.Targets)
+	if err != nil {
+		return 0, err
+	}
+	commandsJSON, err := json.Marshal(e.Commands)
+	if err != nil {
+		return 0, err
+	}
 	resultJSON, err := json.Marshal(e.Result)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	evidenceJSON, err := json.Marshal(e.Evidence)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	_, err = s.db.ExecContext(ctx, `
+	var seq int64
+	err = tx.QueryRowContext(ctx, `
 		INSERT INTO audit_events (
 			trail_id, type, at, actor, targets, commands, result, evidence,
-			correlation_id, prev_hash, hash, id
+			correlation_id, trail_hash, prev_hash, hash, hash_version, key_id, signature, id,
+			ledger_prev_hash, ledger_hash
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
+		RETURNING seq
 	`, e.TrailID, e.Type, e.At, actorJSON, targetsJSON, commandsJSON, resultJSON, evidenceJSON,
-		e.CorrelationID, e.PrevHash, e.Hash, e.ID)
+		e.CorrelationID, e.TrailHash, e.PrevHash, e.Hash, e.HashVersion, e.KeyID, e.Signature, e.ID,
+		entry.PrevHash, entry.Hash).Scan(&seq)
+	return seq, err
+}
+
+func (s *Store) LedgerEntries(ctx context.Context, fromSeq, toSeq int64) ([]audit.LedgerEntry, error) {
+	query := `
+		SELECT seq, trail_id, id, hash, ledger_prev_hash, ledger_hash
+		FROM audit_events
+		WHERE seq >= $1`
+	args := []any{fromSeq}
+	if toSeq > 0 {
+		args = append(args, toSeq)
+		query += fmt.Sprintf(" AND seq <= $%d", len(args))
+	}
+	query += " ORDER BY seq ASC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []audit.LedgerEntry
+	for rows.Next() {
+		var le audit.LedgerEntry
+		if err := rows.Scan(&le.Seq, &le.TrailID, &le.EventID, &le.EventHash, &le.PrevHash, &le.Hash); err != nil {
+			return nil, err
+		}
+		out = append(out, le)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Store) SaveCheckpoint(ctx context.Context, c audit.Checkpoint) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_checkpoints (tree_size, root_hash, at, key_id, signature)
+		VALUES ($1, $2, $3, $4, $5)
+	`, c.TreeSize, c.RootHash, c.At, c.KeyID, c.Signature)
 	return err
 }
 
+func (s *Store) LatestCheckpoint(ctx context.Context) (*audit.Checkpoint, error) {
+	var c audit.Checkpoint
+	err := s.db.QueryRowContext(ctx, `
+		SELECT tree_size, root_hash, at, key_id, signature
+		FROM audit_checkpoints
+		ORDER BY tree_size DESC
+		LIMIT 1
+	`).Scan(&c.TreeSize, &c.RootHash, &c.At, &c.KeyID, &c.Signature)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
 func (s *Store) LatestEvent(ctx context.Context, trailID string) (*audit.Event, error) {
 	row := s.db.QueryRowContext(ctx, `
-		SELECT id, trail_id, type, at, actor, targets, commands, result, evidence,
-		       correlation_id, prev_hash, hash
+		SELECT `+eventColumns+`
 		FROM audit_events
 		WHERE trail_id = $1
 		ORDER BY seq DESC
@@ -107,7 +274,7 @@ func (s *Store) GetTrail(ctx context.Context, trailID string) (audit.Trail, []au
 	err := row.Scan(&t.ID, &t.CreatedAt, &t.Title, &t.Description, &t.CorrelationID, &targetsJSON)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return audit.Trail{}, nil, errors.New("trail not found")
+			return audit.Trail{}, nil, audit.ErrTrailNotFound
 		}
 		return audit.Trail{}, nil, err
 	}
@@ -119,8 +286,7 @@ func (s *Store) GetTrail(ctx context.Context, trailID string) (audit.Trail, []au
 	}
 
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, trail_id, type, at, actor, targets, commands, result, evidence,
-		       correlation_id, prev_hash, hash
+		SELECT `+eventColumns+`
 		FROM audit_events
 		WHERE trail_id = $1
 		ORDER BY seq ASC
@@ -145,17 +311,66 @@ func (s *Store) GetTrail(ctx context.Context, trailID string) (audit.Trail, []au
 	return t, events, nil
 }
 
-func (s *Store) QueryEvents(ctx context.Context, q audit.Query) ([]audit.Event, error) {
+func (s *Store) QueryEvents(ctx context.Context, q audit.Query) (audit.EventPage, error) {
+	limit := q.Limit
+	if limit > 0 {
+		// One extra row tells us whether there is a next page.
+		limit++
+	}
+	query, args, err := eventQuery(q, limit)
+	if err != nil {
+		return audit.EventPage{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return audit.EventPage{}, err
+	}
+	defer rows.Close()
+
+	var page audit.EventPage
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return audit.EventPage{}, err
+		}
+		page.Events = append(page.Events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return audit.EventPage{}, err
+	}
+
+	if q.Limit > 0 && len(page.Events) > q.Limit {
+		page.Events = page.Events[:q.Limit]
+		page.NextCursor = audit.EncodeEventCursor(audit.EventCursor{Seq: page.Events[q.Limit-1].Seq})
+	}
+	return page, nil
+}
+
+// eventQuery builds the SELECT for q, returning at most limit rows
+// (limit <= 0 means no limit).
+func eventQuery(q audit.Query, limit int) (string, []any, error) {
 	var args []any
 	var b strings.Builder
 
 	b.WriteString(`
-		SELECT id, trail_id, type, at, actor, targets, commands, result, evidence,
-		       correlation_id, prev_hash, hash
+		SELECT ` + eventColumns + `
 		FROM audit_events
 		WHERE 1=1
 	`)
 
+	dir, cmp := "DESC", "<"
+	if q.Order.Ascending() {
+		dir, cmp = "ASC", ">"
+	}
+	if q.Cursor != "" {
+		c, err := audit.DecodeEventCursor(q.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, c.Seq)
+		fmt.Fprintf(&b, " AND seq %s $%d", cmp, len(args))
+	}
 	if !q.From.IsZero() {
 		args = append(args, q.From)
 		fmt.Fprintf(&b, " AND at >= $%d", len(args))
@@ -168,45 +383,257 @@ func (s *Store) QueryEvents(ctx context.Context, q audit.Query) ([]audit.Event,
 		args = append(args, pq.Array(q.EventTypes))
 		fmt.Fprintf(&b, " AND type = ANY($%d)", len(args))
 	}
-	if q.TargetType != "" && q.TargetID != "" {
-		filter := []struct {
-			Type string `json:"type"`
-			ID   string `json:"id"`
-		}{{Type: q.TargetType, ID: q.TargetID}}
-		filterJSON, err := json.Marshal(filter)
+	if ts := q.AnyTargets(); len(ts) > 0 {
+		b.WriteString(" AND (")
+		for i, t := range ts {
+			filterJSON, err := targetFilter(t.Type, t.ID)
+			if err != nil {
+				return "", nil, err
+			}
+			if i > 0 {
+				b.WriteString(" OR ")
+			}
+			args = append(args, filterJSON)
+			fmt.Fprintf(&b, "targets @> $%d::jsonb", len(args))
+		}
+		b.WriteString(")")
+	}
+	if len(q.TargetLabels) > 0 {
+		// [{"labels": {...}}] is contained in targets when a single target
+		// carries every label, which the GIN index can answer.
+		filterJSON, err := json.Marshal([]struct {
+			Labels map[string]string `json:"labels"`
+		}{{Labels: q.TargetLabels}})
 		if err != nil {
-			return nil, err
+			return "", nil, err
 		}
 		args = append(args, filterJSON)
 		fmt.Fprintf(&b, " AND targets @> $%d::jsonb", len(args))
 	}
+	if q.ActorID != "" {
+		args = append(args, q.ActorID)
+		fmt.Fprintf(&b, " AND actor->>'id' = $%d", len(args))
+	}
+	if q.ActorRole != "" {
+		args = append(args, q.ActorRole)
+		fmt.Fprintf(&b, " AND actor->>'role' = $%d", len(args))
+	}
+	if q.CorrelationID != "" {
+		args = append(args, q.CorrelationID)
+		fmt.Fprintf(&b, " AND correlation_id = $%d", len(args))
+	}
+	if len(q.TrailIDs) > 0 {
+		args = append(args, pq.Array(q.TrailIDs))
+		fmt.Fprintf(&b, " AND trail_id = ANY($%d)", len(args))
+	}
+	if q.ResultStatus != "" {
+		args = append(args, q.ResultStatus)
+		fmt.Fprintf(&b, " AND result->>'status' = $%d", len(args))
+	}
+
+	b.WriteString(" ORDER BY seq " + dir)
+	if limit > 0 {
+		args = append(args, limit)
+		fmt.Fprintf(&b, " LIMIT $%d", len(args))
+	}
+
+	return b.String(), args, nil
+}
+
+// IterateEvents streams rows straight from database/sql, so memory use does
+// not grow with the result set.
+func (s *Store) IterateEvents(ctx context.Context, q audit.Query) (audit.EventIterator, error) {
+	query, args, err := eventQuery(q, q.Limit)
+	if err != nil {
+		return nil, err
+	}
 
-	b.WriteString(" ORDER BY seq DESC")
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &rowsIterator{ctx: ctx, rows: rows}, nil
+}
+
+// rowsIterator adapts *sql.Rows to audit.EventIterator. database/sql closes
+// the rows asynchronously on cancellation, so Next also checks ctx itself.
+type rowsIterator struct {
+	ctx  context.Context
+	rows *sql.Rows
+	ev   audit.Event
+	err  error
+}
+
+func (it *rowsIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.err = it.ctx.Err(); it.err != nil {
+		return false
+	}
+	if !it.rows.Next() {
+		return false
+	}
+	it.ev, it.err = scanEvent(it.rows)
+	return it.err == nil
+}
+
+func (it *rowsIterator) Event() audit.Event { return it.ev }
+
+func (it *rowsIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+func (it *rowsIterator) Close() error { return it.rows.Close() }
+
+func (s *Store) ListTrails(ctx context.Context, q audit.TrailQuery) (audit.TrailPage, error) {
+	if err := q.Validate(); err != nil {
+		return audit.TrailPage{}, err
+	}
+
+	var args []any
+	var b strings.Builder
+
+	// The REQUESTED event is the only one with an empty prev_hash, and
+	// audit_events_trail_prev_hash_idx makes it unique per trail.
+	b.WriteString(`
+		SELECT t.id, t.created_at, t.title, t.description, t.correlation_id, t.targets,
+			COALESCE(h.type, ''), h.at, COALESCE(r.actor, '{}'::jsonb)
+		FROM audit_trails t
+		LEFT JOIN audit_events h ON h.seq = (SELECT MAX(seq) FROM audit_events WHERE trail_id = t.id)
+		LEFT JOIN audit_events r ON r.trail_id = t.id AND r.prev_hash = ''
+		WHERE 1=1
+	`)
+
+	if q.Cursor != "" {
+		c, err := audit.DecodeTrailCursor(q.Cursor)
+		if err != nil {
+			return audit.TrailPage{}, err
+		}
+		args = append(args, c.CreatedAt, c.ID)
+		fmt.Fprintf(&b, " AND (t.created_at, t.id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+	if !q.From.IsZero() {
+		args = append(args, q.From)
+		fmt.Fprintf(&b, " AND t.created_at >= $%d", len(args))
+	}
+	if !q.To.IsZero() {
+		args = append(args, q.To)
+		fmt.Fprintf(&b, " AND t.created_at < $%d", len(args))
+	}
+	if q.TitleContains != "" {
+		args = append(args, q.TitleContains)
+		fmt.Fprintf(&b, " AND strpos(lower(t.title), lower($%d)) > 0", len(args))
+	}
+	if q.CorrelationID != "" {
+		args = append(args, q.CorrelationID)
+		fmt.Fprintf(&b, " AND t.correlation_id = $%d", len(args))
+	}
+	if q.TargetType != "" {
+		// Without an ID, containment matches any target of the type.
+		target := map[string]string{"type": q.TargetType}
+		if q.TargetID != "" {
+			target["id"] = q.TargetID
+		}
+		filterJSON, err := json.Marshal([]map[string]string{target})
+		if err != nil {
+			return audit.TrailPage{}, err
+		}
+		args = append(args, filterJSON)
+		fmt.Fprintf(&b, " AND t.targets @> $%d::jsonb", len(args))
+	}
+	if q.RequesterID != "" {
+		args = append(args, q.RequesterID)
+		fmt.Fprintf(&b, " AND r.actor->>'id' = $%d", len(args))
+	}
+	if q.Status != "" {
+		args = append(args, q.Status)
+		fmt.Fprintf(&b, " AND h.type = $%d", len(args))
+	}
+
+	b.WriteString(" ORDER BY t.created_at DESC, t.id DESC")
 	if q.Limit > 0 {
-		args = append(args, q.Limit)
+		// One extra row tells us whether there is a next page.
+		args = append(args, q.Limit+1)
 		fmt.Fprintf(&b, " LIMIT $%d", len(args))
 	}
 
 	rows, err := s.db.QueryContext(ctx, b.String(), args...)
 	if err != nil {
-		return nil, err
+		return audit.TrailPage{}, err
 	}
 	defer rows.Close()
 
-	var out []audit.Event
+	var page audit.TrailPage
 	for rows.Next() {
-		ev, err := scanEvent(rows)
+		sum, err := scanTrailSummary(rows)
 		if err != nil {
-			return nil, err
+			return audit.TrailPage{}, err
 		}
-		out = append(out, ev)
+		page.Trails = append(page.Trails, sum)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return audit.TrailPage{}, err
 	}
-	return out, nil
+
+	if q.Limit > 0 && len(page.Trails) > q.Limit {
+		page.Trails = page.Trails[:q.Limit]
+		last := page.Trails[q.Limit-1]
+		page.NextCursor = audit.EncodeTrailCursor(audit.TrailCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	return page, nil
 }
 
+func scanTrailSummary(r rowScanner) (audit.TrailSummary, error) {
+	var sum audit.TrailSummary
+	var targetsJSON, actorJSON []byte
+	var updatedAt sql.NullTime
+
+	err := r.Scan(
+		&sum.ID,
+		&sum.CreatedAt,
+		&sum.Title,
+		&sum.Description,
+		&sum.CorrelationID,
+		&targetsJSON,
+		&sum.Status,
+		&updatedAt,
+		&actorJSON,
+	)
+	if err != nil {
+		return audit.TrailSummary{}, err
+	}
+	sum.UpdatedAt = updatedAt.Time
+
+	if len(targetsJSON) > 0 {
+		if err := json.Unmarshal(targetsJSON, &sum.Targets); err != nil {
+			return audit.TrailSummary{}, err
+		}
+	}
+	if len(actorJSON) > 0 {
+		if err := json.Unmarshal(actorJSON, &sum.Requester); err != nil {
+			return audit.TrailSummary{}, err
+		}
+	}
+	return sum, nil
+}
+
+// targetFilter is the JSONB containment operand matching a targets array
+// that includes the target typ/id.
+func targetFilter(typ, id string) ([]byte, error) {
+	return json.Marshal([]struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}{{Type: typ, ID: id}})
+}
+
+// eventColumns is the column list scanEvent expects, in order.
+const eventColumns = `seq, id, trail_id, type, at, actor, targets, commands, result, evidence,
+	correlation_id, trail_hash, prev_hash, hash, hash_version, key_id, signature`
+
 type rowScanner interface {
 	Scan(dest ...any) error
 }
@@ -216,6 +643,7 @@ func scanEvent(r rowScanner) (audit.Event, error) {
 	var actorJSON, targetsJSON, commandsJSON, resultJSON, evidenceJSON []byte
 
 	err := r.Scan(
+		&ev.Seq,
 		&ev.ID,
 		&ev.TrailID,
 		&ev.Type,
@@ -226,8 +654,12 @@ func scanEvent(r rowScanner) (audit.Event, error) {
 		&resultJSON,
 		&evidenceJSON,
 		&ev.CorrelationID,
+		&ev.TrailHash,
 		&ev.PrevHash,
 		&ev.Hash,
+		&ev.HashVersion,
+		&ev.KeyID,
+		&ev.Signature,
 	)
 	if err != nil {
 		return audit.Event{}, err
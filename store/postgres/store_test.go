@@ -0,0 +1,160 @@
+package postgres_test
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/postgres"
+	"github.com/ajazfarhad/provenance/store/storetest"
+)
+
+// dsnEnv names a database the tests may create schemas in, e.g.
+// postgres://postgres@localhost/postgres?sslmode=disable.
+const dsnEnv = "PROVENANCE_TEST_POSTGRES_DSN"
+
+// openDB returns a connection to a new, migrated schema that is dropped
+// when the test ends, and the DSN of that schema.
+func openDB(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	db, dsn := emptySchema(t)
+	if err := postgres.Migrate(context.Background(), db); err != nil {
+		t.Fatalf("Migrate error: %v", err)
+	}
+	return db, dsn
+}
+
+// emptySchema returns a connection to a new schema with no tables that is
+// dropped when the test ends, and the DSN of that schema.
+func emptySchema(t *testing.T) (*sql.DB, string) {
+	t.Helper()
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skip(dsnEnv + " is not set")
+	}
+	ctx := context.Background()
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	var b [6]byte
+	rand.Read(b[:])
+	schema := "provenance_test_" + hex.EncodeToString(b[:])
+	if _, err := admin.ExecContext(ctx, "CREATE SCHEMA "+schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() { admin.ExecContext(ctx, "DROP SCHEMA "+schema+" CASCADE") })
+
+	dsn = withSearchPath(t, dsn, schema)
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, dsn
+}
+
+// withSearchPath points every connection of dsn at schema.
+func withSearchPath(t *testing.T, dsn, schema string) string {
+	if !strings.Contains(dsn, "://") {
+		return dsn + " search_path=" + schema
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("parse %s: %v", dsnEnv, err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) audit.Store {
+		db, dsn := openDB(t)
+		st := postgres.New(db, postgres.WithOutbox(storetest.OutboxSinks...), postgres.WithNotifications(dsn))
+		t.Cleanup(func() { st.Close() })
+		return st
+	})
+}
+
+// The unique (trail_id, prev_hash) index stops forks even without a head
+// check, and its violation must surface as ErrConflict.
+func TestAppendEventForkIsConflict(t *testing.T) {
+	ctx := context.Background()
+	db, _ := openDB(t)
+	st := postgres.New(db)
+	svc := audit.NewService(st, nil)
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+
+	fork := events[1]
+	fork.ID, fork.Hash = "fork", "fork-hash"
+	if err := st.AppendEvent(ctx, fork); !errors.Is(err, audit.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+}
+
+// A database created from the schema.sql shipped before migrations existed
+// must upgrade to a schema the current store can append to and verify.
+func TestMigrateFromBaselineSchema(t *testing.T) {
+	ctx := context.Background()
+	db, _ := emptySchema(t)
+
+	schema, err := os.ReadFile("testdata/schema.sql")
+	if err != nil {
+		t.Fatalf("read baseline schema: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, string(schema)); err != nil {
+		t.Fatalf("apply baseline schema: %v", err)
+	}
+	for i := 0; i < 2; i++ { // the second run must be a no-op
+		if err := postgres.Migrate(ctx, db); err != nil {
+			t.Fatalf("Migrate error: %v", err)
+		}
+	}
+
+	st := postgres.New(db, postgres.WithOutbox())
+	svc := audit.NewService(st, nil)
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+	if err := svc.VerifyLedger(ctx, 1, 0); err != nil {
+		t.Fatalf("VerifyLedger error: %v", err)
+	}
+	if _, err := svc.Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint error: %v", err)
+	}
+	if due, err := st.Deliveries(ctx, audit.DefaultSink, 0); err != nil || len(due) != 2 {
+		t.Fatalf("expected 2 outbox deliveries, got %d (err %v)", len(due), err)
+	}
+}
@@ -0,0 +1,367 @@
+// Package remote implements audit.Store against a provenance server started
+// with server.WithStore, so agents record changes without holding database
+// credentials:
+//
+//	svc := provenance.New(remote.New("https://audit.internal", remote.WithKeyResolver(keys)))
+//
+// The server is not trusted with history. Every trail it returns is checked
+// like VerifyTrail before use: the hash chain, the header hash and, with
+// WithKeyResolver, every signature. The store also remembers the head of
+// each trail it has seen or written, so a server that later returns a
+// shorter or different history is caught even if it recomputed the hashes.
+// Query results are checked event by event (hash, signature, and that the
+// event matches the query); a server can still omit events from a query,
+// which VerifyLedger and checkpoints detect.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/server"
+)
+
+// ErrHistoryRewritten is returned when the server returns a trail that does
+// not extend the history this Store saw earlier.
+var ErrHistoryRewritten = errors.New("remote: trail history differs from what this client saw")
+
+// pageSize is how many events IterateEvents fetches per request.
+const pageSize = 500
+
+type Store struct {
+	url    string
+	client *http.Client
+	keys   audit.KeyResolver
+	header http.Header
+
+	mu   sync.Mutex
+	seen map[string]head // trail ID => latest head this client verified
+}
+
+// head is a trail's length and latest hash at some point in time.
+type head struct {
+	n    int
+	hash string
+}
+
+type Option func(*Store)
+
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Store) { s.client = c }
+}
+
+// WithKeyResolver requires a valid signature on every event the server
+// returns. Without it a compromised server can rewrite history this client
+// has not seen yet and recompute the hashes.
+func WithKeyResolver(keys audit.KeyResolver) Option {
+	return func(s *Store) { s.keys = keys }
+}
+
+// WithHeader adds a header to every request, e.g. for authentication.
+func WithHeader(name, value string) Option {
+	return func(s *Store) { s.header.Add(name, value) }
+}
+
+// New returns a Store for the server at baseURL, e.g. "https://audit.internal".
+func New(baseURL string, opts ...Option) *Store {
+	s := &Store{
+		url:    strings.TrimSuffix(baseURL, "/"),
+		client: &http.Client{Timeout: 30 * time.Second},
+		header: make(http.Header),
+		seen:   make(map[string]head),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CreateTrail fails: the server only creates a trail together with its
+// REQUESTED event, which Service.Request sends through StartTrail.
+func (s *Store) CreateTrail(ctx context.Context, t audit.Trail) error {
+	return fmt.Errorf("%w: remote: create trail %s with StartTrail", audit.ErrInvalid, t.ID)
+}
+
+// StartTrail creates t and appends first, its REQUESTED event, in one
+// request, so a rejected event leaves no trail behind.
+func (s *Store) StartTrail(ctx context.Context, t audit.Trail, first audit.Event) error {
+	if err := s.do(ctx, http.MethodPost, "/v1/store/trails", server.StartBody{Trail: t, Event: first}, nil); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen[t.ID] = head{n: 1, hash: first.Hash}
+	return nil
+}
+
+// AppendEvent is CompareAndAppend: the server only appends events that
+// extend their trail's head.
+func (s *Store) AppendEvent(ctx context.Context, e audit.Event) error {
+	return s.CompareAndAppend(ctx, e)
+}
+
+func (s *Store) CompareAndAppend(ctx context.Context, e audit.Event) error {
+	if err := s.do(ctx, http.MethodPost, "/v1/store/events", server.AppendBody{Event: e}, nil); err != nil {
+		return err
+	}
+
+	// The server accepted e as the successor of e.PrevHash, so it is the
+	// new head if that was the head we knew.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if h, ok := s.seen[e.TrailID]; ok && h.hash == e.PrevHash {
+		s.seen[e.TrailID] = head{n: h.n + 1, hash: e.Hash}
+	} else if !ok && e.PrevHash == "" {
+		s.seen[e.TrailID] = head{n: 1, hash: e.Hash}
+	}
+	return nil
+}
+
+func (s *Store) GetTrail(ctx context.Context, trailID string) (audit.Trail, []audit.Event, error) {
+	var resp server.TrailResponse
+	if err := s.do(ctx, http.MethodGet, "/v1/store/trails/"+url.PathEscape(trailID), nil, &resp); err != nil {
+		return audit.Trail{}, nil, err
+	}
+	if resp.Trail.ID != trailID {
+		return audit.Trail{}, nil, fmt.Errorf("remote: asked for trail %s, got %s", trailID, resp.Trail.ID)
+	}
+	if err := audit.VerifyEvents(ctx, resp.Trail, resp.Events, s.keys); err != nil {
+		return audit.Trail{}, nil, err
+	}
+	if err := s.extend(trailID, resp.Events); err != nil {
+		return audit.Trail{}, nil, err
+	}
+	return resp.Trail, resp.Events, nil
+}
+
+// extend checks that events continue the history last seen for the trail
+// and records their head.
+func (s *Store) extend(trailID string, events []audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if h, ok := s.seen[trailID]; ok {
+		if len(events) < h.n || events[h.n-1].Hash != h.hash {
+			return fmt.Errorf("%w: trail %s", ErrHistoryRewritten, trailID)
+		}
+	}
+	if n := len(events); n > 0 {
+		s.seen[trailID] = head{n: n, hash: events[n-1].Hash}
+	}
+	return nil
+}
+
+// LatestEvent reads the whole trail, so the event is verified against the
+// chain.
+func (s *Store) LatestEvent(ctx context.Context, trailID string) (*audit.Event, error) {
+	_, events, err := s.GetTrail(ctx, trailID)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+	return &events[len(events)-1], nil
+}
+
+func (s *Store) ListTrails(ctx context.Context, q audit.TrailQuery) (audit.TrailPage, error) {
+	if err := q.Validate(); err != nil {
+		return audit.TrailPage{}, err
+	}
+	var page audit.TrailPage
+	if err := s.do(ctx, http.MethodPost, "/v1/store/trails/query", q, &page); err != nil {
+		return audit.TrailPage{}, err
+	}
+	for _, t := range page.Trails {
+		if !q.Matches(t) {
+			return audit.TrailPage{}, fmt.Errorf("remote: trail %s does not match the query", t.ID)
+		}
+	}
+	return page, nil
+}
+
+func (s *Store) QueryEvents(ctx context.Context, q audit.Query) (audit.EventPage, error) {
+	var page audit.EventPage
+	if err := s.do(ctx, http.MethodPost, "/v1/store/events/query", q, &page); err != nil {
+		return audit.EventPage{}, err
+	}
+	if q.Limit > 0 && len(page.Events) > q.Limit {
+		return audit.EventPage{}, fmt.Errorf("remote: asked for %d events, got %d", q.Limit, len(page.Events))
+	}
+	for _, e := range page.Events {
+		if err := s.checkEvent(ctx, q, e); err != nil {
+			return audit.EventPage{}, err
+		}
+	}
+	return page, nil
+}
+
+// checkEvent verifies a single event from a query result.
+func (s *Store) checkEvent(ctx context.Context, q audit.Query, e audit.Event) error {
+	if !q.Matches(e) {
+		return fmt.Errorf("remote: event %s does not match the query", e.ID)
+	}
+	sum, err := audit.ComputeEventHash(e)
+	if err != nil {
+		return err
+	}
+	if sum != e.Hash {
+		return fmt.Errorf("remote: event %s: %w", e.ID, audit.ErrHashMismatch)
+	}
+	if s.keys == nil {
+		return nil
+	}
+	if e.Signature == "" {
+		return fmt.Errorf("remote: event %s: missing signature: %w", e.ID, audit.ErrBadSignature)
+	}
+	pub, err := s.keys.ResolveKey(ctx, e.KeyID)
+	if err != nil {
+		return fmt.Errorf("remote: event %s: cannot resolve key %q: %v: %w", e.ID, e.KeyID, err, audit.ErrBadSignature)
+	}
+	if err := audit.VerifyEventSignature(e, pub); err != nil {
+		return fmt.Errorf("remote: event %s: %v: %w", e.ID, err, audit.ErrBadSignature)
+	}
+	return nil
+}
+
+// IterateEvents pages through QueryEvents.
+func (s *Store) IterateEvents(ctx context.Context, q audit.Query) (audit.EventIterator, error) {
+	return &pageIterator{ctx: ctx, s: s, q: q, remaining: q.Limit}, nil
+}
+
+type pageIterator struct {
+	ctx       context.Context
+	s         *Store
+	q         audit.Query
+	remaining int // 0: unlimited
+
+	buf  []audit.Event
+	done bool
+	ev   audit.Event
+	err  error
+}
+
+func (it *pageIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.err = it.ctx.Err(); it.err != nil {
+		return false
+	}
+	for len(it.buf) == 0 {
+		if it.done {
+			return false
+		}
+		q := it.q
+		q.Limit = pageSize
+		if it.q.Limit > 0 {
+			q.Limit = min(pageSize, it.remaining)
+		}
+		page, err := it.s.QueryEvents(it.ctx, q)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.buf = page.Events
+		it.q.Cursor = page.NextCursor
+		it.remaining -= len(page.Events)
+		it.done = page.NextCursor == "" || (it.q.Limit > 0 && it.remaining <= 0)
+	}
+	it.ev, it.buf = it.buf[0], it.buf[1:]
+	return true
+}
+
+func (it *pageIterator) Event() audit.Event { return it.ev }
+func (it *pageIterator) Err() error         { return it.err }
+
+func (it *pageIterator) Close() error {
+	it.buf, it.done = nil, true
+	return nil
+}
+
+// LedgerEntries implements audit.Ledger. The entries are not checked here;
+// Service.VerifyLedger recomputes their hashes.
+func (s *Store) LedgerEntries(ctx context.Context, fromSeq, toSeq int64) ([]audit.LedgerEntry, error) {
+	path := "/v1/store/ledger?from_seq=" + strconv.FormatInt(fromSeq, 10) + "&to_seq=" + strconv.FormatInt(toSeq, 10)
+	var resp server.LedgerResponse
+	if err := s.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Entries, nil
+}
+
+// do sends in as JSON (if not nil) and decodes the response into out (if
+// not nil). Error responses are mapped back to audit errors where one fits.
+func (s *Store) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.url+path, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range s.header {
+		req.Header[k] = v
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return decodeError(resp)
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("remote: %s %s: decode response: %w", method, path, err)
+	}
+	return nil
+}
+
+func decodeError(resp *http.Response) error {
+	var body server.ErrorResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil || body.Error == nil {
+		return fmt.Errorf("remote: %s %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status)
+	}
+
+	e := body.Error
+	switch {
+	case e.Code == server.CodeNotFound && e.Details["resource"] == "trail":
+		return audit.ErrTrailNotFound
+	case e.Code == server.CodeConflict:
+		return audit.ErrConflict
+	case e.Code == server.CodeUnimplemented:
+		return audit.ErrNoLedger
+	case e.Code == server.CodeInvalidArgument:
+		return fmt.Errorf("remote: %s: %w", e.Message, audit.ErrInvalid)
+	default:
+		e.Status = resp.StatusCode
+		return fmt.Errorf("remote: %w", e)
+	}
+}
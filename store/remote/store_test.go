@@ -0,0 +1,300 @@
+package remote_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/server"
+	"github.com/ajazfarhad/provenance/store/memory"
+	"github.com/ajazfarhad/provenance/store/remote"
+)
+
+// serve runs a provenance server whose store API reads through st.
+func serve(t *testing.T, st audit.Store) string {
+	t.Helper()
+	srv := httptest.NewServer(server.New(audit.NewService(st, nil), server.WithStore(st)))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func seed(t *testing.T, svc *audit.Service) string {
+	t.Helper()
+	ctx := context.Background()
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{
+		Title:     "Update NTP",
+		Requester: audit.Actor{ID: "u-1"},
+		Targets:   []audit.Target{{Type: "network_device", ID: "sw-12"}},
+	})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.Execute(ctx, trailID, audit.Actor{ID: "svc-1"}, "corr",
+		[]audit.Command{{Kind: "cli", Raw: "ntp server 10.0.0.1"}},
+		audit.Result{Status: "SUCCESS"},
+	); err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	return trailID
+}
+
+func TestServiceOverRemoteStore(t *testing.T) {
+	ctx := context.Background()
+	backend := memory.New()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	st := remote.New(serve(t, backend), remote.WithKeyResolver(audit.StaticKeys{"k1": pub}))
+	svc := audit.NewService(st, nil, audit.WithSigner(audit.NewEd25519Signer("k1", priv)))
+	trailID := seed(t, svc)
+	for i := 0; i < 3; i++ {
+		seed(t, svc)
+	}
+
+	// The events landed in the server's store.
+	if _, events, err := backend.GetTrail(ctx, trailID); err != nil || len(events) != 3 {
+		t.Fatalf("backend has %d events, err %v", len(events), err)
+	}
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+	if err := svc.VerifyLedger(ctx, 0, 0); err != nil {
+		t.Fatalf("VerifyLedger error: %v", err)
+	}
+
+	events, err := svc.WhatChanged(ctx, audit.Target{Type: "network_device", ID: "sw-12"}, time.Time{}, time.Time{}, 5)
+	if err != nil || len(events) != 5 {
+		t.Fatalf("WhatChanged: %d events, err %v", len(events), err)
+	}
+
+	it, err := svc.IterateEvents(ctx, audit.Query{Order: audit.OrderAsc})
+	if err != nil {
+		t.Fatalf("IterateEvents error: %v", err)
+	}
+	var n int
+	for it.Next() {
+		n++
+		if it.Event().Seq != int64(n) {
+			t.Fatalf("event %d has seq %d", n, it.Event().Seq)
+		}
+	}
+	if err := it.Err(); err != nil || n != 12 {
+		t.Fatalf("iterated %d events, err %v", n, err)
+	}
+
+	page, err := svc.ListTrails(ctx, audit.TrailQuery{Limit: 2})
+	if err != nil || len(page.Trails) != 2 || page.NextCursor == "" {
+		t.Fatalf("ListTrails: %+v, err %v", page, err)
+	}
+
+	if _, _, err := st.GetTrail(ctx, "nope"); !errors.Is(err, audit.ErrTrailNotFound) {
+		t.Fatalf("expected ErrTrailNotFound, got %v", err)
+	}
+	if _, err := svc.Request(ctx, audit.RequestInput{Requester: audit.Actor{ID: "u-1"}}); !errors.Is(err, audit.ErrInvalid) {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+}
+
+func TestCompareAndAppendConflict(t *testing.T) {
+	ctx := context.Background()
+	st := remote.New(serve(t, memory.New()))
+	trailID := seed(t, audit.NewService(st, nil))
+
+	latest, err := st.LatestEvent(ctx, trailID)
+	if err != nil {
+		t.Fatalf("LatestEvent error: %v", err)
+	}
+	e := audit.Event{ID: "stale", TrailID: trailID, Type: audit.EventVerified, PrevHash: "not-the-head", Actor: latest.Actor}
+	e.Hash, _ = audit.ComputeEventHash(e)
+	if err := st.CompareAndAppend(ctx, e); !errors.Is(err, audit.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+
+	// The server refuses events whose hash does not match their content.
+	e.PrevHash, e.Hash = latest.Hash, "forged"
+	if err := st.CompareAndAppend(ctx, e); !errors.Is(err, audit.ErrInvalid) {
+		t.Fatalf("expected ErrInvalid, got %v", err)
+	}
+}
+
+// compromised serves a rewritten copy of every trail once rewrite is set.
+type compromised struct {
+	audit.Store
+	rewrite func([]audit.Event) []audit.Event
+}
+
+func (c *compromised) GetTrail(ctx context.Context, id string) (audit.Trail, []audit.Event, error) {
+	t, events, err := c.Store.GetTrail(ctx, id)
+	if c.rewrite != nil && err == nil {
+		events = c.rewrite(events)
+	}
+	return t, events, err
+}
+
+func (c *compromised) QueryEvents(ctx context.Context, q audit.Query) (audit.EventPage, error) {
+	page, err := c.Store.QueryEvents(ctx, q)
+	if c.rewrite != nil && err == nil {
+		page.Events = c.rewrite(page.Events)
+	}
+	return page, err
+}
+
+// rehash recomputes the chain from the first event, as an attacker with
+// database access would.
+func rehash(events []audit.Event) []audit.Event {
+	prev := ""
+	for i := range events {
+		events[i].PrevHash = prev
+		events[i].Hash, _ = audit.ComputeEventHash(events[i])
+		prev = events[i].Hash
+	}
+	return events
+}
+
+func TestDetectsCompromisedServer(t *testing.T) {
+	ctx := context.Background()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	cases := []struct {
+		name    string
+		keys    bool
+		rewrite func([]audit.Event) []audit.Event
+		want    error
+	}{
+		{"edited command", false, func(es []audit.Event) []audit.Event {
+			for i := range es {
+				if es[i].Type == audit.EventExecuted {
+					es[i].Commands[0].Raw = "no ntp"
+				}
+			}
+			return es
+		}, audit.ErrHashMismatch},
+		{"dropped event", false, func(es []audit.Event) []audit.Event {
+			if len(es) > 0 && es[len(es)-1].Type == audit.EventExecuted {
+				es = es[:len(es)-1]
+			}
+			return es
+		}, remote.ErrHistoryRewritten},
+		{"rehashed rewrite", true, func(es []audit.Event) []audit.Event {
+			for i := range es {
+				if es[i].Type == audit.EventExecuted {
+					es[i].Commands[0].Raw = "no ntp"
+				}
+			}
+			return rehash(es)
+		}, audit.ErrBadSignature},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			backend := &compromised{Store: memory.New()}
+			var opts []remote.Option
+			if tc.keys {
+				opts = append(opts, remote.WithKeyResolver(audit.StaticKeys{"k1": pub}))
+			}
+			st := remote.New(serve(t, backend), opts...)
+			trailID := seed(t, audit.NewService(st, nil, audit.WithSigner(audit.NewEd25519Signer("k1", priv))))
+
+			backend.rewrite = tc.rewrite
+			if _, _, err := st.GetTrail(ctx, trailID); !errors.Is(err, tc.want) {
+				t.Fatalf("GetTrail: expected %v, got %v", tc.want, err)
+			}
+			if tc.want == remote.ErrHistoryRewritten {
+				return
+			}
+			if _, err := st.QueryEvents(ctx, audit.Query{TrailIDs: []string{trailID}}); !errors.Is(err, tc.want) {
+				t.Fatalf("QueryEvents: expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+// The server checks appends with its own Service, so a client that skips
+// the sanitizer, lifecycle, policies or signing cannot write around them.
+func TestServerEnforcesItsRules(t *testing.T) {
+	ctx := context.Background()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+
+	backend := memory.New()
+	serverSvc := audit.NewService(backend,
+		audit.TextSanitizer(func(_, text string) string { return strings.ReplaceAll(text, "s3cret", "[redacted]") }),
+		audit.WithPolicy(audit.ApprovalPolicy{Quorum: 1, SeparateDuties: true}),
+		audit.WithPolicy(audit.PolicyFunc(func(trail audit.Trail, _ []audit.Event, next audit.Event) error {
+			if next.Type == audit.EventRequested && next.Actor.ID == "u-9" {
+				return &audit.PolicyError{TrailID: trail.ID, Type: next.Type, ActorID: next.Actor.ID, Reason: "suspended"}
+			}
+			return nil
+		})),
+		audit.WithKeyResolver(audit.StaticKeys{"k1": pub}),
+	)
+	srv := httptest.NewServer(server.New(serverSvc, server.WithStore(backend)))
+	t.Cleanup(srv.Close)
+
+	signer := audit.WithSigner(audit.NewEd25519Signer("k1", priv))
+	lax := audit.NewService(remote.New(srv.URL), nil, signer, audit.WithTransitions(nil))
+	code := func(err error) string {
+		var apiErr *server.Error
+		if errors.As(err, &apiErr) {
+			return apiErr.Code
+		}
+		return ""
+	}
+
+	if _, err := lax.Request(ctx, audit.RequestInput{Title: "password s3cret", Requester: audit.Actor{ID: "u-1"}}); !errors.Is(err, audit.ErrInvalid) {
+		t.Fatalf("unsanitized trail: expected ErrInvalid, got %v", err)
+	}
+	fine := audit.Trail{ID: "fine", Title: "Update NTP", CreatedAt: time.Date(2026, 1, 5, 9, 0, 0, 999, time.UTC)}
+	if err := remote.New(srv.URL).StartTrail(ctx, fine, audit.Event{TrailID: fine.ID, Type: audit.EventRequested}); !errors.Is(err, audit.ErrInvalid) {
+		t.Fatalf("sub-microsecond trail time: expected ErrInvalid, got %v", err)
+	}
+	// A rejected REQUESTED event leaves no trail behind.
+	if _, err := lax.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-9"}}); code(err) != server.CodePolicyRejected {
+		t.Fatalf("rejected request: expected %s, got %v", server.CodePolicyRejected, err)
+	}
+	if page, err := backend.ListTrails(ctx, audit.TrailQuery{}); err != nil || len(page.Trails) != 0 {
+		t.Fatalf("rejected request left trails %+v, %v", page.Trails, err)
+	}
+	trailID, err := lax.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := lax.Verify(ctx, trailID, audit.Actor{ID: "u-3"}, "", nil); code(err) != server.CodeInvalidTransition {
+		t.Fatalf("skipped transition: expected %s, got %v", server.CodeInvalidTransition, err)
+	}
+	if err := lax.Approve(ctx, trailID, audit.Actor{ID: "u-1"}, "", ""); code(err) != server.CodePolicyRejected {
+		t.Fatalf("self-approval: expected %s, got %v", server.CodePolicyRejected, err)
+	}
+	if err := lax.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", "s3cret"); !errors.Is(err, audit.ErrInvalid) {
+		t.Fatalf("unsanitized note: expected ErrInvalid, got %v", err)
+	}
+	unsigned := audit.NewService(remote.New(srv.URL), nil)
+	if err := unsigned.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); code(err) != server.CodeVerificationFailed {
+		t.Fatalf("unsigned event: expected %s, got %v", server.CodeVerificationFailed, err)
+	}
+	if err := lax.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+
+	// AppendEvent cannot fork a trail either.
+	_, events, err := backend.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	fork := events[1]
+	fork.ID = "fork"
+	fork.Hash, _ = audit.ComputeEventHash(fork)
+	if err := remote.New(srv.URL).AppendEvent(ctx, fork); !errors.Is(err, audit.ErrConflict) {
+		t.Fatalf("fork: expected ErrConflict, got %v", err)
+	}
+	if err := serverSvc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+ALTER TABLE audit_events ADD COLUMN trail_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN hash_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE audit_events ADD COLUMN key_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN signature TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN ledger_prev_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN ledger_hash TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS audit_events_trail_prev_hash_idx ON audit_events (trail_id, prev_hash);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    tree_size INTEGER PRIMARY KEY,
+    root_hash TEXT NOT NULL,
+    at TIMESTAMP NOT NULL,
+    key_id TEXT NOT NULL DEFAULT '',
+    signature TEXT NOT NULL DEFAULT ''
+);
@@ -5,17 +5,25 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"sort"
 	"strings"
 
 	"github.com/ajazfarhad/provenance/audit"
 )
 
 type Store struct {
-	db *sql.DB
+	db    *sql.DB
+	sinks []string // see WithOutbox
 }
 
-func New(db *sql.DB) *Store {
-	return &Store{db: db}
+type Option func(*Store)
+
+func New(db *sql.DB, opts ...Option) *Store {
+	s := &Store{db: db}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Store) CreateTrail(ctx context.Context, t audit.Trail) error {
@@ -31,51 +39,194 @@ func (s *Store) CreateTrail(ctx context.Context, t audit.Trail) error {
 	_, err = s.db.ExecContext(ctx, `
 		INSERT INTO audit_trails (id, created_at, title, description, correlation_id, targets)
 		VALUES (?, ?, ?, ?, ?, ?)
-	`, t.ID, t.CreatedAt, t.Title, t.Description, t.CorrelationID, targetsJSON)
+	`, t.ID, t.CreatedAt.UTC(), t.Title, t.Description, t.CorrelationID, targetsJSON)
 	return err
 }
 
 func (s *Store) AppendEvent(ctx context.Context, e audit.Event) error {
+	return s.appendEvent(ctx, e, false)
+}
+
+func (s *Store) CompareAndAppend(ctx context.Context, e audit.Event) error {
+	return s.appendEvent(ctx, e, true)
+}
+
+// appendEvent inserts e and links it into the ledger in one transaction.
+// With checkHead it first checks that e chains to the trail's latest event.
+func (s *Store) appendEvent(ctx context.Context, e audit.Event, checkHead bool) error {
 	if e.ID == "" {
 		return errors.New("event id is required")
 	}
 
-	actorJSON, err := json.Marshal(e.Actor)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	targetsJSON, err := json.Marshal(e.Targets)
+	defer tx.Rollback()
+
+	// Take the write lock before reading any head, so concurrent appenders
+	// queue on busy_timeout instead of failing to upgrade a read lock.
+	// SQLite has a single writer, which also serializes the ledger.
+	res, err := tx.ExecContext(ctx, `UPDATE audit_trails SET id = id WHERE id = ?`, e.TrailID)
 	if err != nil {
 		return err
 	}
-	commandsJSON, err := json.Marshal(e.Commands)
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return audit.ErrTrailNotFound
+	}
+
+	if checkHead {
+		var head string
+		err = tx.QueryRowContext(ctx, `
+			SELECT hash
+			FROM audit_events
+			WHERE trail_id = ?
+			ORDER BY seq DESC
+			LIMIT 1
+		`, e.TrailID).Scan(&head)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if head != e.PrevHash {
+			return audit.ErrConflict
+		}
+	}
+
+	entry := audit.LedgerEntry{TrailID: e.TrailID, EventID: e.ID, EventHash: e.Hash}
+	err = tx.QueryRowContext(ctx, `
+		SELECT ledger_hash
+		FROM audit_events
+		ORDER BY seq DESC
+		LIMIT 1
+	`).Scan(&entry.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if entry.Hash, err = audit.ComputeLedgerHash(entry); err != nil {
+		return err
+	}
+
+	seq, err := insertEvent(ctx, tx, e, entry)
 	if err != nil {
+		// audit_events_trail_prev_hash_idx is the last line of defence
+		// against two events chaining to the same predecessor, with or
+		// without checkHead.
+		if strings.Contains(err.Error(), "UNIQUE constraint failed: audit_events.trail_id, audit_events.prev_hash") {
+			return audit.ErrConflict
+		}
 		return err
 	}
+
+	if len(s.sinks) > 0 {
+		e.Seq = seq
+		if err := insertDeliveries(ctx, tx, e, s.sinks); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// insertEvent inserts e with its ledger entry and returns the assigned seq.
+func insertEvent(ctx context.Context, tx *sql.Tx, e audit.Event, entry audit.LedgerEntry) (int64, error) {
+	actorJSON, err := json.Marshal(e.Actor)
+	if err != nil {
+		return 0, err
+	}
+	targetsJSON, err := json.Marshal(e.Targets)
+	if err != nil {
+		return 0, err
+	}
+	commandsJSON, err := json.Marshal(e.Commands)
+	if err != nil {
+		return 0, err
+	}
 	resultJSON, err := json.Marshal(e.Result)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	evidenceJSON, err := json.Marshal(e.Evidence)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	_, err = s.db.ExecContext(ctx, `
+	res, err := tx.ExecContext(ctx, `
 		INSERT INTO audit_events (
 			trail_id, type, at, actor, targets, commands, result, evidence,
-			correlation_id, prev_hash, hash, id
+			correlation_id, trail_hash, prev_hash, hash, hash_version, key_id, signature, id,
+			ledger_prev_hash, ledger_hash
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, e.TrailID, e.Type, e.At, actorJSON, targetsJSON, commandsJSON, resultJSON, evidenceJSON,
-		e.CorrelationID, e.PrevHash, e.Hash, e.ID)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.TrailID, e.Type, e.At.UTC(), actorJSON, targetsJSON, commandsJSON, resultJSON, evidenceJSON,
+		e.CorrelationID, e.TrailHash, e.PrevHash, e.Hash, e.HashVersion, e.KeyID, e.Signature, e.ID,
+		entry.PrevHash, entry.Hash)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (s *Store) LedgerEntries(ctx context.Context, fromSeq, toSeq int64) ([]audit.LedgerEntry, error) {
+	query := `
+		SELECT seq, trail_id, id, hash, ledger_prev_hash, ledger_hash
+		FROM audit_events
+		WHERE seq >= ?`
+	args := []any{fromSeq}
+	if toSeq > 0 {
+		query += " AND seq <= ?"
+		args = append(args, toSeq)
+	}
+	query += " ORDER BY seq ASC"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []audit.LedgerEntry
+	for rows.Next() {
+		var le audit.LedgerEntry
+		if err := rows.Scan(&le.Seq, &le.TrailID, &le.EventID, &le.EventHash, &le.PrevHash, &le.Hash); err != nil {
+			return nil, err
+		}
+		out = append(out, le)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *Store) SaveCheckpoint(ctx context.Context, c audit.Checkpoint) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_checkpoints (tree_size, root_hash, at, key_id, signature)
+		VALUES (?, ?, ?, ?, ?)
+	`, c.TreeSize, c.RootHash, c.At.UTC(), c.KeyID, c.Signature)
 	return err
 }
 
+func (s *Store) LatestCheckpoint(ctx context.Context) (*audit.Checkpoint, error) {
+	var c audit.Checkpoint
+	err := s.db.QueryRowContext(ctx, `
+		SELECT tree_size, root_hash, at, key_id, signature
+		FROM audit_checkpoints
+		ORDER BY tree_size DESC
+		LIMIT 1
+	`).Scan(&c.TreeSize, &c.RootHash, &c.At, &c.KeyID, &c.Signature)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
 func (s *Store) LatestEvent(ctx context.Context, trailID string) (*audit.Event, error) {
 	row := s.db.QueryRowContext(ctx, `
-		SELECT id, trail_id, type, at, actor, targets, commands, result, evidence,
-		       correlation_id, prev_hash, hash
+		SELECT `+eventColumns+`
 		FROM audit_events
 		WHERE trail_id = ?
 		ORDER BY seq DESC
@@ -105,7 +256,7 @@ func (s *Store) GetTrail(ctx context.Context, trailID string) (audit.Trail, []au
 	err := row.Scan(&t.ID, &t.CreatedAt, &t.Title, &t.Description, &t.CorrelationID, &targetsJSON)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return audit.Trail{}, nil, errors.New("trail not found")
+			return audit.Trail{}, nil, audit.ErrTrailNotFound
 		}
 		return audit.Trail{}, nil, err
 	}
@@ -117,8 +268,7 @@ func (s *Store) GetTrail(ctx context.Context, trailID string) (audit.Trail, []au
 	}
 
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id, trail_id, type, at, actor, targets, commands, result, evidence,
-		       correlation_id, prev_hash, hash
+		SELECT `+eventColumns+`
 		FROM audit_events
 		WHERE trail_id = ?
 		ORDER BY seq ASC
@@ -143,23 +293,72 @@ func (s *Store) GetTrail(ctx context.Context, trailID string) (audit.Trail, []au
 	return t, events, nil
 }
 
-func (s *Store) QueryEvents(ctx context.Context, q audit.Query) ([]audit.Event, error) {
+func (s *Store) QueryEvents(ctx context.Context, q audit.Query) (audit.EventPage, error) {
+	limit := q.Limit
+	if limit > 0 {
+		// One extra row tells us whether there is a next page.
+		limit++
+	}
+	query, args, err := eventQuery(q, limit)
+	if err != nil {
+		return audit.EventPage{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return audit.EventPage{}, err
+	}
+	defer rows.Close()
+
+	var page audit.EventPage
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return audit.EventPage{}, err
+		}
+		page.Events = append(page.Events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return audit.EventPage{}, err
+	}
+
+	if q.Limit > 0 && len(page.Events) > q.Limit {
+		page.Events = page.Events[:q.Limit]
+		page.NextCursor = audit.EncodeEventCursor(audit.EventCursor{Seq: page.Events[q.Limit-1].Seq})
+	}
+	return page, nil
+}
+
+// eventQuery builds the SELECT for q, returning at most limit rows
+// (limit <= 0 means no limit).
+func eventQuery(q audit.Query, limit int) (string, []any, error) {
 	var args []any
 	var b strings.Builder
 
 	b.WriteString(`
-		SELECT id, trail_id, type, at, actor, targets, commands, result, evidence,
-		       correlation_id, prev_hash, hash
+		SELECT ` + eventColumns + `
 		FROM audit_events
 		WHERE 1=1
 	`)
 
+	dir, cmp := "DESC", "<"
+	if q.Order.Ascending() {
+		dir, cmp = "ASC", ">"
+	}
+	if q.Cursor != "" {
+		c, err := audit.DecodeEventCursor(q.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, c.Seq)
+		b.WriteString(" AND seq " + cmp + " ?")
+	}
 	if !q.From.IsZero() {
-		args = append(args, q.From)
+		args = append(args, q.From.UTC())
 		b.WriteString(" AND at >= ?")
 	}
 	if !q.To.IsZero() {
-		args = append(args, q.To)
+		args = append(args, q.To.UTC())
 		b.WriteString(" AND at < ?")
 	}
 	if len(q.EventTypes) > 0 {
@@ -173,44 +372,257 @@ func (s *Store) QueryEvents(ctx context.Context, q audit.Query) ([]audit.Event,
 		}
 		b.WriteString(")")
 	}
+	if ts := q.AnyTargets(); len(ts) > 0 {
+		b.WriteString(" AND EXISTS (SELECT 1 FROM json_each(audit_events.targets) WHERE ")
+		for i, t := range ts {
+			if i > 0 {
+				b.WriteString(" OR ")
+			}
+			b.WriteString("(json_extract(value, '$.type') = ? AND json_extract(value, '$.id') = ?)")
+			args = append(args, t.Type, t.ID)
+		}
+		b.WriteString(")")
+	}
+	if len(q.TargetLabels) > 0 {
+		// json_quote turns the label key into a quoted path segment, so
+		// keys containing dots are not read as nested paths.
+		b.WriteString(" AND EXISTS (SELECT 1 FROM json_each(audit_events.targets) WHERE 1=1")
+		for _, k := range sortedKeys(q.TargetLabels) {
+			b.WriteString(" AND json_extract(value, '$.labels.' || json_quote(?)) = ?")
+			args = append(args, k, q.TargetLabels[k])
+		}
+		b.WriteString(")")
+	}
+	if q.ActorID != "" {
+		args = append(args, q.ActorID)
+		b.WriteString(" AND json_extract(actor, '$.id') = ?")
+	}
+	if q.ActorRole != "" {
+		args = append(args, q.ActorRole)
+		b.WriteString(" AND json_extract(actor, '$.role') = ?")
+	}
+	if q.CorrelationID != "" {
+		args = append(args, q.CorrelationID)
+		b.WriteString(" AND correlation_id = ?")
+	}
+	if len(q.TrailIDs) > 0 {
+		b.WriteString(" AND trail_id IN (")
+		for i, id := range q.TrailIDs {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString("?")
+			args = append(args, id)
+		}
+		b.WriteString(")")
+	}
+	if q.ResultStatus != "" {
+		args = append(args, q.ResultStatus)
+		b.WriteString(" AND json_extract(result, '$.status') = ?")
+	}
+
+	b.WriteString(" ORDER BY seq " + dir)
+	if limit > 0 {
+		args = append(args, limit)
+		b.WriteString(" LIMIT ?")
+	}
+
+	return b.String(), args, nil
+}
+
+// IterateEvents streams rows straight from database/sql, so memory use does
+// not grow with the result set.
+func (s *Store) IterateEvents(ctx context.Context, q audit.Query) (audit.EventIterator, error) {
+	query, args, err := eventQuery(q, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &rowsIterator{ctx: ctx, rows: rows}, nil
+}
+
+// rowsIterator adapts *sql.Rows to audit.EventIterator. database/sql closes
+// the rows asynchronously on cancellation, so Next also checks ctx itself.
+type rowsIterator struct {
+	ctx  context.Context
+	rows *sql.Rows
+	ev   audit.Event
+	err  error
+}
+
+func (it *rowsIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.err = it.ctx.Err(); it.err != nil {
+		return false
+	}
+	if !it.rows.Next() {
+		return false
+	}
+	it.ev, it.err = scanEvent(it.rows)
+	return it.err == nil
+}
+
+func (it *rowsIterator) Event() audit.Event { return it.ev }
+
+func (it *rowsIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+func (it *rowsIterator) Close() error { return it.rows.Close() }
+
+func (s *Store) ListTrails(ctx context.Context, q audit.TrailQuery) (audit.TrailPage, error) {
+	if err := q.Validate(); err != nil {
+		return audit.TrailPage{}, err
+	}
+
+	var args []any
+	var b strings.Builder
 
-	b.WriteString(" ORDER BY seq DESC")
+	// The REQUESTED event is the only one with an empty prev_hash, and
+	// audit_events_trail_prev_hash_idx makes it unique per trail.
+	b.WriteString(`
+		SELECT t.id, t.created_at, t.title, t.description, t.correlation_id, t.targets,
+			COALESCE(h.type, ''), h.at, COALESCE(r.actor, '{}')
+		FROM audit_trails t
+		LEFT JOIN audit_events h ON h.seq = (SELECT MAX(seq) FROM audit_events WHERE trail_id = t.id)
+		LEFT JOIN audit_events r ON r.trail_id = t.id AND r.prev_hash = ''
+		WHERE 1=1
+	`)
 
-	applyLimitInSQL := q.Limit > 0 && (q.TargetType == "" || q.TargetID == "")
-	if applyLimitInSQL {
+	if q.Cursor != "" {
+		c, err := audit.DecodeTrailCursor(q.Cursor)
+		if err != nil {
+			return audit.TrailPage{}, err
+		}
+		args = append(args, c.CreatedAt.UTC(), c.CreatedAt.UTC(), c.ID)
+		b.WriteString(" AND (t.created_at < ? OR (t.created_at = ? AND t.id < ?))")
+	}
+	if !q.From.IsZero() {
+		args = append(args, q.From.UTC())
+		b.WriteString(" AND t.created_at >= ?")
+	}
+	if !q.To.IsZero() {
+		args = append(args, q.To.UTC())
+		b.WriteString(" AND t.created_at < ?")
+	}
+	if q.TitleContains != "" {
+		args = append(args, q.TitleContains)
+		b.WriteString(" AND instr(lower(t.title), lower(?)) > 0")
+	}
+	if q.CorrelationID != "" {
+		args = append(args, q.CorrelationID)
+		b.WriteString(" AND t.correlation_id = ?")
+	}
+	if q.TargetType != "" {
+		args = append(args, q.TargetType)
+		b.WriteString(` AND EXISTS (
+			SELECT 1 FROM json_each(t.targets)
+			WHERE json_extract(value, '$.type') = ?`)
+		if q.TargetID != "" {
+			args = append(args, q.TargetID)
+			b.WriteString(" AND json_extract(value, '$.id') = ?")
+		}
+		b.WriteString(")")
+	}
+	if q.RequesterID != "" {
+		args = append(args, q.RequesterID)
+		b.WriteString(" AND json_extract(r.actor, '$.id') = ?")
+	}
+	if q.Status != "" {
+		args = append(args, q.Status)
+		b.WriteString(" AND h.type = ?")
+	}
+
+	b.WriteString(" ORDER BY t.created_at DESC, t.id DESC")
+	if q.Limit > 0 {
+		// One extra row tells us whether there is a next page.
+		args = append(args, q.Limit+1)
 		b.WriteString(" LIMIT ?")
-		args = append(args, q.Limit)
 	}
 
 	rows, err := s.db.QueryContext(ctx, b.String(), args...)
 	if err != nil {
-		return nil, err
+		return audit.TrailPage{}, err
 	}
 	defer rows.Close()
 
-	var out []audit.Event
+	var page audit.TrailPage
 	for rows.Next() {
-		ev, err := scanEvent(rows)
+		sum, err := scanTrailSummary(rows)
 		if err != nil {
-			return nil, err
-		}
-		if q.TargetType != "" && q.TargetID != "" {
-			if !eventHasTarget(ev, q.TargetType, q.TargetID) {
-				continue
-			}
+			return audit.TrailPage{}, err
 		}
-		out = append(out, ev)
+		page.Trails = append(page.Trails, sum)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return audit.TrailPage{}, err
 	}
 
-	if q.Limit > 0 && len(out) > q.Limit {
-		out = out[:q.Limit]
+	if q.Limit > 0 && len(page.Trails) > q.Limit {
+		page.Trails = page.Trails[:q.Limit]
+		last := page.Trails[q.Limit-1]
+		page.NextCursor = audit.EncodeTrailCursor(audit.TrailCursor{CreatedAt: last.CreatedAt, ID: last.ID})
 	}
-	return out, nil
+	return page, nil
 }
 
+func scanTrailSummary(r rowScanner) (audit.TrailSummary, error) {
+	var sum audit.TrailSummary
+	var targetsJSON, actorJSON []byte
+	var updatedAt sql.NullTime
+
+	err := r.Scan(
+		&sum.ID,
+		&sum.CreatedAt,
+		&sum.Title,
+		&sum.Description,
+		&sum.CorrelationID,
+		&targetsJSON,
+		&sum.Status,
+		&updatedAt,
+		&actorJSON,
+	)
+	if err != nil {
+		return audit.TrailSummary{}, err
+	}
+	sum.UpdatedAt = updatedAt.Time
+
+	if len(targetsJSON) > 0 {
+		if err := json.Unmarshal(targetsJSON, &sum.Targets); err != nil {
+			return audit.TrailSummary{}, err
+		}
+	}
+	if len(actorJSON) > 0 {
+		if err := json.Unmarshal(actorJSON, &sum.Requester); err != nil {
+			return audit.TrailSummary{}, err
+		}
+	}
+	return sum, nil
+}
+
+// sortedKeys keeps generated SQL stable for the same filter.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// eventColumns is the column list scanEvent expects, in order.
+const eventColumns = `seq, id, trail_id, type, at, actor, targets, commands, result, evidence,
+	correlation_id, trail_hash, prev_hash, hash, hash_version, key_id, signature`
+
 type rowScanner interface {
 	Scan(dest ...any) error
 }
@@ -220,6 +632,7 @@ func scanEvent(r rowScanner) (audit.Event, error) {
 	var actorJSON, targetsJSON, commandsJSON, resultJSON, evidenceJSON []byte
 
 	err := r.Scan(
+		&ev.Seq,
 		&ev.ID,
 		&ev.TrailID,
 		&ev.Type,
@@ -230,8 +643,12 @@ func scanEvent(r rowScanner) (audit.Event, error) {
 		&resultJSON,
 		&evidenceJSON,
 		&ev.CorrelationID,
+		&ev.TrailHash,
 		&ev.PrevHash,
 		&ev.Hash,
+		&ev.HashVersion,
+		&ev.KeyID,
+		&ev.Signature,
 	)
 	if err != nil {
 		return audit.Event{}, err
@@ -265,12 +682,3 @@ func scanEvent(r rowScanner) (audit.Event, error) {
 
 	return ev, nil
 }
-
-func eventHasTarget(e audit.Event, typ, id string) bool {
-	for _, tgt := range e.Targets {
-		if tgt.Type == typ && tgt.ID == id {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,112 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/sqlite"
+	"github.com/ajazfarhad/provenance/store/storetest"
+)
+
+// openDB returns a migrated database in a temporary file. Concurrent
+// appenders wait on busy_timeout for SQLite's single writer.
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := emptyDB(t)
+	if err := sqlite.Migrate(context.Background(), db); err != nil {
+		t.Fatalf("Migrate error: %v", err)
+	}
+	return db
+}
+
+// emptyDB returns a database in a temporary file with no tables.
+func emptyDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "audit.db")+"?_pragma=busy_timeout(10000)")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) audit.Store {
+		return sqlite.New(openDB(t), sqlite.WithOutbox(storetest.OutboxSinks...))
+	})
+}
+
+// The unique (trail_id, prev_hash) index stops forks even without a head
+// check, and its violation must surface as ErrConflict.
+func TestAppendEventForkIsConflict(t *testing.T) {
+	ctx := context.Background()
+	st := sqlite.New(openDB(t))
+	svc := audit.NewService(st, nil)
+
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	_, events, err := st.GetTrail(ctx, trailID)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+
+	fork := events[1]
+	fork.ID, fork.Hash = "fork", "fork-hash"
+	if err := st.AppendEvent(ctx, fork); !errors.Is(err, audit.ErrConflict) {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+}
+
+// A database created from the schema.sql shipped before migrations existed
+// must upgrade to a schema the current store can append to and verify.
+func TestMigrateFromBaselineSchema(t *testing.T) {
+	ctx := context.Background()
+	db := emptyDB(t)
+
+	schema, err := os.ReadFile("testdata/schema.sql")
+	if err != nil {
+		t.Fatalf("read baseline schema: %v", err)
+	}
+	if _, err := db.ExecContext(ctx, string(schema)); err != nil {
+		t.Fatalf("apply baseline schema: %v", err)
+	}
+	for i := 0; i < 2; i++ { // the second run must be a no-op
+		if err := sqlite.Migrate(ctx, db); err != nil {
+			t.Fatalf("Migrate error: %v", err)
+		}
+	}
+
+	st := sqlite.New(db, sqlite.WithOutbox())
+	svc := audit.NewService(st, nil)
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+	if err := svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+	if err := svc.VerifyLedger(ctx, 1, 0); err != nil {
+		t.Fatalf("VerifyLedger error: %v", err)
+	}
+	if _, err := svc.Checkpoint(ctx); err != nil {
+		t.Fatalf("Checkpoint error: %v", err)
+	}
+	if due, err := st.Deliveries(ctx, audit.DefaultSink, 0); err != nil || len(due) != 2 {
+		t.Fatalf("expected 2 outbox deliveries, got %d (err %v)", len(due), err)
+	}
+}
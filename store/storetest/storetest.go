@@ -0,0 +1,821 @@
+// Package storetest is a conformance suite for audit.Store implementations.
+// Each store package runs it from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) audit.Store {
+//			return memory.New(memory.WithOutbox(storetest.OutboxSinks...))
+//		})
+//	}
+//
+// Optional interfaces (Ledger, Notifier, Outbox, CheckpointStore) are tested
+// when the store implements them. Outbox stores must record deliveries for
+// OutboxSinks.
+package storetest
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"slices"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+)
+
+// OutboxSinks are the sinks an Outbox store must record deliveries for.
+var OutboxSinks = []string{"primary", "secondary"}
+
+// Run runs the suite, opening a new, empty store for every test.
+func Run(t *testing.T, open func(t *testing.T) audit.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, st audit.Store)
+	}{
+		{"TrailNotFound", testTrailNotFound},
+		{"RoundTrip", testRoundTrip},
+		{"SubMicrosecondClock", testSubMicrosecondClock},
+		{"CompareAndAppend", testCompareAndAppend},
+		{"ConcurrentCompareAndAppend", testConcurrentCompareAndAppend},
+		{"Ledger", testLedger},
+		{"QueryEvents", testQueryEvents},
+		{"IterateEvents", testIterateEvents},
+		{"ListTrails", testListTrails},
+		{"Notify", testNotify},
+		{"Outbox", testOutbox},
+		{"ConcurrentClaims", testConcurrentClaims},
+		{"Checkpoint", testCheckpoint},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) { tc.fn(t, open(t)) })
+	}
+}
+
+var (
+	base = time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	east = time.FixedZone("UTC+2", 2*60*60) // for bounds given in another zone
+	priv = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	pub  = priv.Public().(ed25519.PublicKey)
+)
+
+// newService returns a signing service whose clock advances a minute per
+// call, so every event has a distinct, whole-second time.
+func newService(st audit.Store) *audit.Service {
+	return newServiceAt(st, 0)
+}
+
+// newServiceAt is newService with offset added to every time.
+func newServiceAt(st audit.Store, offset time.Duration) *audit.Service {
+	var mu sync.Mutex
+	n := 0
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		n++
+		return base.Add(time.Duration(n)*time.Minute + offset)
+	}
+	return audit.NewService(st, nil,
+		audit.WithClock(clock),
+		audit.WithSigner(audit.NewEd25519Signer("k1", priv)),
+		audit.WithKeyResolver(audit.StaticKeys{"k1": pub}),
+	)
+}
+
+var (
+	sw1 = audit.Target{Type: "network_device", ID: "sw-1", Labels: map[string]string{"site": "dc1"}}
+	sw2 = audit.Target{Type: "network_device", ID: "sw-2", Labels: map[string]string{"site": "dc2"}}
+	fw1 = audit.Target{Type: "firewall", ID: "fw-1", Labels: map[string]string{"site": "dc1"}}
+)
+
+// fixture is the data seed writes.
+type fixture struct {
+	svc    *audit.Service
+	trails []string      // executed, failed, cancelled, empty
+	events []audit.Event // every event in ledger order
+}
+
+// seed writes three trails through a Service, plus one trail with no events.
+func seed(t *testing.T, st audit.Store) fixture {
+	t.Helper()
+	ctx := context.Background()
+	f := fixture{svc: newService(st)}
+
+	request := func(title, corr string, requester string, targets ...audit.Target) string {
+		t.Helper()
+		id, err := f.svc.Request(ctx, audit.RequestInput{
+			Title:         title,
+			CorrelationID: corr,
+			Requester:     audit.Actor{ID: requester, Name: "User " + requester},
+			Targets:       targets,
+		})
+		if err != nil {
+			t.Fatalf("Request error: %v", err)
+		}
+		f.trails = append(f.trails, id)
+		return id
+	}
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("seed error: %v", err)
+		}
+	}
+
+	a := request("Update NTP", "c-a", "u-1", sw1, sw2)
+	check(f.svc.Approve(ctx, a, audit.Actor{ID: "u-2"}, "c-a", "ok"))
+	check(f.svc.Execute(ctx, a, audit.Actor{ID: "svc-1"}, "c-a", []audit.Command{{
+		Kind:       "cli",
+		Raw:        "ntp server 10.0.0.1",
+		Output:     "OK",
+		OutputMeta: map[string]string{"exit": "0"},
+		Targets:    []audit.Target{{Type: sw1.Type, ID: sw1.ID}},
+	}}, audit.Result{Status: "SUCCESS", Message: "applied"}))
+
+	b := request("Open port 443", "c-b", "u-2", fw1)
+	check(f.svc.Approve(ctx, b, audit.Actor{ID: "u-1"}, "c-b", ""))
+	check(f.svc.Fail(ctx, b, audit.Actor{ID: "svc-1"}, "c-b", audit.Result{Message: "timeout"},
+		[]audit.Evidence{{Kind: "log", Ref: "job/42", Detail: map[string]string{"attempt": "3"}}}))
+
+	c := request("Reboot sw-1", "c-c", "u-1", sw1)
+	check(f.svc.Cancel(ctx, c, audit.Actor{ID: "u-1"}, "c-c", audit.Result{Message: "not needed"}, nil))
+
+	empty := audit.Trail{ID: "trail-without-events", CreatedAt: base.Add(time.Hour), Title: "Empty", Targets: []audit.Target{sw2}}
+	check(st.CreateTrail(ctx, empty))
+	f.trails = append(f.trails, empty.ID)
+
+	for _, id := range f.trails {
+		_, evs, err := st.GetTrail(ctx, id)
+		check(err)
+		f.events = append(f.events, evs...)
+	}
+	sort.Slice(f.events, func(i, j int) bool { return f.events[i].Seq < f.events[j].Seq })
+	return f
+}
+
+func testTrailNotFound(t *testing.T, st audit.Store) {
+	ctx := context.Background()
+
+	if _, _, err := st.GetTrail(ctx, "missing"); !errors.Is(err, audit.ErrTrailNotFound) {
+		t.Fatalf("GetTrail: expected ErrTrailNotFound, got %v", err)
+	}
+	e := audit.Event{ID: "e-1", TrailID: "missing", Type: audit.EventRequested, At: base, Hash: "h"}
+	if err := st.CompareAndAppend(ctx, e); !errors.Is(err, audit.ErrTrailNotFound) {
+		t.Fatalf("CompareAndAppend: expected ErrTrailNotFound, got %v", err)
+	}
+	if err := st.AppendEvent(ctx, e); !errors.Is(err, audit.ErrTrailNotFound) {
+		t.Fatalf("AppendEvent: expected ErrTrailNotFound, got %v", err)
+	}
+}
+
+func testRoundTrip(t *testing.T, st audit.Store) {
+	ctx := context.Background()
+	f := seed(t, st)
+
+	if err := st.CreateTrail(ctx, audit.Trail{ID: f.trails[0], CreatedAt: base, Title: "dup"}); err == nil {
+		t.Fatalf("CreateTrail with an existing ID succeeded")
+	}
+
+	// Every field is hashed or signed, so verification proves the store
+	// returned events exactly as they were written.
+	for _, id := range f.trails[:3] {
+		if err := f.svc.VerifyTrail(ctx, id); err != nil {
+			t.Fatalf("VerifyTrail(%s) error: %v", id, err)
+		}
+	}
+
+	trail, events, err := st.GetTrail(ctx, f.trails[0])
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	if trail.Title != "Update NTP" || !trail.CreatedAt.Equal(base.Add(time.Minute)) || len(trail.Targets) != 2 || trail.Targets[1].Labels["site"] != "dc2" {
+		t.Fatalf("unexpected trail %+v", trail)
+	}
+	if len(events) != 3 || events[2].Commands[0].OutputMeta["exit"] != "0" || events[2].Result.Message != "applied" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Seq <= events[i-1].Seq {
+			t.Fatalf("events not in chain order: %d after %d", events[i].Seq, events[i-1].Seq)
+		}
+	}
+
+	latest, err := st.LatestEvent(ctx, f.trails[1])
+	if err != nil || latest == nil || latest.Type != audit.EventFailed {
+		t.Fatalf("LatestEvent = %+v, %v; want the FAILED event", latest, err)
+	}
+	if latest, err := st.LatestEvent(ctx, f.trails[3]); err != nil || latest != nil {
+		t.Fatalf("LatestEvent of an empty trail = %+v, %v; want nil", latest, err)
+	}
+}
+
+// nextEvent returns an event that chains to prevHash on trailID.
+// testSubMicrosecondClock checks that times finer than a store keeps, e.g.
+// Postgres rounding to the microsecond, do not break the hashes over them.
+func testSubMicrosecondClock(t *testing.T, st audit.Store) {
+	ctx := context.Background()
+	svc := newServiceAt(st, 999*time.Nanosecond)
+
+	id, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}, Targets: []audit.Target{sw1}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, id, audit.Actor{ID: "u-2"}, "", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+
+	trail, events, err := st.GetTrail(ctx, id)
+	if err != nil {
+		t.Fatalf("GetTrail error: %v", err)
+	}
+	if !trail.CreatedAt.Equal(trail.CreatedAt.Truncate(time.Microsecond)) {
+		t.Fatalf("trail created at %v, finer than a microsecond", trail.CreatedAt)
+	}
+	for _, e := range events {
+		if !e.At.Equal(e.At.Truncate(time.Microsecond)) {
+			t.Fatalf("event %s stored at %v, finer than a microsecond", e.ID, e.At)
+		}
+	}
+	if err := svc.VerifyTrail(ctx, id); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+}
+
+func nextEvent(t *testing.T, trailID, id, prevHash string) audit.Event {
+	t.Helper()
+	e := audit.Event{
+		ID:       id,
+		TrailID:  trailID,
+		Type:     audit.EventApproved,
+		At:       base.Add(24 * time.Hour),
+		Actor:    audit.Actor{ID: "u-9"},
+		PrevHash: prevHash,
+	}
+	h, err := audit.ComputeEventHash(e)
+	if err != nil {
+		t.Fatalf("ComputeEventHash error: %v", err)
+	}
+	e.Hash = h
+	return e
+}
+
+func testCompareAndAppend(t *testing.T, st audit.Store) {
+	ctx := context.Background()
+	f := seed(t, st)
+
+	_, events, _ := st.GetTrail(ctx, f.trails[0])
+	head := events[len(events)-1].Hash
+
+	for _, prev := range []string{"", events[0].Hash, "bogus"} {
+		if err := st.CompareAndAppend(ctx, nextEvent(t, f.trails[0], "stale-"+prev, prev)); !errors.Is(err, audit.ErrConflict) {
+			t.Fatalf("CompareAndAppend after %q: expected ErrConflict, got %v", prev, err)
+		}
+	}
+	if err := st.CompareAndAppend(ctx, nextEvent(t, f.trails[3], "first", "bogus")); !errors.Is(err, audit.ErrConflict) {
+		t.Fatalf("CompareAndAppend on an empty trail: expected ErrConflict, got %v", err)
+	}
+	if _, got, _ := st.GetTrail(ctx, f.trails[0]); len(got) != len(events) {
+		t.Fatalf("conflicting appends stored events: %d, want %d", len(got), len(events))
+	}
+
+	if err := st.CompareAndAppend(ctx, nextEvent(t, f.trails[0], "next", head)); err != nil {
+		t.Fatalf("CompareAndAppend on the head error: %v", err)
+	}
+	if err := st.CompareAndAppend(ctx, nextEvent(t, f.trails[3], "first", "")); err != nil {
+		t.Fatalf("CompareAndAppend on an empty trail error: %v", err)
+	}
+	if latest, err := st.LatestEvent(ctx, f.trails[0]); err != nil || latest.ID != "next" {
+		t.Fatalf("LatestEvent = %+v, %v; want the appended event", latest, err)
+	}
+}
+
+func testConcurrentCompareAndAppend(t *testing.T, st audit.Store) {
+	ctx := context.Background()
+	f := seed(t, st)
+
+	latest, err := st.LatestEvent(ctx, f.trails[0])
+	if err != nil {
+		t.Fatalf("LatestEvent error: %v", err)
+	}
+
+	const writers = 8
+	errs := make([]error, writers)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = st.CompareAndAppend(ctx, nextEvent(t, f.trails[0], "racer-"+string(rune('a'+i)), latest.Hash))
+		}(i)
+	}
+	wg.Wait()
+
+	won := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, audit.ErrConflict):
+			t.Fatalf("CompareAndAppend error: %v", err)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("%d concurrent appends to the same head succeeded, want 1", won)
+	}
+
+	// Concurrent approvals through the service retry on conflict and leave
+	// a single, valid chain.
+	trailID, err := f.svc.Request(ctx, audit.RequestInput{Title: "Busy", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f.svc.Approve(ctx, trailID, audit.Actor{ID: "u-3"}, "", ""); err != nil && !errors.Is(err, audit.ErrConflict) {
+				t.Errorf("Approve error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := f.svc.VerifyTrail(ctx, trailID); err != nil {
+		t.Fatalf("VerifyTrail error: %v", err)
+	}
+}
+
+func testLedger(t *testing.T, st audit.Store) {
+	l, ok := st.(audit.Ledger)
+	if !ok {
+		t.Skip("store does not keep a ledger")
+	}
+	ctx := context.Background()
+	f := seed(t, st)
+
+	entries, err := l.LedgerEntries(ctx, 0, 0)
+	if err != nil {
+		t.Fatalf("LedgerEntries error: %v", err)
+	}
+	if len(entries) != len(f.events) {
+		t.Fatalf("expected %d ledger entries, got %d", len(f.events), len(entries))
+	}
+	for i, le := range entries {
+		e := f.events[i]
+		if le.Seq != e.Seq || le.EventID != e.ID || le.TrailID != e.TrailID || le.EventHash != e.Hash {
+			t.Fatalf("ledger entry %d = %+v does not match event %+v", i, le, e)
+		}
+	}
+	if err := f.svc.VerifyLedger(ctx, 0, 0); err != nil {
+		t.Fatalf("VerifyLedger error: %v", err)
+	}
+
+	from, to := entries[2].Seq, entries[4].Seq
+	part, err := l.LedgerEntries(ctx, from, to)
+	if err != nil || len(part) != 3 || part[0].Seq != from || part[2].Seq != to {
+		t.Fatalf("LedgerEntries(%d, %d) = %+v, %v", from, to, part, err)
+	}
+	if err := f.svc.VerifyLedger(ctx, from, 0); err != nil {
+		t.Fatalf("VerifyLedger from %d error: %v", from, err)
+	}
+}
+
+// eventQueries covers every Query filter, alone and combined.
+func eventQueries(f fixture) map[string]audit.Query {
+	at := func(i int) time.Time { return f.events[i].At }
+	return map[string]audit.Query{
+		"all":              {},
+		"target":           {TargetType: sw1.Type, TargetID: sw1.ID},
+		"any target":       {Targets: []audit.Target{{Type: fw1.Type, ID: fw1.ID}, {Type: sw2.Type, ID: sw2.ID}}},
+		"labels":           {TargetLabels: map[string]string{"site": "dc1"}},
+		"types":            {EventTypes: []audit.EventType{audit.EventApproved, audit.EventFailed}},
+		"actor":            {ActorID: "u-1"},
+		"role":             {ActorRole: audit.RoleRequester},
+		"correlation":      {CorrelationID: "c-b"},
+		"trails":           {TrailIDs: []string{f.trails[0], f.trails[2]}},
+		"result status":    {ResultStatus: "FAILED"},
+		"time range":       {From: at(2), To: at(6)},
+		"time range east":  {From: at(2).In(east), To: at(6).In(east)},
+		"combined":         {TargetType: sw1.Type, TargetID: sw1.ID, ActorID: "u-1", From: at(1)},
+		"nothing":          {ActorID: "nobody"},
+		"labels and types": {TargetLabels: map[string]string{"site": "dc1"}, EventTypes: []audit.EventType{audit.EventRequested}},
+	}
+}
+
+// expect applies q to every event of f in q.Order: what a store must return.
+func expect(f fixture, q audit.Query) []string {
+	var ids []string
+	for _, e := range f.events {
+		if q.Matches(e) {
+			ids = append(ids, e.ID)
+		}
+	}
+	if !q.Order.Ascending() {
+		slices.Reverse(ids)
+	}
+	return ids
+}
+
+func eventIDs(events []audit.Event) []string {
+	var ids []string
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func testQueryEvents(t *testing.T, st audit.Store) {
+	ctx := context.Background()
+	f := seed(t, st)
+
+	for name, q := range eventQueries(f) {
+		for _, order := range []audit.Order{audit.OrderDesc, audit.OrderAsc} {
+			q.Order = order
+			want := expect(f, q)
+
+			page, err := st.QueryEvents(ctx, q)
+			if err != nil {
+				t.Fatalf("%s %s: QueryEvents error: %v", name, order, err)
+			}
+			if got := eventIDs(page.Events); !slices.Equal(got, want) || page.NextCursor != "" {
+				t.Fatalf("%s %s: got %v (cursor %q), want %v", name, order, got, page.NextCursor, want)
+			}
+
+			paged := q
+			paged.Limit = 2
+			var got []string
+			for pages := 0; ; pages++ {
+				if pages > len(f.events) {
+					t.Fatalf("%s %s: cursor does not advance", name, order)
+				}
+				page, err := st.QueryEvents(ctx, paged)
+				if err != nil {
+					t.Fatalf("%s %s: QueryEvents page error: %v", name, order, err)
+				}
+				got = append(got, eventIDs(page.Events)...)
+				if page.NextCursor == "" {
+					break
+				}
+				paged.Cursor = page.NextCursor
+			}
+			if !slices.Equal(got, want) {
+				t.Fatalf("%s %s: paged got %v, want %v", name, order, got, want)
+			}
+		}
+	}
+
+	if _, err := st.QueryEvents(ctx, audit.Query{Cursor: "not a cursor"}); err == nil {
+		t.Fatalf("QueryEvents accepted a malformed cursor")
+	}
+}
+
+func testIterateEvents(t *testing.T, st audit.Store) {
+	ctx := context.Background()
+	f := seed(t, st)
+
+	collect := func(q audit.Query) []string {
+		t.Helper()
+		it, err := st.IterateEvents(ctx, q)
+		if err != nil {
+			t.Fatalf("IterateEvents error: %v", err)
+		}
+		defer it.Close()
+		var ids []string
+		for it.Next() {
+			ids = append(ids, it.Event().ID)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("iterator error: %v", err)
+		}
+		if err := it.Close(); err != nil {
+			t.Fatalf("Close error: %v", err)
+		}
+		return ids
+	}
+
+	for name, q := range eventQueries(f) {
+		for _, order := range []audit.Order{audit.OrderDesc, audit.OrderAsc} {
+			q.Order = order
+			want := expect(f, q)
+			if got := collect(q); !slices.Equal(got, want) {
+				t.Fatalf("%s %s: got %v, want %v", name, order, got, want)
+			}
+		}
+	}
+
+	// Cursor sets the start and Limit caps the total, like a single page.
+	q := audit.Query{Order: audit.OrderAsc, Limit: 2}
+	page, err := st.QueryEvents(ctx, q)
+	if err != nil {
+		t.Fatalf("QueryEvents error: %v", err)
+	}
+	q.Cursor, q.Limit = page.NextCursor, 3
+	if got, want := collect(q), expect(f, audit.Query{Order: audit.OrderAsc})[2:5]; !slices.Equal(got, want) {
+		t.Fatalf("from cursor: got %v, want %v", got, want)
+	}
+
+	// Stopping early and cancelling must not leak or block.
+	cctx, cancel := context.WithCancel(ctx)
+	it, err := st.IterateEvents(cctx, audit.Query{})
+	if err != nil {
+		t.Fatalf("IterateEvents error: %v", err)
+	}
+	if !it.Next() {
+		t.Fatalf("Next returned false: %v", it.Err())
+	}
+	cancel()
+	for it.Next() {
+	}
+	it.Close()
+	it.Close()
+}
+
+func testListTrails(t *testing.T, st audit.Store) {
+	ctx := context.Background()
+	f := seed(t, st)
+
+	// The expected summaries, derived from the trails themselves.
+	var all []audit.TrailSummary
+	for _, id := range f.trails {
+		trail, events, err := st.GetTrail(ctx, id)
+		if err != nil {
+			t.Fatalf("GetTrail error: %v", err)
+		}
+		sum := audit.TrailSummary{Trail: trail}
+		if len(events) > 0 {
+			sum.Requester = events[0].Actor
+			sum.Status = events[len(events)-1].Type
+			sum.UpdatedAt = events[len(events)-1].At
+		}
+		all = append(all, sum)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if !all[i].CreatedAt.Equal(all[j].CreatedAt) {
+			return all[i].CreatedAt.After(all[j].CreatedAt)
+		}
+		return all[i].ID > all[j].ID
+	})
+
+	queries := map[string]audit.TrailQuery{
+		"all":         {},
+		"status":      {Status: audit.EventExecuted},
+		"requester":   {RequesterID: "u-1"},
+		"title":       {TitleContains: "ntp"},
+		"correlation": {CorrelationID: "c-b"},
+		"target":      {TargetType: sw2.Type, TargetID: sw2.ID},
+		"target type": {TargetType: fw1.Type},
+		"time range":  {From: all[2].CreatedAt, To: all[0].CreatedAt},
+		"range east":  {From: all[2].CreatedAt.In(east), To: all[0].CreatedAt.In(east)},
+		"combined":    {RequesterID: "u-1", TargetType: sw1.Type, TargetID: sw1.ID, Status: audit.EventCancelled},
+	}
+	for name, q := range queries {
+		var want []string
+		for _, sum := range all {
+			if q.Matches(sum) {
+				want = append(want, sum.ID)
+			}
+		}
+
+		page, err := st.ListTrails(ctx, q)
+		if err != nil {
+			t.Fatalf("%s: ListTrails error: %v", name, err)
+		}
+		var got []string
+		for _, sum := range page.Trails {
+			got = append(got, sum.ID)
+		}
+		if !slices.Equal(got, want) || page.NextCursor != "" {
+			t.Fatalf("%s: got %v (cursor %q), want %v", name, got, page.NextCursor, want)
+		}
+
+		q.Limit = 1
+		got = nil
+		for pages := 0; ; pages++ {
+			if pages > len(all) {
+				t.Fatalf("%s: cursor does not advance", name)
+			}
+			page, err := st.ListTrails(ctx, q)
+			if err != nil {
+				t.Fatalf("%s: ListTrails page error: %v", name, err)
+			}
+			for _, sum := range page.Trails {
+				got = append(got, sum.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		if !slices.Equal(got, want) {
+			t.Fatalf("%s: paged got %v, want %v", name, got, want)
+		}
+	}
+
+	if _, err := st.ListTrails(ctx, audit.TrailQuery{TargetID: sw1.ID}); !errors.Is(err, audit.ErrInvalid) {
+		t.Fatalf("ListTrails with a target ID but no type: expected ErrInvalid, got %v", err)
+	}
+
+	page, err := st.ListTrails(ctx, audit.TrailQuery{})
+	if err != nil {
+		t.Fatalf("ListTrails error: %v", err)
+	}
+	for i, sum := range page.Trails {
+		w := all[i]
+		if sum.Requester.ID != w.Requester.ID || sum.Status != w.Status || !sum.UpdatedAt.Equal(w.UpdatedAt) || len(sum.Targets) != len(w.Targets) {
+			t.Fatalf("summary %d = %+v, want %+v", i, sum, w)
+		}
+	}
+}
+
+func testNotify(t *testing.T, st audit.Store) {
+	n, ok := st.(audit.Notifier)
+	if !ok {
+		t.Skip("store does not notify")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := n.Notify(ctx)
+	if ch == nil {
+		t.Skip("notifications unavailable")
+	}
+	seed(t, st)
+
+	select {
+	case <-ch:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("no notification after appending events")
+	}
+}
+
+func testOutbox(t *testing.T, st audit.Store) {
+	ob, ok := st.(audit.Outbox)
+	if !ok {
+		t.Skip("store has no outbox")
+	}
+	ctx := context.Background()
+	f := seed(t, st)
+	later := base.Add(365 * 24 * time.Hour)
+	primary, secondary := OutboxSinks[0], OutboxSinks[1]
+	ids := func(ds []audit.Delivery) []string {
+		var out []string
+		for _, d := range ds {
+			out = append(out, d.Event.ID)
+		}
+		return out
+	}
+
+	want := expect(f, audit.Query{Order: audit.OrderAsc})
+	for _, sink := range OutboxSinks {
+		all, err := ob.Deliveries(ctx, sink, 0)
+		if err != nil {
+			t.Fatalf("Deliveries(%s) error: %v", sink, err)
+		}
+		if got := ids(all); !slices.Equal(got, want) {
+			t.Fatalf("%s deliveries %v, want every event oldest first %v", sink, got, want)
+		}
+		if all[0].Sink != sink {
+			t.Fatalf("delivery sink = %q, want %q", all[0].Sink, sink)
+		}
+	}
+	if _, err := ob.ClaimDeliveries(ctx, "no-such-sink", later, time.Minute, 0); !errors.Is(err, audit.ErrUnknownSink) {
+		t.Fatalf("ClaimDeliveries for an unknown sink: expected ErrUnknownSink, got %v", err)
+	}
+
+	// Claims lease deliveries: a second claim skips the first one's.
+	first, err := ob.ClaimDeliveries(ctx, primary, later, time.Minute, 2)
+	if err != nil {
+		t.Fatalf("ClaimDeliveries error: %v", err)
+	}
+	if got := ids(first); !slices.Equal(got, want[:2]) {
+		t.Fatalf("first claim %v, want %v", got, want[:2])
+	}
+	second, err := ob.ClaimDeliveries(ctx, primary, later, time.Minute, 0)
+	if err != nil {
+		t.Fatalf("ClaimDeliveries error: %v", err)
+	}
+	if got := ids(second); !slices.Equal(got, want[2:]) {
+		t.Fatalf("second claim %v, want the unclaimed rest %v", got, want[2:])
+	}
+	if again, err := ob.ClaimDeliveries(ctx, primary, later.Add(30*time.Second), time.Minute, 0); err != nil || len(again) != 0 {
+		t.Fatalf("claim during the lease = %v, %v; want none", ids(again), err)
+	}
+
+	// Each sink has its own copy of every event.
+	other, err := ob.ClaimDeliveries(ctx, secondary, later, time.Minute, 0)
+	if err != nil || !slices.Equal(ids(other), want) {
+		t.Fatalf("%s claim = %v, %v; want every event", secondary, ids(other), err)
+	}
+
+	if err := ob.AckDelivery(ctx, first[0].ID); err != nil {
+		t.Fatalf("AckDelivery error: %v", err)
+	}
+	if err := ob.RetryDelivery(ctx, first[1].ID, later.Add(time.Hour), "boom"); err != nil {
+		t.Fatalf("RetryDelivery error: %v", err)
+	}
+	if err := ob.DeadLetter(ctx, second[0].ID, "gone"); err != nil {
+		t.Fatalf("DeadLetter error: %v", err)
+	}
+
+	// Once the leases expire, the rest of the claimed deliveries are due
+	// again; the retried one waits for its time and the dead one never is.
+	expired, err := ob.ClaimDeliveries(ctx, primary, later.Add(2*time.Minute), time.Minute, 0)
+	if err != nil {
+		t.Fatalf("ClaimDeliveries error: %v", err)
+	}
+	if got := ids(expired); !slices.Equal(got, want[3:]) {
+		t.Fatalf("claim after the lease %v, want %v", got, want[3:])
+	}
+	retried, err := ob.ClaimDeliveries(ctx, primary, later.Add(time.Hour), time.Minute, 0)
+	if got := ids(retried); err != nil || !slices.Equal(got, append([]string{want[1]}, want[3:]...)) {
+		t.Fatalf("claim at the retry time = %v, %v", got, err)
+	}
+	if d := retried[0]; d.ID != first[1].ID || d.Attempts != 1 || d.LastError != "boom" || d.Dead {
+		t.Fatalf("retried delivery = %+v", d)
+	}
+
+	rest, err := ob.Deliveries(ctx, primary, 0)
+	if err != nil {
+		t.Fatalf("Deliveries error: %v", err)
+	}
+	if len(rest) != len(want)-1 || rest[0].ID != first[1].ID {
+		t.Fatalf("acked delivery still listed: %v", ids(rest))
+	}
+	if d := rest[1]; d.ID != second[0].ID || !d.Dead || d.Attempts != 1 || d.LastError != "gone" {
+		t.Fatalf("dead-lettered delivery = %+v", d)
+	}
+	if left, err := ob.Deliveries(ctx, secondary, 0); err != nil || len(left) != len(want) {
+		t.Fatalf("%s lost deliveries acked on %s: %d left, %v", secondary, primary, len(left), err)
+	}
+}
+
+func testConcurrentClaims(t *testing.T, st audit.Store) {
+	ob, ok := st.(audit.Outbox)
+	if !ok {
+		t.Skip("store has no outbox")
+	}
+	ctx := context.Background()
+	f := seed(t, st)
+	later := base.Add(365 * 24 * time.Hour)
+	sink := OutboxSinks[0]
+
+	var mu sync.Mutex
+	seen := make(map[int64]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				ds, err := ob.ClaimDeliveries(ctx, sink, later, time.Hour, 1)
+				if err != nil {
+					t.Errorf("ClaimDeliveries error: %v", err)
+					return
+				}
+				if len(ds) == 0 {
+					return
+				}
+				mu.Lock()
+				seen[ds[0].ID]++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if want := len(expect(f, audit.Query{})); len(seen) != want {
+		t.Fatalf("claimed %d deliveries, want %d", len(seen), want)
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Fatalf("delivery %d claimed %d times", id, n)
+		}
+	}
+}
+
+func testCheckpoint(t *testing.T, st audit.Store) {
+	cs, ok := st.(audit.CheckpointStore)
+	if !ok {
+		t.Skip("store does not keep checkpoints")
+	}
+	ctx := context.Background()
+	f := seed(t, st)
+
+	if latest, err := cs.LatestCheckpoint(ctx); err != nil || latest != nil {
+		t.Fatalf("LatestCheckpoint before any = %+v, %v", latest, err)
+	}
+	c, err := f.svc.Checkpoint(ctx)
+	if err != nil {
+		t.Fatalf("Checkpoint error: %v", err)
+	}
+	latest, err := cs.LatestCheckpoint(ctx)
+	if err != nil || latest == nil {
+		t.Fatalf("LatestCheckpoint = %+v, %v", latest, err)
+	}
+	if latest.TreeSize != int64(len(f.events)) || latest.RootHash != c.RootHash {
+		t.Fatalf("LatestCheckpoint = %+v, want %+v", latest, c)
+	}
+	if err := audit.VerifyCheckpoint(*latest, pub); err != nil {
+		t.Fatalf("VerifyCheckpoint error: %v", err)
+	}
+}
@@ -1,15 +1,22 @@
 package provenance
 
-import "github.com/ajazfarhad/provenance/audit"
+import (
+	"context"
+	"crypto/ed25519"
+
+	"github.com/ajazfarhad/provenance/audit"
+)
 
 type EventType = audit.EventType
 
 const (
-	EventRequested EventType = audit.EventRequested
-	EventApproved  EventType = audit.EventApproved
-	EventExecuted  EventType = audit.EventExecuted
-	EventVerified  EventType = audit.EventVerified
-	EventFailed    EventType = audit.EventFailed
+	EventRequested  EventType = audit.EventRequested
+	EventApproved   EventType = audit.EventApproved
+	EventExecuted   EventType = audit.EventExecuted
+	EventVerified   EventType = audit.EventVerified
+	EventFailed     EventType = audit.EventFailed
+	EventCancelled  EventType = audit.EventCancelled
+	EventRolledBack EventType = audit.EventRolledBack
 )
 
 type ActorRole = audit.ActorRole
@@ -29,10 +36,96 @@ type Evidence = audit.Evidence
 type Event = audit.Event
 type Trail = audit.Trail
 type Query = audit.Query
+type Order = audit.Order
+type EventPage = audit.EventPage
+type EventIterator = audit.EventIterator
+type Subscription = audit.Subscription
+type Notifier = audit.Notifier
+type TrailQuery = audit.TrailQuery
+type TrailSummary = audit.TrailSummary
+type TrailPage = audit.TrailPage
 type RequestInput = audit.RequestInput
 
+const (
+	OrderDesc Order = audit.OrderDesc
+	OrderAsc  Order = audit.OrderAsc
+)
+
+const FromLatest = audit.FromLatest
+
 type VerifyError = audit.VerifyError
+type VerifyReport = audit.VerifyReport
+type VerifyResult = audit.VerifyResult
+type LedgerEntry = audit.LedgerEntry
+type Ledger = audit.Ledger
+type Checkpoint = audit.Checkpoint
+type CheckpointStore = audit.CheckpointStore
+type InclusionProof = audit.InclusionProof
+type ConsistencyProof = audit.ConsistencyProof
+
+type Transitions = audit.Transitions
+type TransitionError = audit.TransitionError
+
+const StateNew EventType = audit.StateNew
+
+type Policy = audit.Policy
+type PolicyFunc = audit.PolicyFunc
+type ActorMetaPolicy = audit.ActorMetaPolicy
+type PolicyError = audit.PolicyError
+type ApprovalPolicy = audit.ApprovalPolicy
+
+type Signer = audit.Signer
+type KeyResolver = audit.KeyResolver
+type Ed25519Signer = audit.Ed25519Signer
+type StaticKeys = audit.StaticKeys
+
+func NewEd25519Signer(keyID string, key ed25519.PrivateKey) *Ed25519Signer {
+	return audit.NewEd25519Signer(keyID, key)
+}
+
+func DefaultTransitions() Transitions {
+	return audit.DefaultTransitions()
+}
+
+type HashScheme = audit.HashScheme
+type HashSchemeFunc = audit.HashSchemeFunc
+
+const (
+	HashV1             = audit.HashV1
+	HashV2             = audit.HashV2
+	DefaultHashVersion = audit.DefaultHashVersion
+)
+
+func RegisterHashScheme(version int, scheme HashScheme) error {
+	return audit.RegisterHashScheme(version, scheme)
+}
+
+var (
+	ErrChainBroken         = audit.ErrChainBroken
+	ErrHashMismatch        = audit.ErrHashMismatch
+	ErrBadSignature        = audit.ErrBadSignature
+	ErrTrailHeaderMismatch = audit.ErrTrailHeaderMismatch
+	ErrBadCursor           = audit.ErrBadCursor
+	ErrTrailNotFound       = audit.ErrTrailNotFound
+	ErrInvalid             = audit.ErrInvalid
+)
+
+func ComputeTrailHash(t Trail) (string, error) {
+	return audit.ComputeTrailHash(t)
+}
 
 func ComputeEventHash(e Event) (string, error) {
 	return audit.ComputeEventHash(e)
 }
+
+func VerifyEvents(ctx context.Context, trail Trail, events []Event, keys KeyResolver) error {
+	return audit.VerifyEvents(ctx, trail, events, keys)
+}
+
+func VerifyInclusion(p InclusionProof, root string) error {
+	return audit.VerifyInclusion(p, root)
+}
+
+func VerifyConsistency(p ConsistencyProof, oldRoot, newRoot string) error {
+	return audit.VerifyConsistency(p, oldRoot, newRoot)
+}
@@ -0,0 +1,271 @@
+// Package webhook POSTs audit events to an HTTP endpoint, signing each body
+// with HMAC-SHA256. Deliveries come from a store's transactional outbox
+// (audit.Outbox), so an event appended before a crash is still delivered.
+// Each Sink drains the outbox deliveries recorded under its name, and
+// replicas running a Sink of the same name share them out by claiming.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+)
+
+const (
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the body.
+	SignatureHeader = "X-Provenance-Signature"
+	// EventHeader carries the event ID; deliveries are at least once, so
+	// receivers should deduplicate on it.
+	EventHeader = "X-Provenance-Event"
+	// EventTypeHeader carries the event type, e.g. "APPROVED".
+	EventTypeHeader = "X-Provenance-Event-Type"
+)
+
+const (
+	DefaultMinBackoff   = time.Second
+	DefaultMaxBackoff   = 5 * time.Minute
+	DefaultJitter       = 0.5
+	DefaultMaxAttempts  = 25
+	DefaultLease        = 5 * time.Minute
+	DefaultBatchSize    = 100
+	DefaultPollInterval = time.Second
+)
+
+// Sink delivers events to one URL.
+type Sink struct {
+	url          string
+	secret       []byte
+	name         string
+	client       *http.Client
+	now          func() time.Time
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	jitter       float64
+	maxAttempts  int
+	lease        time.Duration
+	batchSize    int
+	pollInterval time.Duration
+}
+
+type Option func(*Sink)
+
+// WithName sets the outbox sink whose deliveries the Sink claims. The store
+// must record deliveries under it; the default is audit.DefaultSink.
+func WithName(name string) Option {
+	return func(s *Sink) { s.name = name }
+}
+
+func WithHTTPClient(c *http.Client) Option {
+	return func(s *Sink) { s.client = c }
+}
+
+func WithClock(now func() time.Time) Option {
+	return func(s *Sink) { s.now = now }
+}
+
+// WithBackoff sets the delay before the first retry; each further failure
+// doubles it, up to max.
+func WithBackoff(min, max time.Duration) Option {
+	return func(s *Sink) { s.minBackoff, s.maxBackoff = min, max }
+}
+
+// WithJitter shortens each retry delay by a random fraction of it, up to
+// frac, so deliveries that failed together do not all retry together.
+// 0 disables it.
+func WithJitter(frac float64) Option {
+	return func(s *Sink) { s.jitter = frac }
+}
+
+// WithMaxAttempts sets how many failed attempts a delivery gets before it is
+// dead-lettered (see audit.Outbox.DeadLetter). n <= 0 retries forever.
+func WithMaxAttempts(n int) Option {
+	return func(s *Sink) { s.maxAttempts = n }
+}
+
+// WithLease sets how long a claimed batch is reserved for this Sink. Flush
+// leaves a batch's remaining deliveries to the next claim once the lease has
+// run out, so it should comfortably exceed the HTTP client's timeout.
+func WithLease(d time.Duration) Option {
+	return func(s *Sink) { s.lease = d }
+}
+
+// WithBatchSize sets how many deliveries Flush claims from the outbox at once.
+func WithBatchSize(n int) Option {
+	return func(s *Sink) { s.batchSize = n }
+}
+
+// WithPollInterval sets how often Run checks an outbox that cannot notify.
+func WithPollInterval(d time.Duration) Option {
+	return func(s *Sink) { s.pollInterval = d }
+}
+
+func New(url string, secret []byte, opts ...Option) *Sink {
+	s := &Sink{
+		url:          url,
+		secret:       secret,
+		name:         audit.DefaultSink,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		now:          func() time.Time { return time.Now().UTC() },
+		minBackoff:   DefaultMinBackoff,
+		maxBackoff:   DefaultMaxBackoff,
+		jitter:       DefaultJitter,
+		maxAttempts:  DefaultMaxAttempts,
+		lease:        DefaultLease,
+		batchSize:    DefaultBatchSize,
+		pollInterval: DefaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Deliver POSTs e as JSON once. Any non-2xx response is an error.
+func (s *Sink) Deliver(ctx context.Context, e audit.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(s.secret, body))
+	req.Header.Set(EventHeader, e.ID)
+	req.Header.Set(EventTypeHeader, string(e.Type))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s returned %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+// Flush claims the Sink's due deliveries in ob a batch at a time and
+// attempts each once, removing those that succeed, rescheduling failures
+// with jittered exponential backoff and dead-lettering those out of
+// attempts. It returns how many were delivered; errors are only returned
+// for outbox failures. Deliveries claimed by another Flush, in this process
+// or another, are skipped until their lease runs out.
+func (s *Sink) Flush(ctx context.Context, ob audit.Outbox) (int, error) {
+	delivered := 0
+	for {
+		claimed := s.now()
+		due, err := ob.ClaimDeliveries(ctx, s.name, claimed, s.lease, s.batchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		progress := false
+		for _, d := range due {
+			if !s.now().Before(claimed.Add(s.lease)) {
+				break // the rest may already be claimed elsewhere
+			}
+			if err := s.Deliver(ctx, d.Event); err != nil {
+				if ctx.Err() != nil {
+					return delivered, ctx.Err()
+				}
+				if err := s.fail(ctx, ob, d, err); err != nil {
+					return delivered, err
+				}
+				continue
+			}
+			if err := ob.AckDelivery(ctx, d.ID); err != nil {
+				return delivered, err
+			}
+			delivered++
+			progress = true
+		}
+
+		// A short or fully failed batch means nothing else is due yet.
+		if len(due) < s.batchSize || !progress {
+			return delivered, nil
+		}
+	}
+}
+
+// fail records a failed attempt at d, dead-lettering it if that was its last.
+func (s *Sink) fail(ctx context.Context, ob audit.Outbox, d audit.Delivery, err error) error {
+	if s.maxAttempts > 0 && d.Attempts+1 >= s.maxAttempts {
+		return ob.DeadLetter(ctx, d.ID, err.Error())
+	}
+	return ob.RetryDelivery(ctx, d.ID, s.now().Add(s.RetryDelay(d.Attempts)), err.Error())
+}
+
+// Run flushes ob until ctx is done. It wakes on appends if ob implements
+// audit.Notifier, and otherwise every poll interval; the poll also picks up
+// retries as they fall due.
+func (s *Sink) Run(ctx context.Context, ob audit.Outbox) error {
+	var wake <-chan struct{}
+	if n, ok := ob.(audit.Notifier); ok {
+		wake = n.Notify(ctx)
+	}
+
+	poll := time.NewTicker(s.pollInterval)
+	defer poll.Stop()
+
+	for {
+		if _, err := s.Flush(ctx, ob); err != nil && ctx.Err() == nil {
+			return err
+		}
+
+		select {
+		case <-wake:
+		case <-poll.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Backoff returns the longest delay after a delivery has failed attempts+1
+// times: the minimum backoff doubled per earlier failure, up to the maximum.
+func (s *Sink) Backoff(attempts int) time.Duration {
+	d := s.minBackoff
+	for i := 0; i < attempts && d < s.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > s.maxBackoff {
+		d = s.maxBackoff
+	}
+	return d
+}
+
+// RetryDelay returns the delay Flush waits after a delivery has failed
+// attempts+1 times: Backoff(attempts), less a random share of up to the
+// jitter fraction.
+func (s *Sink) RetryDelay(attempts int) time.Duration {
+	d := s.Backoff(attempts)
+	return d - time.Duration(rand.Float64()*s.jitter*float64(d))
+}
+
+// Sign returns the SignatureHeader value for body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether header is a valid SignatureHeader for body.
+// Receivers should call it on the raw request body before parsing it.
+func Verify(secret, body []byte, header string) bool {
+	return hmac.Equal([]byte(header), []byte(Sign(secret, body)))
+}
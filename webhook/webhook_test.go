@@ -0,0 +1,320 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ajazfarhad/provenance/audit"
+	"github.com/ajazfarhad/provenance/store/memory"
+	"github.com/ajazfarhad/provenance/webhook"
+)
+
+var secret = []byte("s3cret")
+
+type receiver struct {
+	mu       sync.Mutex
+	failures int // respond 503 this many times first
+	got      []audit.Event
+	bad      int // requests with an invalid signature
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !webhook.Verify(secret, body, req.Header.Get(webhook.SignatureHeader)) {
+		r.bad++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.failures > 0 {
+		r.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var e audit.Event
+	if err := json.Unmarshal(body, &e); err != nil || req.Header.Get(webhook.EventHeader) != e.ID {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.got = append(r.got, e)
+}
+
+func TestFlushDeliversSignedEvents(t *testing.T) {
+	ctx := context.Background()
+
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	st := memory.New(memory.WithOutbox())
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "corr", "ok"); err != nil {
+		t.Fatalf("Approve error: %v", err)
+	}
+
+	sink := webhook.New(srv.URL, secret)
+	n, err := sink.Flush(ctx, st)
+	if err != nil {
+		t.Fatalf("Flush error: %v", err)
+	}
+	if n != 2 || len(rcv.got) != 2 {
+		t.Fatalf("expected 2 deliveries, got %d (received %d)", n, len(rcv.got))
+	}
+	if rcv.got[0].Type != audit.EventRequested || rcv.got[1].Type != audit.EventApproved {
+		t.Fatalf("expected deliveries in append order, got %s, %s", rcv.got[0].Type, rcv.got[1].Type)
+	}
+	if rcv.got[1].Seq == 0 || rcv.got[1].Hash == "" {
+		t.Fatalf("expected the delivered event to carry seq and hash, got %+v", rcv.got[1])
+	}
+
+	left, _ := st.Deliveries(ctx, audit.DefaultSink, 0)
+	if len(left) != 0 {
+		t.Fatalf("expected an empty outbox, got %d deliveries", len(left))
+	}
+}
+
+func TestFlushRetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+
+	rcv := &receiver{failures: 2}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	now := time.Date(2026, 2, 3, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	st := memory.New(memory.WithOutbox())
+	svc := audit.NewService(st, audit.NoopSanitizer{}, audit.WithClock(clock))
+	if _, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}}); err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	sink := webhook.New(srv.URL, secret, webhook.WithClock(clock), webhook.WithBackoff(time.Second, time.Minute), webhook.WithJitter(0))
+
+	// First failure: retry after 1s.
+	if n, err := sink.Flush(ctx, st); err != nil || n != 0 {
+		t.Fatalf("expected a failed delivery, got n=%d err=%v", n, err)
+	}
+	pending, _ := st.Deliveries(ctx, audit.DefaultSink, 0)
+	if len(pending) != 1 || pending[0].Attempts != 1 || !pending[0].NextAt.Equal(now.Add(time.Second)) || pending[0].LastError == "" {
+		t.Fatalf("unexpected delivery after first failure: %+v", pending)
+	}
+
+	// Not due yet: nothing is attempted.
+	if n, _ := sink.Flush(ctx, st); n != 0 || rcv.failures != 1 {
+		t.Fatalf("expected no attempt before the backoff elapsed")
+	}
+
+	// Second failure doubles the backoff.
+	now = now.Add(time.Second)
+	sink.Flush(ctx, st)
+	pending, _ = st.Deliveries(ctx, audit.DefaultSink, 0)
+	if len(pending) != 1 || pending[0].Attempts != 2 || !pending[0].NextAt.Equal(now.Add(2*time.Second)) {
+		t.Fatalf("unexpected delivery after second failure: %+v", pending)
+	}
+
+	now = now.Add(2 * time.Second)
+	if n, err := sink.Flush(ctx, st); err != nil || n != 1 {
+		t.Fatalf("expected the retry to succeed, got n=%d err=%v", n, err)
+	}
+	if len(rcv.got) != 1 {
+		t.Fatalf("expected exactly one successful delivery, got %d", len(rcv.got))
+	}
+}
+
+func TestBackoffIsCapped(t *testing.T) {
+	sink := webhook.New("http://example.invalid", secret, webhook.WithBackoff(time.Second, 10*time.Second))
+
+	for attempts, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		if got := sink.Backoff(attempts); got != want {
+			t.Fatalf("Backoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestRetryDelayIsJittered(t *testing.T) {
+	sink := webhook.New("http://example.invalid", secret, webhook.WithBackoff(time.Second, 10*time.Second), webhook.WithJitter(0.5))
+
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		d := sink.RetryDelay(2)
+		if d < 2*time.Second || d > 4*time.Second {
+			t.Fatalf("RetryDelay(2) = %s, want between 2s and 4s", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("expected jittered delays to vary, got %v", seen)
+	}
+}
+
+func TestFlushDeadLettersAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+
+	rcv := &receiver{failures: 10}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	now := time.Date(2026, 2, 3, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	st := memory.New(memory.WithOutbox())
+	svc := audit.NewService(st, audit.NoopSanitizer{}, audit.WithClock(clock))
+	if _, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}}); err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	sink := webhook.New(srv.URL, secret, webhook.WithClock(clock), webhook.WithMaxAttempts(3))
+	for i := 0; i < 5; i++ {
+		sink.Flush(ctx, st)
+		now = now.Add(time.Hour)
+	}
+	if rcv.failures != 7 {
+		t.Fatalf("expected 3 attempts, got %d", 10-rcv.failures)
+	}
+	left, _ := st.Deliveries(ctx, audit.DefaultSink, 0)
+	if len(left) != 1 || !left[0].Dead || left[0].Attempts != 3 || left[0].LastError == "" {
+		t.Fatalf("expected a dead-lettered delivery, got %+v", left)
+	}
+}
+
+func TestSinksDeliverIndependently(t *testing.T) {
+	ctx := context.Background()
+
+	up := &receiver{}
+	upSrv := httptest.NewServer(up)
+	defer upSrv.Close()
+	down := &receiver{failures: 1 << 30}
+	downSrv := httptest.NewServer(down)
+	defer downSrv.Close()
+
+	st := memory.New(memory.WithOutbox("up", "down"))
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+	if _, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}}); err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	if n, err := webhook.New(downSrv.URL, secret, webhook.WithName("down")).Flush(ctx, st); err != nil || n != 0 {
+		t.Fatalf("expected the failing sink to deliver nothing, got n=%d err=%v", n, err)
+	}
+	if n, err := webhook.New(upSrv.URL, secret, webhook.WithName("up")).Flush(ctx, st); err != nil || n != 1 {
+		t.Fatalf("expected the healthy sink to deliver, got n=%d err=%v", n, err)
+	}
+	if left, _ := st.Deliveries(ctx, "down", 0); len(left) != 1 || left[0].Attempts != 1 {
+		t.Fatalf("expected the failing sink's delivery to stay pending, got %+v", left)
+	}
+	if _, err := webhook.New(upSrv.URL, secret, webhook.WithName("typo")).Flush(ctx, st); !errors.Is(err, audit.ErrUnknownSink) {
+		t.Fatalf("expected ErrUnknownSink for an unconfigured sink, got %v", err)
+	}
+}
+
+func TestConcurrentFlushesDeliverOnce(t *testing.T) {
+	ctx := context.Background()
+
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	st := memory.New(memory.WithOutbox())
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+	trailID, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}})
+	if err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := svc.Approve(ctx, trailID, audit.Actor{ID: "u-2"}, "", ""); err != nil {
+			t.Fatalf("Approve error: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := webhook.New(srv.URL, secret, webhook.WithBatchSize(2)).Flush(ctx, st); err != nil {
+				t.Errorf("Flush error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for _, e := range rcv.got {
+		if seen[e.ID] {
+			t.Fatalf("event %s delivered twice", e.ID)
+		}
+		seen[e.ID] = true
+	}
+	if len(seen) != 21 {
+		t.Fatalf("expected 21 deliveries, got %d", len(seen))
+	}
+}
+
+func TestVerifyRejectsTamperedBody(t *testing.T) {
+	body := []byte(`{"id":"e-1"}`)
+	sig := webhook.Sign(secret, body)
+
+	if !webhook.Verify(secret, body, sig) {
+		t.Fatalf("expected signature to verify")
+	}
+	if webhook.Verify(secret, []byte(`{"id":"e-2"}`), sig) {
+		t.Fatalf("expected tampered body to be rejected")
+	}
+	if webhook.Verify([]byte("other"), body, sig) {
+		t.Fatalf("expected wrong secret to be rejected")
+	}
+}
+
+func TestRunDeliversOnAppend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	st := memory.New(memory.WithOutbox())
+	svc := audit.NewService(st, audit.NoopSanitizer{})
+
+	sink := webhook.New(srv.URL, secret, webhook.WithPollInterval(time.Hour))
+	done := make(chan error, 1)
+	go func() { done <- sink.Run(ctx, st) }()
+
+	if _, err := svc.Request(ctx, audit.RequestInput{Title: "Update NTP", Requester: audit.Actor{ID: "u-1"}}); err != nil {
+		t.Fatalf("Request error: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rcv.mu.Lock()
+		n := len(rcv.got)
+		rcv.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for delivery")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected Run to stop with context.Canceled, got %v", err)
+	}
+}